@@ -5,18 +5,36 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"log"
+	"net/mail"
+	"strings"
 	"time"
+	"unicode"
 )
 
 type Account struct {
-	id      string
-	owner   string
-	balance float64
-	events  []event.Event
+	id         string
+	owner      string
+	group      string
+	contact    ContactDetails
+	balance    float64
+	consumed   int
+	debtSince  time.Time
+	activity   time.Time // the latest booking on the account
+	plan       *Subscription
+	covered    map[string]int // cups covered by a subscription per billing period
+	billed     string         // the latest billing period a subscription fee has been charged for
+	mergedInto string         // the account this account has been merged into
+	mergedFrom []string       // the accounts that have been merged into this account
+	events     []event.Event
 }
 
-func NewAccount(owner string) (*Account, error) {
-	created := NewAccountCreated(uuid.New().String(), time.Now(), owner)
+// NewAccount creates a new account for the given owner. The email address is optional
+// and is used to notify the owner about their balance.
+func NewAccount(owner string, email string) (*Account, error) {
+	if err := validateEmail(email); err != nil {
+		return nil, err
+	}
+	created := NewAccountCreated(uuid.New().String(), time.Now(), owner, email)
 	a := Account{}
 	if err := a.apply(*created); err != nil {
 		return nil, err
@@ -36,6 +54,18 @@ func (a *Account) apply(e event.Event) error {
 		return a.createAccount(theEvent)
 	case IncomingPayment:
 		return a.applyPayment(theEvent)
+	case ContactDetailsUpdated:
+		return a.applyContactDetails(theEvent)
+	case GroupAssigned:
+		return a.applyGroup(theEvent)
+	case Subscribed:
+		return a.applySubscribed(theEvent)
+	case Unsubscribed:
+		return a.applyUnsubscribed(theEvent)
+	case SubscriptionFeeCharged:
+		return a.applyFeeCharged(theEvent)
+	case AccountMerged:
+		return a.applyMerged(theEvent)
 	default:
 		return fmt.Errorf("unknown event: %v", e)
 	}
@@ -63,20 +93,83 @@ func (a *Account) applyConsumed(e CoffyConsumed) error {
 	if e.AggregateID() != a.id {
 		return fmt.Errorf("event aggregate id does not match current aggregate")
 	}
-	a.balance -= e.Costs
+	a.updateBalance(-e.Costs, e.Occurred())
+	a.consumed++
+	if e.PlanID != "" {
+		if a.covered == nil {
+			a.covered = make(map[string]int)
+		}
+		period := e.Period
+		if period == "" {
+			// cups recorded before billing periods were stored belong to the calendar month
+			period = BillingPeriod(e.Occurred())
+		}
+		a.covered[period]++
+	}
 	a.events = append(a.events, e)
 	return nil
 }
 
+// updateBalance changes the balance by the given delta and keeps track of the latest activity
+// and the time point the account went into debt.
+func (a *Account) updateBalance(delta float64, occurred time.Time) {
+	wasInDebt := a.balance < 0
+	a.balance += delta
+	if occurred.After(a.activity) {
+		a.activity = occurred
+	}
+	switch {
+	case a.balance >= 0:
+		a.debtSince = time.Time{}
+	case !wasInDebt:
+		a.debtSince = occurred
+	}
+}
+
 // Consume charges the account with the price of the coffee consumed
 // and stores the type of coffee.
 //
 // The value for the price must be greater or equal zero.
 func (a *Account) Consume(price float64, coffeeType string) error {
-	if price < 0 {
+	return a.Record(Consumption{CoffeeType: coffeeType, Costs: price})
+}
+
+// Consumption describes a single coffee that is charged to an account.
+type Consumption struct {
+	CoffeeType string    // the type of coffee consumed
+	Beverage   string    // the beverage prepared from the coffee, empty if the coffee was consumed as is
+	Costs      float64   // the amount charged to the account
+	Subsidy    float64   // the part of the price that is paid by someone else, e.g. the employer
+	PlanID     string    // the subscription plan that covers the consumption, empty if charged per cup
+	Period     string    // the billing period of the plan that covers the consumption
+	ReceiptID  string    // the receipt that covers the consumption, empty if no receipt was issued
+	MachineID  string    // the machine the coffee was taken from, empty if unknown
+	OccurredOn time.Time // the time of the consumption, zero for now
+}
+
+// Record charges the account with the costs of a consumption and records its details.
+//
+// The costs and the subsidy must be greater or equal zero.
+func (a *Account) Record(c Consumption) error {
+	if err := a.active(); err != nil {
+		return err
+	}
+	if c.Costs < 0 {
 		return fmt.Errorf("price cannot be negative")
 	}
-	e := NewCoffyConsumed(a.id, coffeeType, price)
+	if c.Subsidy < 0 {
+		return fmt.Errorf("subsidy cannot be negative")
+	}
+	e := NewCoffyConsumed(a.id, c.CoffeeType, c.Costs)
+	e.Subsidy = c.Subsidy
+	e.PlanID = c.PlanID
+	e.Period = c.Period
+	e.ReceiptID = c.ReceiptID
+	e.MachineID = c.MachineID
+	e.Beverage = c.Beverage
+	if !c.OccurredOn.IsZero() {
+		e.OccurredOn = c.OccurredOn
+	}
 	if err := a.apply(*e); err != nil {
 		return err
 	}
@@ -107,6 +200,9 @@ func (a *Account) ConsumeN(price float64, coffeeType string, n int) error {
 //
 // Only values greater or equal 0 are allowed. The value for reason can be left empty if not required.
 func (a *Account) Pay(amount float64, reason string) error {
+	if err := a.active(); err != nil {
+		return err
+	}
 	if amount < 0 {
 		return fmt.Errorf("payment amount cannot be negative")
 	}
@@ -121,13 +217,7 @@ func (a *Account) Pay(amount float64, reason string) error {
 // ConsumedTotal returns the total amount of coffee consumed.
 // Only events of type CoffyConsumed are considered.
 func (a *Account) ConsumedTotal() int {
-	consumed := 0
-	for _, e := range a.events {
-		if e.Type() == "CoffyConsumed" {
-			consumed += 1
-		}
-	}
-	return consumed
+	return a.consumed
 }
 
 func (a *Account) ID() string {
@@ -138,10 +228,90 @@ func (a *Account) Owner() string {
 	return a.owner
 }
 
+// Group returns the account group, e.g. 'apprentice', which is used to apply pricing rules.
+// An empty group means the account is not part of any group.
+func (a *Account) Group() string {
+	return a.group
+}
+
+// AssignGroup assigns the account to a group. An empty group removes the account from its current group.
+func (a *Account) AssignGroup(group string) error {
+	e := NewGroupAssigned(a.id, strings.TrimSpace(group))
+	if err := a.apply(*e); err != nil {
+		log.Printf("Error: %v", err)
+		return fmt.Errorf("error assigning group to Account ID '%s'", a.id)
+	}
+	return nil
+}
+
+func (a *Account) Email() string {
+	return a.contact.Email
+}
+
+// Contact returns the contact details and preferences of the account's owner.
+func (a *Account) Contact() ContactDetails {
+	return a.contact
+}
+
+// UpdateContactDetails replaces the contact details of the account.
+//
+// The email address and the preferred language are optional, but must be valid if provided.
+// The language is expected as a short language tag, e.g. 'en' or 'de-CH'.
+func (a *Account) UpdateContactDetails(details ContactDetails) error {
+	if err := validateEmail(details.Email); err != nil {
+		return err
+	}
+	if err := validateLanguage(details.Language); err != nil {
+		return err
+	}
+	e := NewContactDetailsUpdated(a.id, details)
+	if err := a.apply(*e); err != nil {
+		log.Printf("Error: %v", err)
+		return fmt.Errorf("error updating contact details of Account ID '%s'", a.id)
+	}
+	return nil
+}
+
+func validateEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(email); err != nil {
+		return fmt.Errorf("invalid email address '%s'", email)
+	}
+	return nil
+}
+
+func validateLanguage(language string) error {
+	if language == "" {
+		return nil
+	}
+	for _, part := range strings.Split(language, "-") {
+		if len(part) < 2 || len(part) > 8 || strings.IndexFunc(part, func(r rune) bool { return r > unicode.MaxASCII || !unicode.IsLetter(r) }) >= 0 {
+			return fmt.Errorf("invalid language tag '%s'", language)
+		}
+	}
+	return nil
+}
+
 func (a *Account) Balance() float64 {
 	return a.balance
 }
 
+// LastActivity returns the time point of the latest booking on the account or its creation.
+func (a *Account) LastActivity() time.Time {
+	return a.activity
+}
+
+// DebtSince returns the time point since when the account has a negative balance.
+// If the account is not in debt, false is returned.
+func (a *Account) DebtSince() (time.Time, bool) {
+	if a.balance >= 0 {
+		return time.Time{}, false
+	}
+	return a.debtSince, true
+}
+
 func (a *Account) Events() []event.Event {
 	return a.events
 }
@@ -151,7 +321,10 @@ func (a *Account) createAccount(e AccountCreated) error {
 		return fmt.Errorf("Account already exists")
 	}
 	a.owner = e.Owner
+	// owners that provide an email address on creation receive all notifications by default
+	a.contact = ContactDetails{Email: e.Email, Notifications: NotificationOptIns{LowBalance: true, DebtReminder: true}}
 	a.id = e.AggregateID()
+	a.activity = e.Occurred()
 	a.events = append(a.events, e)
 	return nil
 }
@@ -160,20 +333,52 @@ func (a *Account) applyPayment(e IncomingPayment) error {
 	if a.id != e.AggregateID() {
 		return fmt.Errorf("event aggregate id does not match current aggregate")
 	}
-	a.balance += e.Amount
+	a.updateBalance(e.Amount, e.Occurred())
+	a.events = append(a.events, e)
+	return nil
+}
+
+func (a *Account) applyContactDetails(e ContactDetailsUpdated) error {
+	if a.id != e.AggregateID() {
+		return fmt.Errorf("event aggregate id does not match current aggregate")
+	}
+	a.contact = e.Details
 	a.events = append(a.events, e)
 	return nil
 }
 
+func (a *Account) applyGroup(e GroupAssigned) error {
+	if a.id != e.AggregateID() {
+		return fmt.Errorf("event aggregate id does not match current aggregate")
+	}
+	a.group = e.Group
+	a.events = append(a.events, e)
+	return nil
+}
+
+// ContactDetails describe how to reach the person behind an account and what they want to be notified about.
+type ContactDetails struct {
+	Email         string             `json:"email"`    // the owner's email address
+	Language      string             `json:"language"` // the preferred language as language tag, e.g. 'en'
+	Notifications NotificationOptIns `json:"notifications"`
+}
+
+// NotificationOptIns hold the notifications an account owner agreed to receive.
+type NotificationOptIns struct {
+	LowBalance   bool `json:"low_balance"`   // notify when the balance drops below a threshold
+	DebtReminder bool `json:"debt_reminder"` // remind regularly about old debts
+}
+
 type AccountCreated struct {
 	AccountID  string    `json:"accountID"`
 	OccurredOn time.Time `json:"occurredOn"`
 	EventType  string    `json:"eventType"`
 	Owner      string    `json:"owner"`
+	Email      string    `json:"email,omitempty"`
 }
 
-func NewAccountCreated(accountID string, occurredOn time.Time, owner string) *AccountCreated {
-	return &AccountCreated{AccountID: accountID, OccurredOn: occurredOn, EventType: "AccountCreated", Owner: owner}
+func NewAccountCreated(accountID string, occurredOn time.Time, owner string, email string) *AccountCreated {
+	return &AccountCreated{AccountID: accountID, OccurredOn: occurredOn, EventType: "AccountCreated", Owner: owner, Email: email}
 }
 
 func (e AccountCreated) Type() string {
@@ -190,17 +395,24 @@ func (e AccountCreated) AggregateID() string {
 
 // The CoffyConsumed event records a coffee consumption event. Next to the common properties of Event, it
 // also records the coffee type (CoffyType) that has been consumed to increase the transparency and the
-// associated costs (Costs).
+// associated costs (Costs). If a pricing rule applied, the part of the price that has not been charged
+// is recorded as subsidy (Subsidy). Consumptions covered by a subscription plan reference the plan (PlanID).
 type CoffyConsumed struct {
 	AccountID  string    `json:"accountID"`
 	OccurredOn time.Time `json:"occurredOn"`
 	EventType  string    `json:"eventType"`
 	CoffyType  string    `json:"coffyType"`
 	Costs      float64   `json:"costs"`
+	Subsidy    float64   `json:"subsidy,omitempty"`
+	PlanID     string    `json:"planID,omitempty"`
+	Period     string    `json:"period,omitempty"` // the billing period of a cup covered by a plan
+	ReceiptID  string    `json:"receiptID,omitempty"`
+	MachineID  string    `json:"machineID,omitempty"`
+	Beverage   string    `json:"beverage,omitempty"`
 }
 
 func NewCoffyConsumed(accountID string, coffyType string, costs float64) *CoffyConsumed {
-	return &CoffyConsumed{accountID, time.Now(), "CoffyConsumed", coffyType, costs}
+	return &CoffyConsumed{AccountID: accountID, OccurredOn: time.Now(), EventType: "CoffyConsumed", CoffyType: coffyType, Costs: costs}
 }
 
 // The IncomingPayment event records an effort to pay someone's outstanding coffy debts. Next to the common properties
@@ -242,3 +454,52 @@ func (e IncomingPayment) Occurred() time.Time {
 func (e IncomingPayment) Type() string {
 	return e.EventType
 }
+
+// The ContactDetailsUpdated event records a change of the account owner's contact details and preferences.
+// The event always contains the complete ContactDetails, which replace the previous ones.
+type ContactDetailsUpdated struct {
+	AccountID  string         `json:"accountID"`
+	OccurredOn time.Time      `json:"occurredOn"`
+	EventType  string         `json:"eventType"`
+	Details    ContactDetails `json:"details"`
+}
+
+func NewContactDetailsUpdated(accountID string, details ContactDetails) *ContactDetailsUpdated {
+	return &ContactDetailsUpdated{accountID, time.Now(), "ContactDetailsUpdated", details}
+}
+
+func (e ContactDetailsUpdated) AggregateID() string {
+	return e.AccountID
+}
+
+func (e ContactDetailsUpdated) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e ContactDetailsUpdated) Type() string {
+	return e.EventType
+}
+
+// The GroupAssigned event records the assignment of an account to a group, e.g. apprentices or visitors.
+type GroupAssigned struct {
+	AccountID  string    `json:"accountID"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	Group      string    `json:"group"`
+}
+
+func NewGroupAssigned(accountID string, group string) *GroupAssigned {
+	return &GroupAssigned{accountID, time.Now(), "GroupAssigned", group}
+}
+
+func (e GroupAssigned) AggregateID() string {
+	return e.AccountID
+}
+
+func (e GroupAssigned) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e GroupAssigned) Type() string {
+	return e.EventType
+}
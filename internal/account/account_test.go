@@ -1,6 +1,11 @@
 package account
 
-import "testing"
+import (
+	"coffy/internal/storage/storagetest"
+	"slices"
+	"testing"
+	"time"
+)
 
 func TestCoffyConsumed(t *testing.T) {
 	costs := 0.25
@@ -38,7 +43,7 @@ func TestIncomingPayment(t *testing.T) {
 }
 
 func TestAccountCreation(t *testing.T) {
-	a, err := NewAccount("Coffy")
+	a, err := NewAccount("Coffy", "")
 	if err != nil {
 		t.Errorf("Error creating new Account: %s", err.Error())
 		return
@@ -57,7 +62,7 @@ func TestAccountCreation(t *testing.T) {
 }
 
 func TestAccountConsumption(t *testing.T) {
-	a, err := NewAccount("Coffy")
+	a, err := NewAccount("Coffy", "")
 	if err != nil {
 		t.Errorf("Error creating new Account: %s", err.Error())
 	}
@@ -70,7 +75,7 @@ func TestAccountConsumption(t *testing.T) {
 }
 
 func TestAccountConsumptionMalicious(t *testing.T) {
-	a, err := NewAccount("Coffy")
+	a, err := NewAccount("Coffy", "")
 	if err != nil {
 		t.Errorf("Error creating new Account: %s", err.Error())
 	}
@@ -81,7 +86,7 @@ func TestAccountConsumptionMalicious(t *testing.T) {
 }
 
 func TestAccountPayment(t *testing.T) {
-	a, err := NewAccount("Coffy")
+	a, err := NewAccount("Coffy", "")
 	if err != nil {
 		t.Errorf("Error creating new Account: %s", err.Error())
 	}
@@ -96,7 +101,7 @@ func TestAccountPayment(t *testing.T) {
 }
 
 func TestAccountPaymentMalicious(t *testing.T) {
-	a, err := NewAccount("Coffy")
+	a, err := NewAccount("Coffy", "")
 	if err != nil {
 		t.Errorf("Error creating new Account: %s", err.Error())
 	}
@@ -106,3 +111,254 @@ func TestAccountPaymentMalicious(t *testing.T) {
 		t.Errorf("Expected error, got none")
 	}
 }
+
+func TestAccountDebtSince(t *testing.T) {
+	a, err := NewAccount("Coffy", "coffy@example.com")
+	if err != nil {
+		t.Errorf("Error creating new Account: %s", err.Error())
+		return
+	}
+	if a.Email() != "coffy@example.com" {
+		t.Errorf("Email should be 'coffy@example.com', got '%s'", a.Email())
+	}
+	if _, inDebt := a.DebtSince(); inDebt {
+		t.Errorf("New account should not be in debt")
+	}
+	_ = a.Consume(0.25, "coffee cream")
+	since, inDebt := a.DebtSince()
+	if !inDebt {
+		t.Errorf("Account should be in debt")
+		return
+	}
+	_ = a.Consume(0.25, "coffee cream")
+	if later, _ := a.DebtSince(); !later.Equal(since) {
+		t.Errorf("Debt should have started at %v, got %v", since, later)
+	}
+	_ = a.Pay(1.00, "debt balance")
+	if _, inDebt := a.DebtSince(); inDebt {
+		t.Errorf("Account should not be in debt after payment")
+	}
+}
+
+func TestAccountContactDetails(t *testing.T) {
+	a, err := NewAccount("Coffy", "")
+	if err != nil {
+		t.Errorf("Error creating new Account: %s", err.Error())
+		return
+	}
+	a.Clear()
+	details := ContactDetails{Email: "coffy@example.com", Language: "de-CH", Notifications: NotificationOptIns{LowBalance: true}}
+	if err := a.UpdateContactDetails(details); err != nil {
+		t.Errorf("Error updating contact details: %s", err.Error())
+		return
+	}
+	if a.Email() != "coffy@example.com" {
+		t.Errorf("Email should be 'coffy@example.com', got '%s'", a.Email())
+	}
+	if a.Contact().Notifications.DebtReminder {
+		t.Errorf("Debt reminders should not be opted in")
+	}
+	if a.Events()[0].Type() != "ContactDetailsUpdated" {
+		t.Errorf("Event Type should be 'ContactDetailsUpdated', got '%s'", a.Events()[0].Type())
+	}
+}
+
+func TestAccountContactDetailsInvalid(t *testing.T) {
+	a, err := NewAccount("Coffy", "")
+	if err != nil {
+		t.Errorf("Error creating new Account: %s", err.Error())
+		return
+	}
+	if err := a.UpdateContactDetails(ContactDetails{Email: "not an email"}); err == nil {
+		t.Errorf("Expected error for invalid email, got none")
+	}
+	if err := a.UpdateContactDetails(ContactDetails{Language: "e"}); err == nil {
+		t.Errorf("Expected error for invalid language, got none")
+	}
+}
+
+func TestAccountSubsidisedConsumption(t *testing.T) {
+	a, err := NewAccount("Coffy", "")
+	if err != nil {
+		t.Errorf("Error creating new Account: %s", err.Error())
+		return
+	}
+	if err := a.AssignGroup("apprentice"); err != nil {
+		t.Errorf("Error assigning group: %s", err.Error())
+	}
+	if a.Group() != "apprentice" {
+		t.Errorf("Group should be 'apprentice', got '%s'", a.Group())
+	}
+	a.Clear()
+	if err := a.Record(Consumption{CoffeeType: "espresso", Costs: 0.25, Subsidy: 0.25}); err != nil {
+		t.Errorf("Error recording consumption: %s", err.Error())
+		return
+	}
+	if a.Balance() != -0.25 {
+		t.Errorf("Balance should be %f, got %f", -0.25, a.Balance())
+	}
+	consumed := a.Events()[0].(CoffyConsumed)
+	if consumed.Subsidy != 0.25 {
+		t.Errorf("Subsidy should be %f, got %f", 0.25, consumed.Subsidy)
+	}
+	if err := a.Record(Consumption{CoffeeType: "espresso", Costs: 0.25, Subsidy: -1}); err == nil {
+		t.Errorf("Expected error for negative subsidy, got none")
+	}
+}
+
+func TestAccountSubscription(t *testing.T) {
+	a, err := NewAccount("Coffy", "")
+	if err != nil {
+		t.Errorf("Error creating new Account: %s", err.Error())
+		return
+	}
+	period := BillingPeriod(time.Now())
+	if err := a.ChargeFee(20, period); err == nil {
+		t.Errorf("Expected error charging a fee without subscription, got none")
+	}
+	if err := a.Subscribe("plan-1"); err != nil {
+		t.Errorf("Error subscribing: %s", err.Error())
+		return
+	}
+	if err := a.Record(Consumption{CoffeeType: "espresso", PlanID: "plan-1", Period: period}); err != nil {
+		t.Errorf("Error recording consumption: %s", err.Error())
+	}
+	if a.CoveredCups(period) != 1 {
+		t.Errorf("Covered cups should be 1, got %d", a.CoveredCups(period))
+	}
+	if err := a.ChargeFee(20, period); err != nil {
+		t.Errorf("Error charging fee: %s", err.Error())
+	}
+	if a.Balance() != -20 {
+		t.Errorf("Balance should be %f, got %f", -20.0, a.Balance())
+	}
+	if err := a.ChargeFee(20, period); err == nil {
+		t.Errorf("Expected error charging the fee twice, got none")
+	}
+	if err := a.Unsubscribe(); err != nil {
+		t.Errorf("Error unsubscribing: %s", err.Error())
+	}
+	if _, ok := a.Subscription(); ok {
+		t.Errorf("Account should not be subscribed")
+	}
+}
+
+func TestAccountMerge(t *testing.T) {
+	source, _ := NewAccount("Coffy", "")
+	target, _ := NewAccount("Coffy Again", "")
+	_ = source.Pay(5.00, "debt balance")
+	_ = target.Consume(0.25, "coffee cream")
+
+	if err := Merge(source, target); err != nil {
+		t.Errorf("Error merging accounts: %s", err.Error())
+		return
+	}
+	if target.Balance() != 4.75 {
+		t.Errorf("Balance should be %.2f, got %.2f", 4.75, target.Balance())
+	}
+	if source.Balance() != 0 {
+		t.Errorf("Source balance should be 0, got %.2f", source.Balance())
+	}
+	if into, merged := source.MergedInto(); !merged || into != target.ID() {
+		t.Errorf("Source should be merged into '%s', got '%s'", target.ID(), into)
+	}
+	if err := source.Consume(0.25, "coffee cream"); err == nil {
+		t.Errorf("Expected error consuming on a merged account, got none")
+	}
+	if err := Merge(source, target); err == nil {
+		t.Errorf("Expected error merging an account twice, got none")
+	}
+	if err := Merge(target, target); err == nil {
+		t.Errorf("Expected error merging an account into itself, got none")
+	}
+}
+
+func TestAccountingResolveMergeChain(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := NewAccounting(&repo)
+	a, _ := accounting.Create("Coffy", "")
+	b, _ := accounting.Create("Coffy Again", "")
+	c, _ := accounting.Create("Coffy Once More", "")
+
+	if _, err := accounting.Merge(a.ID(), b.ID()); err != nil {
+		t.Fatalf("Error merging accounts: %s", err.Error())
+	}
+	if _, err := accounting.Merge(b.ID(), c.ID()); err != nil {
+		t.Fatalf("Error merging accounts: %s", err.Error())
+	}
+	resolved, err := accounting.Resolve(a.ID())
+	if err != nil {
+		t.Fatalf("Error resolving account: %s", err.Error())
+	}
+	if resolved.ID() != c.ID() {
+		t.Errorf("Account should resolve to '%s', got '%s'", c.ID(), resolved.ID())
+	}
+	if _, err := accounting.Consume(resolved.ID(), []Consumption{{CoffeeType: "coffee cream", Costs: 0.25}}); err != nil {
+		t.Errorf("Error consuming on the resolved account: %s", err.Error())
+	}
+}
+
+func TestAccountQuery(t *testing.T) {
+	alice, _ := NewAccount("Alice", "")
+	bob, _ := NewAccount("bob", "")
+	carol, _ := NewAccount("Carol", "")
+	_ = alice.Consume(0.50, "coffee cream")
+	_ = bob.Pay(2.00, "top up")
+	_ = Merge(carol, bob)
+
+	debtors := 0.0
+	tests := []struct {
+		name  string
+		query Query
+		want  []*Account
+	}{
+		{"default", Query{}, []*Account{alice, bob}},
+		{"owner substring", Query{Owner: "ALI"}, []*Account{alice}},
+		{"merged", Query{Status: StatusMerged}, []*Account{carol}},
+		{"all", Query{Status: StatusAll}, []*Account{alice, bob, carol}},
+		{"debtors", Query{MaxBalance: &debtors, Sort: SortByBalance}, []*Account{alice}},
+		{"balance descending", Query{Sort: SortByBalance, Descending: true}, []*Account{bob, alice}},
+	}
+	for _, tt := range tests {
+		got := make([]*Account, 0)
+		for _, a := range []*Account{carol, bob, alice} {
+			if tt.query.matches(a) {
+				got = append(got, a)
+			}
+		}
+		slices.SortFunc(got, tt.query.compare)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: unexpected result %v", tt.name, got)
+		}
+	}
+
+	if err := (Query{Sort: "color"}).Validate(); err == nil {
+		t.Errorf("Expected error for unknown sort key, got none")
+	}
+	if err := (Query{Status: "deleted"}).Validate(); err == nil {
+		t.Errorf("Expected error for unknown status, got none")
+	}
+}
+
+func TestQueryPosition(t *testing.T) {
+	alice, _ := NewAccount("Alice", "")
+	bob, _ := NewAccount("Bob", "")
+	carol, _ := NewAccount("Carol", "")
+	_ = alice.Pay(1.00, "top up")
+	_ = bob.Pay(2.00, "top up")
+	_ = carol.Pay(3.00, "top up")
+
+	query := Query{Sort: SortByBalance}
+	last := query.Position(bob)
+	// the account the position was taken from changes, the following accounts stay in place
+	_ = bob.Pay(5.00, "top up")
+	if query.Compare(query.Position(alice), last) >= 0 {
+		t.Errorf("Expected alice before the position")
+	}
+	if query.Compare(query.Position(carol), last) <= 0 {
+		t.Errorf("Expected carol after the position")
+	}
+	if query.Compare(query.Position(bob), last) <= 0 {
+		t.Errorf("Expected the changed account after the position")
+	}
+}
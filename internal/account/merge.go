@@ -0,0 +1,95 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrorMerged = errors.New("account has been merged")
+
+// MergedInto returns the ID of the account the current account has been merged into and false,
+// if the account has not been merged.
+func (a *Account) MergedInto() (string, bool) {
+	return a.mergedInto, a.mergedInto != ""
+}
+
+// MergedFrom returns the IDs of all accounts that have been merged into the current account.
+func (a *Account) MergedFrom() []string {
+	return a.mergedFrom
+}
+
+// Merge moves the balance of the source account into the target account and links both accounts.
+//
+// Both accounts record an AccountMerged event. Afterward, the source account only serves as a redirect
+// to the target and cannot be used anymore.
+func Merge(source *Account, target *Account) error {
+	if source.id == target.id {
+		return errors.New("cannot merge an account into itself")
+	}
+	if err := source.active(); err != nil {
+		return err
+	}
+	if err := target.active(); err != nil {
+		return err
+	}
+	now := time.Now()
+	amount := source.balance
+	if err := source.apply(*NewAccountMerged(source.id, source.id, target.id, amount, now)); err != nil {
+		return err
+	}
+	return target.apply(*NewAccountMerged(target.id, source.id, target.id, amount, now))
+}
+
+// active returns an error, if the account has been merged into another account.
+func (a *Account) active() error {
+	if a.mergedInto != "" {
+		return fmt.Errorf("%w into account '%s'", ErrorMerged, a.mergedInto)
+	}
+	return nil
+}
+
+func (a *Account) applyMerged(e AccountMerged) error {
+	if a.id != e.AggregateID() {
+		return fmt.Errorf("event aggregate id does not match current aggregate")
+	}
+	switch a.id {
+	case e.SourceID:
+		a.updateBalance(-e.Amount, e.Occurred())
+		a.mergedInto = e.TargetID
+	case e.TargetID:
+		a.updateBalance(e.Amount, e.Occurred())
+		a.mergedFrom = append(a.mergedFrom, e.SourceID)
+	default:
+		return fmt.Errorf("account is neither source nor target of the merge")
+	}
+	a.events = append(a.events, e)
+	return nil
+}
+
+// The AccountMerged event records the merge of a duplicate account (SourceID) into another account (TargetID).
+// The event is recorded on both accounts and contains the balance (Amount) that has been moved.
+type AccountMerged struct {
+	AccountID  string    `json:"accountID"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	SourceID   string    `json:"sourceID"`
+	TargetID   string    `json:"targetID"`
+	Amount     float64   `json:"amount"`
+}
+
+func NewAccountMerged(accountID string, sourceID string, targetID string, amount float64, occurredOn time.Time) *AccountMerged {
+	return &AccountMerged{accountID, occurredOn, "AccountMerged", sourceID, targetID, amount}
+}
+
+func (e AccountMerged) AggregateID() string {
+	return e.AccountID
+}
+
+func (e AccountMerged) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e AccountMerged) Type() string {
+	return e.EventType
+}
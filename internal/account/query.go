@@ -0,0 +1,142 @@
+package account
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Status filters accounts by their state.
+type Status string
+
+const (
+	StatusActive Status = "active" // accounts in use
+	StatusMerged Status = "merged" // accounts that have been merged into another account
+	StatusAll    Status = "all"
+)
+
+// SortKey defines the order of accounts in search results.
+type SortKey string
+
+const (
+	SortByOwner        SortKey = "owner"
+	SortByBalance      SortKey = "balance"
+	SortByLastActivity SortKey = "last_activity"
+)
+
+// Query describes a search for accounts. All criteria are optional, the zero value finds all
+// active accounts, sorted by owner.
+type Query struct {
+	Owner      string   // a case-insensitive substring of the owner
+	Status     Status   // the state of the accounts, default is StatusActive
+	MinBalance *float64 // the minimal balance (inclusive)
+	MaxBalance *float64 // the maximal balance (inclusive), e.g. below zero for debtors only
+	Sort       SortKey  // the order of the results, default is SortByOwner
+	Descending bool     // reverses the order of the results
+}
+
+// Validate checks the query for unknown values.
+func (q Query) Validate() error {
+	switch q.Status {
+	case "", StatusActive, StatusMerged, StatusAll:
+	default:
+		return fmt.Errorf("unknown status '%s'", q.Status)
+	}
+	switch q.Sort {
+	case "", SortByOwner, SortByBalance, SortByLastActivity:
+	default:
+		return fmt.Errorf("unknown sort key '%s'", q.Sort)
+	}
+	if q.MinBalance != nil && q.MaxBalance != nil && *q.MinBalance > *q.MaxBalance {
+		return fmt.Errorf("minimal balance is greater than the maximal balance")
+	}
+	return nil
+}
+
+func (q Query) matches(a *Account) bool {
+	_, merged := a.MergedInto()
+	switch q.Status {
+	case "", StatusActive:
+		if merged {
+			return false
+		}
+	case StatusMerged:
+		if !merged {
+			return false
+		}
+	}
+	if q.Owner != "" && !strings.Contains(strings.ToLower(a.owner), strings.ToLower(q.Owner)) {
+		return false
+	}
+	if q.MinBalance != nil && a.balance < *q.MinBalance {
+		return false
+	}
+	if q.MaxBalance != nil && a.balance > *q.MaxBalance {
+		return false
+	}
+	return true
+}
+
+// Position is the place of an account in the order of a query: the value it is sorted by and its ID.
+// It allows continuing a search after an account, even if the account has changed in between.
+type Position struct {
+	Owner    string    `json:"owner,omitempty"`
+	Balance  float64   `json:"balance,omitempty"`
+	Activity time.Time `json:"activity"`
+	ID       string    `json:"id"`
+}
+
+// Position returns the position of the account in the order of the query.
+func (q Query) Position(a *Account) Position {
+	switch q.Sort {
+	case SortByBalance:
+		return Position{Balance: a.balance, ID: a.id}
+	case SortByLastActivity:
+		return Position{Activity: a.activity, ID: a.id}
+	default:
+		return Position{Owner: strings.ToLower(a.owner), ID: a.id}
+	}
+}
+
+// Compare orders two positions of the query.
+func (q Query) Compare(a Position, b Position) int {
+	var c int
+	switch q.Sort {
+	case SortByBalance:
+		c = cmp.Compare(a.Balance, b.Balance)
+	case SortByLastActivity:
+		c = a.Activity.Compare(b.Activity)
+	default:
+		c = cmp.Compare(a.Owner, b.Owner)
+	}
+	if q.Descending {
+		c = -c
+	}
+	// the ID keeps the order stable for accounts with equal sort values
+	return cmp.Or(c, cmp.Compare(a.ID, b.ID))
+}
+
+func (q Query) compare(a *Account, b *Account) int {
+	return q.Compare(q.Position(a), q.Position(b))
+}
+
+// Search returns all accounts matching the query in the requested order.
+func (a *Accounting) Search(q Query) ([]Account, error) {
+	if err := q.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	accounts, err := a.all()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Account, 0, len(accounts))
+	for _, acc := range accounts {
+		if q.matches(&acc) {
+			result = append(result, acc)
+		}
+	}
+	slices.SortFunc(result, func(x Account, y Account) int { return q.compare(&x, &y) })
+	return result, nil
+}
@@ -6,18 +6,32 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 )
 
 var ErrorNotFound = errors.New("account not found")
+var ErrorInvalidProperty = errors.New("invalid property")
+
+// A BalanceListener gets informed about an account's balance change, after the
+// change has been saved. The previous balance is provided to detect trends.
+type BalanceListener func(account *Account, previous float64)
 
 type Accounting struct {
-	repo storage.EventRepository
+	repo      storage.EventRepository
+	listeners []BalanceListener
+}
+
+// OnBalanceChange registers a BalanceListener that is called after every consumption.
+func (a *Accounting) OnBalanceChange(listener BalanceListener) {
+	a.listeners = append(a.listeners, listener)
 }
 
-func (a *Accounting) Create(owner string) (*Account, error) {
-	account, err := NewAccount(owner)
+func (a *Accounting) Create(owner string, email string) (*Account, error) {
+	account, err := NewAccount(owner, email)
 	if err != nil {
-		return nil, fmt.Errorf("error creating account: %w", err)
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
 	}
 	entries, err := a.convertAll(account.events)
 	if err != nil {
@@ -29,22 +43,186 @@ func (a *Accounting) Create(owner string) (*Account, error) {
 	return account, nil
 }
 
-func (a *Accounting) Consume(accountId string, costs float64, coffee string, n int) error {
-	account, err := a.Find(accountId)
+// Consume charges an account with all consumptions, which are saved together with the related
+// event entries of other aggregates, e.g. the receipt covering the consumptions.
+// Consumptions of merged accounts are charged to the account they have been merged into.
+func (a *Accounting) Consume(accountId string, consumptions []Consumption, related ...storage.EventEntry) (*Account, error) {
+	account, err := a.Resolve(accountId)
 	if err != nil {
-		return fmt.Errorf("error finding account: %w", err)
+		return nil, fmt.Errorf("error finding account: %w", err)
 	}
 	account.Clear()
-	for range n {
-		if err := account.Consume(costs, coffee); err != nil {
-			return fmt.Errorf("error consuming costs: %w", err)
+	previous := account.Balance()
+	for _, c := range consumptions {
+		if err := account.Record(c); err != nil {
+			return nil, fmt.Errorf("error consuming costs: %w", err)
+		}
+	}
+	if err := a.save(account, related...); err != nil {
+		return nil, err
+	}
+	for _, listener := range a.listeners {
+		listener(account, previous)
+	}
+	return account, nil
+}
+
+// ConsumeAll charges several accounts with their consumptions, which are all saved together with the
+// related event entries of other aggregates. Either all consumptions are recorded or none.
+//
+// The consumptions are imported from records of the past, e.g. tally sheets, so no BalanceListener is
+// called for them.
+func (a *Accounting) ConsumeAll(consumptions map[string][]Consumption, related ...storage.EventEntry) error {
+	ids := slices.Sorted(maps.Keys(consumptions))
+	entries := related
+	for _, id := range ids {
+		account, err := a.Resolve(id)
+		if err != nil {
+			return fmt.Errorf("error finding account '%s': %w", id, err)
+		}
+		account.Clear()
+		for _, c := range consumptions[id] {
+			if err := account.Record(c); err != nil {
+				return fmt.Errorf("error consuming costs: %w", err)
+			}
+		}
+		converted, err := a.convertAll(account.Events())
+		if err != nil {
+			return fmt.Errorf("error converting events: %w", err)
+		}
+		entries = append(entries, converted...)
+	}
+	if err := a.repo.SaveAll(entries); err != nil {
+		return fmt.Errorf("error saving events: %w", err)
+	}
+	return nil
+}
+
+// Pay deposits an amount with a reason to an account.
+//
+// Related event entries of other aggregates are saved in the same transaction as the payment,
+// e.g. to record the redemption of a voucher that was used to pay.
+func (a *Accounting) Pay(accountID string, amount float64, reason string, related ...storage.EventEntry) (*Account, error) {
+	return a.modify(accountID, func(account *Account) error { return account.Pay(amount, reason) }, related...)
+}
+
+// UpdateContact replaces the contact details and preferences of an account.
+func (a *Accounting) UpdateContact(accountID string, details ContactDetails) (*Account, error) {
+	return a.modify(accountID, func(account *Account) error { return account.UpdateContactDetails(details) })
+}
+
+// AssignGroup assigns an account to a group, which is considered by pricing rules.
+func (a *Accounting) AssignGroup(accountID string, group string) (*Account, error) {
+	return a.modify(accountID, func(account *Account) error { return account.AssignGroup(group) })
+}
+
+// Subscribe subscribes an account to a flat-rate plan.
+func (a *Accounting) Subscribe(accountID string, planID string) (*Account, error) {
+	return a.modify(accountID, func(account *Account) error { return account.Subscribe(planID) })
+}
+
+// Unsubscribe ends the subscription of an account.
+func (a *Accounting) Unsubscribe(accountID string) (*Account, error) {
+	account, err := a.Resolve(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := account.Subscription(); !ok {
+		return nil, ErrorNotSubscribed
+	}
+	return a.modify(accountID, func(account *Account) error { return account.Unsubscribe() })
+}
+
+// ChargeFee charges the monthly subscription fee of an account for the billing period.
+func (a *Accounting) ChargeFee(accountID string, fee float64, period string) (*Account, error) {
+	return a.modify(accountID, func(account *Account) error { return account.ChargeFee(fee, period) })
+}
+
+// Consumptions returns all recorded consumptions of all accounts that occurred in the
+// half-open interval [from, to).
+func (a *Accounting) Consumptions(from time.Time, to time.Time) ([]CoffyConsumed, error) {
+	query, err := a.repo.FetchByEventType("CoffyConsumed")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load consumptions: %w", err)
+	}
+	consumptions := make([]CoffyConsumed, 0)
+	for _, entry := range query {
+		if entry.Date.Before(from) || !entry.Date.Before(to) {
+			continue
+		}
+		e := CoffyConsumed{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as CoffyConsumed: %w", err)
 		}
+		consumptions = append(consumptions, e)
 	}
+	return consumptions, nil
+}
+
+// Payments returns all payments of all accounts in the half-open time range [from, to).
+func (a *Accounting) Payments(from time.Time, to time.Time) ([]IncomingPayment, error) {
+	query, err := a.repo.FetchByEventType("IncomingPayment")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load payments: %w", err)
+	}
+	payments := make([]IncomingPayment, 0)
+	for _, entry := range query {
+		if entry.Date.Before(from) || !entry.Date.Before(to) {
+			continue
+		}
+		e := IncomingPayment{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as IncomingPayment: %w", err)
+		}
+		payments = append(payments, e)
+	}
+	return payments, nil
+}
+
+// Fees returns the subscription fees of all accounts that have been charged for the billing periods
+// in the closed range [first, last].
+func (a *Accounting) Fees(first string, last string) ([]SubscriptionFeeCharged, error) {
+	query, err := a.repo.FetchByEventType("SubscriptionFeeCharged")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscription fees: %w", err)
+	}
+	fees := make([]SubscriptionFeeCharged, 0)
+	for _, entry := range query {
+		e := SubscriptionFeeCharged{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as SubscriptionFeeCharged: %w", err)
+		}
+		if e.Period >= first && e.Period <= last {
+			fees = append(fees, e)
+		}
+	}
+	return fees, nil
+}
+
+// modify resolves an account, applies the change and saves the resulting events together
+// with the related entries of other aggregates.
+func (a *Accounting) modify(accountID string, change func(*Account) error, related ...storage.EventEntry) (*Account, error) {
+	account, err := a.Resolve(accountID)
+	if err != nil {
+		return nil, err
+	}
+	account.Clear()
+	if err := change(account); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	if err := a.save(account, related...); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// save persists all uncommitted events of the account together with the related entries.
+func (a *Accounting) save(account *Account, related ...storage.EventEntry) error {
 	entries, err := a.convertAll(account.Events())
 	if err != nil {
 		return fmt.Errorf("error converting events: %w", err)
 	}
-	if err = a.repo.SaveAll(entries); err != nil {
+	if err = a.repo.SaveAll(append(related, entries...)); err != nil {
 		return fmt.Errorf("error saving events: %w", err)
 	}
 	return nil
@@ -63,6 +241,68 @@ func (a *Accounting) convertAll(events []event.Event) ([]storage.EventEntry, err
 }
 
 func (a *Accounting) Find(accountID string) (*Account, error) {
+	events, err := a.loadEvents(accountID)
+	if err != nil {
+		return nil, err
+	}
+	acc := &Account{}
+	for _, e := range events {
+		if err := acc.apply(e); err != nil {
+			return nil, fmt.Errorf("failed to apply event: %w", err)
+		}
+	}
+	return acc, nil
+}
+
+// Resolve finds an account and follows merges, so the account that is actually in use is returned.
+// Merge chains (A into B, then B into C) are followed to their end.
+func (a *Accounting) Resolve(accountID string) (*Account, error) {
+	visited := map[string]bool{}
+	for {
+		if visited[accountID] {
+			return nil, fmt.Errorf("merge cycle detected at account '%s'", accountID)
+		}
+		visited[accountID] = true
+		acc, err := a.Find(accountID)
+		if err != nil {
+			return nil, err
+		}
+		target, merged := acc.MergedInto()
+		if !merged {
+			return acc, nil
+		}
+		accountID = target
+	}
+}
+
+// Merge moves the balance and history of a duplicate source account into the target account.
+//
+// The source account is kept as a redirect to the target account.
+func (a *Accounting) Merge(sourceID string, targetID string) (*Account, error) {
+	source, err := a.Find(sourceID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := a.Find(targetID)
+	if err != nil {
+		return nil, err
+	}
+	source.Clear()
+	target.Clear()
+	if err := Merge(source, target); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	sourceEntries, err := a.convertAll(source.Events())
+	if err != nil {
+		return nil, fmt.Errorf("error converting events: %w", err)
+	}
+	if err := a.save(target, sourceEntries...); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+func (a *Accounting) loadEvents(accountID string) ([]event.Event, error) {
 	query, err := a.repo.LoadAll(accountID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load account: %w", err)
@@ -78,16 +318,16 @@ func (a *Accounting) Find(accountID string) (*Account, error) {
 		}
 		events = append(events, evnt)
 	}
-	acc := &Account{}
-	for _, e := range events {
-		if err := acc.apply(e); err != nil {
-			return nil, fmt.Errorf("failed to apply event: %w", err)
-		}
-	}
-	return acc, nil
+	return events, nil
 }
 
+// ListAll returns all accounts in use. Accounts that have been merged into other accounts are omitted.
 func (a *Accounting) ListAll() ([]Account, error) {
+	return a.Search(Query{Status: StatusActive})
+}
+
+// all returns every account, including merged ones, in order of creation.
+func (a *Accounting) all() ([]Account, error) {
 	query, err := a.repo.FetchByEventType("AccountCreated")
 	if err != nil {
 		return nil, fmt.Errorf("failed to load accounts: %w", err)
@@ -123,6 +363,42 @@ func convert(entry storage.EventEntry) (event.Event, error) {
 			return nil, err
 		}
 		return evnt, nil
+	case "ContactDetailsUpdated":
+		evnt, err := toContactDetailsUpdated(entry)
+		if err != nil {
+			return nil, err
+		}
+		return evnt, nil
+	case "GroupAssigned":
+		evnt, err := toGroupAssigned(entry)
+		if err != nil {
+			return nil, err
+		}
+		return evnt, nil
+	case "Subscribed":
+		evnt, err := toSubscribed(entry)
+		if err != nil {
+			return nil, err
+		}
+		return evnt, nil
+	case "Unsubscribed":
+		evnt, err := toUnsubscribed(entry)
+		if err != nil {
+			return nil, err
+		}
+		return evnt, nil
+	case "SubscriptionFeeCharged":
+		evnt, err := toFeeCharged(entry)
+		if err != nil {
+			return nil, err
+		}
+		return evnt, nil
+	case "AccountMerged":
+		evnt, err := toMerged(entry)
+		if err != nil {
+			return nil, err
+		}
+		return evnt, nil
 	default:
 		return nil, fmt.Errorf("unknown event type: %s", entry.EventType)
 	}
@@ -148,6 +424,24 @@ func toEventEntry(event event.Event) (storage.EventEntry, error) {
 			return storage.EventEntry{}, err
 		}
 		return storage.EventEntry{AggregateID: t.AccountID, Date: t.OccurredOn, EventType: t.EventType, EventData: data}, nil
+	case ContactDetailsUpdated:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: t.AccountID, Date: t.OccurredOn, EventType: t.EventType, EventData: data}, nil
+	case GroupAssigned:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: t.AccountID, Date: t.OccurredOn, EventType: t.EventType, EventData: data}, nil
+	case Subscribed, Unsubscribed, SubscriptionFeeCharged, AccountMerged:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: t.AggregateID(), Date: t.Occurred(), EventType: t.Type(), EventData: data}, nil
 	default:
 		return storage.EventEntry{}, errors.New("unknown event type")
 	}
@@ -177,6 +471,54 @@ func toConsume(entry storage.EventEntry) (event.Event, error) {
 	return e, nil
 }
 
+func toContactDetailsUpdated(entry storage.EventEntry) (event.Event, error) {
+	e := ContactDetailsUpdated{}
+	if err := json.Unmarshal(entry.EventData, &e); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data as ContactDetailsUpdated: %w", err)
+	}
+	return e, nil
+}
+
+func toGroupAssigned(entry storage.EventEntry) (event.Event, error) {
+	e := GroupAssigned{}
+	if err := json.Unmarshal(entry.EventData, &e); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data as GroupAssigned: %w", err)
+	}
+	return e, nil
+}
+
+func toSubscribed(entry storage.EventEntry) (event.Event, error) {
+	e := Subscribed{}
+	if err := json.Unmarshal(entry.EventData, &e); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data as Subscribed: %w", err)
+	}
+	return e, nil
+}
+
+func toUnsubscribed(entry storage.EventEntry) (event.Event, error) {
+	e := Unsubscribed{}
+	if err := json.Unmarshal(entry.EventData, &e); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data as Unsubscribed: %w", err)
+	}
+	return e, nil
+}
+
+func toFeeCharged(entry storage.EventEntry) (event.Event, error) {
+	e := SubscriptionFeeCharged{}
+	if err := json.Unmarshal(entry.EventData, &e); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data as SubscriptionFeeCharged: %w", err)
+	}
+	return e, nil
+}
+
+func toMerged(entry storage.EventEntry) (event.Event, error) {
+	e := AccountMerged{}
+	if err := json.Unmarshal(entry.EventData, &e); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data as AccountMerged: %w", err)
+	}
+	return e, nil
+}
+
 func NewAccounting(store *storage.EventRepository) *Accounting {
 	service := &Accounting{}
 	service.repo = *store
@@ -0,0 +1,121 @@
+package account
+
+import (
+	"coffy/internal/event"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// StatementEntry is a single booking on an account statement.
+type StatementEntry struct {
+	Date        time.Time `json:"date"`
+	AccountID   string    `json:"account_id"` // the account the booking was recorded on, differs for merged accounts
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	Amount      float64   `json:"amount"`               // positive for deposits, negative for charges
+	Balance     float64   `json:"balance"`              // the running balance after the booking
+	ReceiptID   string    `json:"receipt_id,omitempty"` // the receipt covering a consumption
+}
+
+// Statement lists all bookings of an account in chronological order.
+//
+// The history of accounts that have been merged into the account is included, so the running balance
+// of the last entry equals the current balance of the account.
+func (a *Accounting) Statement(accountID string) ([]StatementEntry, error) {
+	acc, err := a.Resolve(accountID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := a.history(acc.ID())
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]StatementEntry, 0)
+	for _, e := range events {
+		if entry, ok := toStatementEntry(e); ok {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date.Before(entries[j].Date) })
+	balance := 0.0
+	for i := range entries {
+		balance += entries[i].Amount
+		entries[i].Balance = balance
+	}
+	return entries, nil
+}
+
+// History returns all consumptions of an account in chronological order, including the consumptions
+// of accounts that have been merged into it.
+func (a *Accounting) History(accountID string) ([]CoffyConsumed, error) {
+	acc, err := a.Resolve(accountID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := a.history(acc.ID())
+	if err != nil {
+		return nil, err
+	}
+	consumptions := make([]CoffyConsumed, 0)
+	for _, e := range events {
+		if c, ok := e.(CoffyConsumed); ok {
+			consumptions = append(consumptions, c)
+		}
+	}
+	sort.SliceStable(consumptions, func(i, j int) bool { return consumptions[i].OccurredOn.Before(consumptions[j].OccurredOn) })
+	return consumptions, nil
+}
+
+// history collects the events of an account and of all accounts merged into it.
+func (a *Accounting) history(accountID string) ([]event.Event, error) {
+	events, err := a.loadEvents(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history of account '%s': %w", accountID, err)
+	}
+	all := make([]event.Event, 0, len(events))
+	for _, e := range events {
+		merged, ok := e.(AccountMerged)
+		if ok && merged.TargetID == accountID {
+			history, err := a.history(merged.SourceID)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, history...)
+		}
+		all = append(all, e)
+	}
+	return all, nil
+}
+
+// toStatementEntry converts an event into a statement entry and returns false, if the event is not
+// relevant for the statement.
+func toStatementEntry(e event.Event) (StatementEntry, bool) {
+	entry := StatementEntry{Date: e.Occurred(), AccountID: e.AggregateID(), Type: e.Type()}
+	switch t := e.(type) {
+	case AccountCreated:
+		entry.Description = fmt.Sprintf("account of '%s' created", t.Owner)
+	case CoffyConsumed:
+		entry.Amount = -t.Costs
+		entry.Description = fmt.Sprintf("consumption of '%s'", t.CoffyType)
+		if t.Beverage != "" {
+			entry.Description = fmt.Sprintf("consumption of '%s' (%s)", t.Beverage, t.CoffyType)
+		}
+		entry.ReceiptID = t.ReceiptID
+	case IncomingPayment:
+		entry.Amount = t.Amount
+		entry.Description = t.Reason
+	case SubscriptionFeeCharged:
+		entry.Amount = -t.Amount
+		entry.Description = fmt.Sprintf("subscription fee for %s", t.Period)
+	case AccountMerged:
+		// the balance transfer is represented by the merged history itself
+		if t.AccountID == t.SourceID {
+			return entry, false
+		}
+		entry.Description = fmt.Sprintf("merged account '%s' with a balance of %.2f", t.SourceID, t.Amount)
+	default:
+		return entry, false
+	}
+	return entry, true
+}
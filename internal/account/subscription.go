@@ -0,0 +1,166 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrorNotSubscribed = errors.New("account has no subscription")
+
+// Subscription references the flat-rate plan an account is subscribed to.
+type Subscription struct {
+	PlanID string
+	Since  time.Time
+}
+
+// BillingPeriod returns the name of the monthly billing period that starts at the given billing instant,
+// e.g. '2025-01'.
+func BillingPeriod(start time.Time) string {
+	return start.Format("2006-01")
+}
+
+// Subscription returns the current subscription of the account and false, if there is none.
+func (a *Account) Subscription() (Subscription, bool) {
+	if a.plan == nil {
+		return Subscription{}, false
+	}
+	return *a.plan, true
+}
+
+// CoveredCups returns the number of cups covered by a subscription in the billing period.
+func (a *Account) CoveredCups(period string) int {
+	return a.covered[period]
+}
+
+// Billed reports whether the subscription fee for the billing period has been charged.
+func (a *Account) Billed(period string) bool {
+	return a.billed >= period
+}
+
+// Subscribe subscribes the account to a flat-rate plan. An existing subscription is replaced.
+func (a *Account) Subscribe(planID string) error {
+	if planID == "" {
+		return errors.New("plan id must not be empty")
+	}
+	e := &Subscribed{AccountID: a.id, OccurredOn: time.Now(), EventType: "Subscribed", PlanID: planID}
+	return a.apply(*e)
+}
+
+// Unsubscribe ends the current subscription of the account.
+func (a *Account) Unsubscribe() error {
+	if a.plan == nil {
+		return ErrorNotSubscribed
+	}
+	e := &Unsubscribed{AccountID: a.id, OccurredOn: time.Now(), EventType: "Unsubscribed", PlanID: a.plan.PlanID}
+	return a.apply(*e)
+}
+
+// ChargeFee charges the monthly subscription fee for the billing period.
+//
+// The fee is charged only once per billing period.
+func (a *Account) ChargeFee(fee float64, period string) error {
+	if a.plan == nil {
+		return ErrorNotSubscribed
+	}
+	if fee < 0 {
+		return fmt.Errorf("fee cannot be negative")
+	}
+	if a.Billed(period) {
+		return fmt.Errorf("fee for period %s already charged", period)
+	}
+	e := &SubscriptionFeeCharged{AccountID: a.id, OccurredOn: time.Now(), EventType: "SubscriptionFeeCharged",
+		PlanID: a.plan.PlanID, Period: period, Amount: fee}
+	return a.apply(*e)
+}
+
+func (a *Account) applySubscribed(e Subscribed) error {
+	if a.id != e.AggregateID() {
+		return fmt.Errorf("event aggregate id does not match current aggregate")
+	}
+	a.plan = &Subscription{PlanID: e.PlanID, Since: e.OccurredOn}
+	a.events = append(a.events, e)
+	return nil
+}
+
+func (a *Account) applyUnsubscribed(e Unsubscribed) error {
+	if a.id != e.AggregateID() {
+		return fmt.Errorf("event aggregate id does not match current aggregate")
+	}
+	a.plan = nil
+	a.events = append(a.events, e)
+	return nil
+}
+
+func (a *Account) applyFeeCharged(e SubscriptionFeeCharged) error {
+	if a.id != e.AggregateID() {
+		return fmt.Errorf("event aggregate id does not match current aggregate")
+	}
+	a.updateBalance(-e.Amount, e.Occurred())
+	a.billed = e.Period
+	a.events = append(a.events, e)
+	return nil
+}
+
+// The Subscribed event records the subscription of an account to a flat-rate plan.
+type Subscribed struct {
+	AccountID  string    `json:"accountID"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	PlanID     string    `json:"planID"`
+}
+
+func (e Subscribed) AggregateID() string {
+	return e.AccountID
+}
+
+func (e Subscribed) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e Subscribed) Type() string {
+	return e.EventType
+}
+
+// The Unsubscribed event records the end of an account's subscription.
+type Unsubscribed struct {
+	AccountID  string    `json:"accountID"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	PlanID     string    `json:"planID"`
+}
+
+func (e Unsubscribed) AggregateID() string {
+	return e.AccountID
+}
+
+func (e Unsubscribed) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e Unsubscribed) Type() string {
+	return e.EventType
+}
+
+// The SubscriptionFeeCharged event records the monthly fee (Amount) of a subscription plan that has been
+// charged to an account for a billing period (Period), e.g. '2025-01'.
+type SubscriptionFeeCharged struct {
+	AccountID  string    `json:"accountID"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	PlanID     string    `json:"planID"`
+	Period     string    `json:"period"`
+	Amount     float64   `json:"amount"`
+}
+
+func (e SubscriptionFeeCharged) AggregateID() string {
+	return e.AccountID
+}
+
+func (e SubscriptionFeeCharged) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e SubscriptionFeeCharged) Type() string {
+	return e.EventType
+}
@@ -3,21 +3,33 @@ package api
 import (
 	"coffy/internal/account"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// GetAccounts returns all available account IDs.
+// GetAccounts returns the accounts matching the query, one page at a time.
 //
 //	@Summary		requests existing accounts
 //	@Schemes		http
 //	@ID				get-accounts
-//	@Description	Request a list of all accounts.
+//	@Description	Request a page of accounts, optionally filtered and sorted.
 //	@Tags			accounts
+//	@Param			owner		query	string	false	"case-insensitive substring of the owner"
+//	@Param			status		query	string	false	"active (default), merged or all"
+//	@Param			min_balance	query	number	false	"minimal balance (inclusive)"
+//	@Param			max_balance	query	number	false	"maximal balance (inclusive), e.g. -0.01 for debtors only"
+//	@Param			sort		query	string	false	"owner (default), balance or last_activity"
+//	@Param			order		query	string	false	"asc (default) or desc"
+//	@Param			cursor		query	string	false	"cursor of the next page from the previous response"
+//	@Param			limit		query	int		false	"page size, 50 by default, 200 at most"
 //	@Produce		json
-//	@Success		200	{array}	AccountAlias
+//	@Success		200	{object}	Page[AccountAlias]
+//	@Failure		400	{ object }	map[string]string
 //	@Router			/accounts [get]
 func GetAccounts(service *account.Accounting) func(*gin.Context) {
 	if service == nil {
@@ -27,25 +39,82 @@ func GetAccounts(service *account.Accounting) func(*gin.Context) {
 		}
 	}
 	return func(c *gin.Context) {
-		accounts, err := service.ListAll()
+		query, err := parseAccountQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		pageReq, err := parsePageRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		accounts, err := service.Search(query)
 		if err != nil {
 			log.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{})
+			switch {
+			case errors.Is(err, account.ErrorInvalidProperty):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
 			return
 		}
-		alias := make([]AccountAlias, 0)
-		for _, a := range accounts {
+		page, err := paginate(accounts, func(a account.Account) account.Position { return query.Position(&a) }, query.Compare, pageReq)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		alias := Page[AccountAlias]{Items: make([]AccountAlias, 0, len(page.Items)), Total: page.Total, NextCursor: page.NextCursor}
+		for _, a := range page.Items {
 			entry, err := convertAccount(&a)
 			if err != nil {
 				log.Println(err)
 				c.JSON(http.StatusInternalServerError, gin.H{})
+				return
 			}
-			alias = append(alias, entry)
+			alias.Items = append(alias.Items, entry)
 		}
 		c.JSON(http.StatusOK, alias)
 	}
 }
 
+// parseAccountQuery reads the account search criteria from the query parameters.
+func parseAccountQuery(c *gin.Context) (account.Query, error) {
+	q := account.Query{
+		Owner:  c.Query("owner"),
+		Status: account.Status(c.Query("status")),
+		Sort:   account.SortKey(c.Query("sort")),
+	}
+	switch c.Query("order") {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		return account.Query{}, errors.New("order must be 'asc' or 'desc'")
+	}
+	var err error
+	if q.MinBalance, err = parseOptionalFloat(c, "min_balance"); err != nil {
+		return account.Query{}, err
+	}
+	if q.MaxBalance, err = parseOptionalFloat(c, "max_balance"); err != nil {
+		return account.Query{}, err
+	}
+	return q, nil
+}
+
+func parseOptionalFloat(c *gin.Context, key string) (*float64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", key)
+	}
+	return &f, nil
+}
+
 // GetAccountById returns the account associated with the provided ID.
 //
 //	@Summary		access account info by ID
@@ -56,6 +125,8 @@ func GetAccounts(service *account.Accounting) func(*gin.Context) {
 //	@Param			id	path	string	true	"account ID"
 //	@Produce		json
 //	@Success		200	{object}	AccountAlias
+//	@Success		301	"the account has been merged, the location header points to the target account"
+//	@Failure		404	{ object }	map[string]string
 //	@Router			/accounts/{id} [get]
 func GetAccountById(service *account.Accounting) func(*gin.Context) {
 	if service == nil {
@@ -69,7 +140,17 @@ func GetAccountById(service *account.Accounting) func(*gin.Context) {
 		result, err := service.Find(id)
 		if err != nil {
 			log.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{})
+			switch {
+			case errors.Is(err, account.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		// merged accounts only redirect to the account they have been merged into
+		if target, merged := result.MergedInto(); merged {
+			c.Redirect(http.StatusMovedPermanently, strings.TrimSuffix(c.Request.URL.Path, id)+target)
 			return
 		}
 		alias, err := convertAccount(result)
@@ -117,10 +198,15 @@ func CreateAccount(service *account.Accounting) func(*gin.Context) {
 			return
 		}
 
-		acc, err := service.Create(request.Owner)
+		acc, err := service.Create(request.Owner, strings.TrimSpace(request.Email))
 		if err != nil {
 			log.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{})
+			switch {
+			case errors.Is(err, account.ErrorInvalidProperty):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
 			return
 		}
 
@@ -135,23 +221,254 @@ func CreateAccount(service *account.Accounting) func(*gin.Context) {
 	}
 }
 
+// PatchAccountContact updates the contact details and preferences of an account.
+//
+//	@Summary		changes the contact details of an account
+//	@Schemes		http
+//	@Description	Replaces the contact details and notification preferences of an account.
+//	@ID				change-account-contact
+//	@Tags			accounts
+//	@Param			request	body	ContactUpdateRequest	true	"contact details update request"
+//	@Param			id		path	string					true	"account ID"
+//	@Produce		json
+//	@Success		200	{object}	AccountAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/accounts/{id}/contact [patch]
+func PatchAccountContact(service *account.Accounting) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		var request ContactUpdateRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+			return
+		}
+		acc, err := service.UpdateContact(c.Param("id"), account.ContactDetails{
+			Email:    strings.TrimSpace(request.Email),
+			Language: strings.TrimSpace(request.Language),
+			Notifications: account.NotificationOptIns{
+				LowBalance:   request.Notifications.LowBalance,
+				DebtReminder: request.Notifications.DebtReminder,
+			},
+		})
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, account.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			case errors.Is(err, account.ErrorInvalidProperty):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		alias, err := convertAccount(acc)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, alias)
+	}
+}
+
+// PatchAccountGroup assigns an account to a group, e.g. apprentices that get their coffee subsidised.
+//
+//	@Summary		changes the group of an account
+//	@Schemes		http
+//	@Description	Assigns an account to a group, which is considered by pricing rules. An empty group removes the assignment.
+//	@ID				change-account-group
+//	@Tags			accounts
+//	@Param			request	body	GroupUpdateRequest	true	"group update request"
+//	@Param			id		path	string				true	"account ID"
+//	@Produce		json
+//	@Success		200	{object}	AccountAlias
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/accounts/{id}/group [patch]
+func PatchAccountGroup(service *account.Accounting) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		var request GroupUpdateRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+			return
+		}
+		acc, err := service.AssignGroup(c.Param("id"), request.Group)
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, account.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		alias, err := convertAccount(acc)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, alias)
+	}
+}
+
+// MergeAccount merges a duplicate account into the account with the given ID.
+//
+//	@Summary		merge a duplicate account
+//	@Schemes		http
+//	@Description	Moves the balance and history of a duplicate source account into the account. The source account redirects to the account afterward.
+//	@ID				merge-accounts
+//	@Tags			accounts
+//	@Param			request	body	MergeRequest	true	"merge request"
+//	@Param			id		path	string			true	"target account ID"
+//	@Produce		json
+//	@Success		200	{object}	AccountAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/accounts/{id}/merge [post]
+func MergeAccount(service *account.Accounting) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		var request MergeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		acc, err := service.Merge(request.SourceID, c.Param("id"))
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, account.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			case errors.Is(err, account.ErrorInvalidProperty):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		alias, err := convertAccount(acc)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, alias)
+	}
+}
+
+// GetAccountStatement lists all bookings of an account.
+//
+//	@Summary		account statement
+//	@Schemes		http
+//	@Description	Lists all bookings of an account in chronological order, including the history of merged accounts.
+//	@ID				get-account-statement
+//	@Tags			accounts
+//	@Param			id	path	string	true	"account ID"
+//	@Produce		json
+//	@Success		200	{array}	account.StatementEntry
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/accounts/{id}/statement [get]
+func GetAccountStatement(service *account.Accounting) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		statement, err := service.Statement(c.Param("id"))
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, account.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, statement)
+	}
+}
+
 type AccountAlias struct {
-	ID            string  `json:"id"`
-	Owner         string  `json:"owner"`
-	Balance       float64 `json:"balance"`
-	ConsumedTotal int     `json:"consumed_total"`
+	ID            string       `json:"id"`
+	Owner         string       `json:"owner"`
+	Group         string       `json:"group"`
+	Balance       float64      `json:"balance"`
+	ConsumedTotal int          `json:"consumed_total"`
+	LastActivity  time.Time    `json:"last_activity"`
+	Contact       ContactAlias `json:"contact"`
+	PlanID        string       `json:"plan_id,omitempty"`
+	MergedFrom    []string     `json:"merged_from,omitempty"`
+}
+
+type ContactAlias struct {
+	Email         string             `json:"email"`
+	Language      string             `json:"language"`
+	Notifications NotificationsAlias `json:"notifications"`
+}
+
+type NotificationsAlias struct {
+	LowBalance   bool `json:"low_balance"`
+	DebtReminder bool `json:"debt_reminder"`
+}
+
+type ContactUpdateRequest ContactAlias
+
+type MergeRequest struct {
+	SourceID string `json:"source_id" binding:"required"`
+}
+
+type GroupUpdateRequest struct {
+	Group string `json:"group"`
 }
 
 type AccountCreationRequest struct {
 	Owner string `json:"owner"`
+	Email string `json:"email"`
 }
 
 func convertAccount(a *account.Account) (AccountAlias, error) {
+	var planID string
+	if sub, ok := a.Subscription(); ok {
+		planID = sub.PlanID
+	}
 	return AccountAlias{
 		ID:            a.ID(),
 		Owner:         a.Owner(),
+		Group:         a.Group(),
 		Balance:       a.Balance(),
-		ConsumedTotal: a.ConsumedTotal()}, nil
+		ConsumedTotal: a.ConsumedTotal(),
+		LastActivity:  a.LastActivity(),
+		Contact: ContactAlias{
+			Email:    a.Contact().Email,
+			Language: a.Contact().Language,
+			Notifications: NotificationsAlias{
+				LowBalance:   a.Contact().Notifications.LowBalance,
+				DebtReminder: a.Contact().Notifications.DebtReminder,
+			},
+		},
+		PlanID:     planID,
+		MergedFrom: a.MergedFrom()}, nil
 }
 
 type AccountCreatedResponse struct {
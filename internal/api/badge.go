@@ -0,0 +1,250 @@
+package api
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/badge"
+	"coffy/internal/consume"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ConsumeByBadge charges the owner of a badge with the coffee currently loaded in a machine.
+//
+// Unknown badges are recorded as pending registration, so an admin can claim them for an account.
+//
+//	@Summary		consume by badge
+//	@Schemes		http
+//	@Description	Informs coffy about the owner of a badge consumed the coffee currently loaded in a machine.
+//	@ID				consume-by-badge
+//	@Tags			consume
+//	@Param			request			body	BadgeConsumeRequest	true	"badge consume request"
+//	@Param			Idempotency-Key	header	string				false	"unique key of the request, retries with the same key are charged once"
+//	@Produce		json
+//	@Success		201	{object}	consume.Receipt
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		403	{ object }	map[string]string	"the badge has been revoked or lost, or a quota has been exceeded"
+//	@Failure		404	{ object }	map[string]string	"the badge is not registered"
+//	@Failure		409	{ object }	map[string]string	"no coffee is loaded in the machine or the coffee has been discontinued"
+//	@Failure		422	{ object }	map[string]string	"the idempotency key has been used for a different request"
+//	@Router			/consume/badge [post]
+func ConsumeByBadge(badges *badge.Service, s *consume.Service) func(c *gin.Context) {
+	if badges == nil || s == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("badge or consume service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &BadgeConsumeRequest{}
+		if err := c.ShouldBindJSON(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if r.MachineID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "machine_id is missing"})
+			return
+		}
+		accountID, err := badges.Identify(r.BadgeUID)
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, badge.ErrorInvalidProperty):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, badge.ErrorRevoked), errors.Is(err, badge.ErrorLost):
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			case errors.Is(err, badge.ErrorUnknownBadge):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		quantity := r.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		consumeOrder(c, s, consume.Order{AccountID: accountID, MachineID: r.MachineID, Quantity: quantity})
+	}
+}
+
+// GetBadges returns all known badges.
+//
+//	@Summary		list badges
+//	@Schemes		http
+//	@Description	Request all badges, or the badges of a status, e.g. the badges pending registration.
+//	@ID				get-badges
+//	@Tags			badges
+//	@Param			status	query	string	false	"pending, active, revoked or lost"
+//	@Produce		json
+//	@Success		200	{array}	BadgeAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Router			/badges [get]
+func GetBadges(service *badge.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("badge service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		status := badge.Status(c.Query("status"))
+		switch status {
+		case "", badge.StatusPending, badge.StatusActive, badge.StatusRevoked, badge.StatusLost:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown status '" + string(status) + "'"})
+			return
+		}
+		var badges []badge.Badge
+		var err error
+		if status == badge.StatusPending {
+			badges, err = service.Pending()
+		} else {
+			badges, err = service.ListAll()
+		}
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		result := make([]BadgeAlias, 0, len(badges))
+		for _, b := range badges {
+			if status == "" || b.Status() == status {
+				result = append(result, toBadgeAlias(&b))
+			}
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// RegisterBadge assigns a badge to an account, e.g. to claim a badge pending registration.
+//
+//	@Summary		register a badge
+//	@Schemes		http
+//	@Description	Assigns a new, pending or revoked badge to an account. Lost badges cannot be registered again.
+//	@ID				register-badge
+//	@Tags			badges
+//	@Param			request	body	BadgeRegisterRequest	true	"badge register request"
+//	@Produce		json
+//	@Success		201	{object}	BadgeAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/badges [post]
+func RegisterBadge(service *badge.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("badge service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &BadgeRegisterRequest{}
+		if err := c.ShouldBindJSON(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		b, err := service.Register(r.UID, r.AccountID)
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, badge.ErrorInvalidProperty):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, account.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		c.JSON(http.StatusCreated, toBadgeAlias(b))
+	}
+}
+
+// RevokeBadge releases a badge from its account.
+//
+//	@Summary		revoke a badge
+//	@Schemes		http
+//	@Description	Releases a badge from its account, e.g. when it has been returned. The badge can be registered again.
+//	@ID				revoke-badge
+//	@Tags			badges
+//	@Param			uid	path	string	true	"badge UID"
+//	@Produce		json
+//	@Success		200	{object}	BadgeAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/badges/{uid} [delete]
+func RevokeBadge(service *badge.Service) func(*gin.Context) {
+	return changeBadge(service, service.Revoke)
+}
+
+// ReportBadgeLost blocks a badge that has been lost.
+//
+//	@Summary		report a badge lost
+//	@Schemes		http
+//	@Description	Blocks a badge that has been lost for good.
+//	@ID				report-badge-lost
+//	@Tags			badges
+//	@Param			uid	path	string	true	"badge UID"
+//	@Produce		json
+//	@Success		200	{object}	BadgeAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/badges/{uid}/lost [post]
+func ReportBadgeLost(service *badge.Service) func(*gin.Context) {
+	return changeBadge(service, service.ReportLost)
+}
+
+func changeBadge(service *badge.Service, change func(uid string) (*badge.Badge, error)) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("badge service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		b, err := change(c.Param("uid"))
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, badge.ErrorInvalidProperty):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, badge.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Badge not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, toBadgeAlias(b))
+	}
+}
+
+func toBadgeAlias(b *badge.Badge) BadgeAlias {
+	accountID, _ := b.AccountID()
+	alias := BadgeAlias{UID: b.UID, AccountID: accountID, Status: string(b.Status())}
+	if !b.Seen().IsZero() {
+		seen := b.Seen()
+		alias.Seen = &seen
+	}
+	return alias
+}
+
+type BadgeAlias struct {
+	UID       string     `json:"uid"`
+	AccountID string     `json:"account_id,omitempty"`
+	Status    string     `json:"status"`         // pending, active, revoked or lost
+	Seen      *time.Time `json:"seen,omitempty"` // the first scan of the badge before it has been registered
+}
+
+type BadgeConsumeRequest struct {
+	BadgeUID  string `json:"badge_uid"`
+	MachineID string `json:"machine_id"`
+	Quantity  int    `json:"quantity"` // defaults to one cup
+}
+
+type BadgeRegisterRequest struct {
+	UID       string `json:"uid"`
+	AccountID string `json:"account_id"`
+}
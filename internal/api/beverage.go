@@ -0,0 +1,241 @@
+package api
+
+import (
+	"coffy/internal/beverage"
+	"coffy/internal/product"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"time"
+)
+
+// GetBeverages returns the beverage catalog with the current price of each beverage.
+//
+//	@Summary		list the beverage catalog
+//	@Schemes		http
+//	@Description	Lists the beverages prepared from the coffees, e.g. cappuccino or latte, ordered by name.
+//	@ID				get-beverages
+//	@Tags			beverages
+//	@Param			status	query	string	false	"active (default), removed or all"
+//	@Produce		json
+//	@Success		200	{array}		BeverageAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Router			/beverages [get]
+func GetBeverages(service *beverage.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("beverage service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", "active")
+		if status != "active" && status != "removed" && status != "all" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be 'active', 'removed' or 'all'"})
+			return
+		}
+		all, err := service.ListAll()
+		if err != nil {
+			respondBeverageError(c, err)
+			return
+		}
+		result := make([]BeverageAlias, 0, len(all))
+		for _, b := range all {
+			if status != "all" && (status == "removed") != b.Removed() {
+				continue
+			}
+			alias, err := toBeverageAlias(service, &b)
+			if err != nil {
+				respondBeverageError(c, err)
+				return
+			}
+			result = append(result, alias)
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// GetBeverage returns a beverage of the catalog.
+//
+//	@Summary		access a beverage by ID
+//	@Schemes		http
+//	@Description	Request a beverage with its recipe and current price.
+//	@ID				get-beverage
+//	@Tags			beverages
+//	@Param			id	path	string	true	"beverage ID"
+//	@Produce		json
+//	@Success		200	{object}	BeverageAlias
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/beverages/{id} [get]
+func GetBeverage(service *beverage.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("beverage service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		b, err := service.Find(c.Param("id"))
+		if err != nil {
+			respondBeverageError(c, err)
+			return
+		}
+		respondBeverage(c, service, b, http.StatusOK)
+	}
+}
+
+// CreateBeverage adds a beverage to the catalog.
+//
+//	@Summary		create a beverage
+//	@Schemes		http
+//	@Description	Adds a beverage prepared from a coffee after a recipe, priced with a fixed price or a multiple of the coffee price plus a surcharge.
+//	@ID				create-beverage
+//	@Tags			beverages
+//	@Param			request	body	BeverageRequest	true	"beverage request"
+//	@Produce		json
+//	@Success		201	{object}	BeverageAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Failure		409	{ object }	map[string]string	"the coffee has been discontinued"
+//	@Router			/beverages [post]
+func CreateBeverage(service *beverage.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("beverage service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &BeverageRequest{}
+		if err := c.ShouldBindJSON(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		b, err := service.Create(r.Name, r.CoffeeID, r.Recipe, r.Pricing)
+		if err != nil {
+			respondBeverageError(c, err)
+			return
+		}
+		respondBeverage(c, service, b, http.StatusCreated)
+	}
+}
+
+// PutBeverage replaces the name, the coffee, the recipe and the pricing of a beverage.
+//
+//	@Summary		change a beverage
+//	@Schemes		http
+//	@Description	Replaces the name, the coffee, the recipe and the pricing of a beverage.
+//	@ID				change-beverage
+//	@Tags			beverages
+//	@Param			id		path	string			true	"beverage ID"
+//	@Param			request	body	BeverageRequest	true	"beverage request"
+//	@Produce		json
+//	@Success		200	{object}	BeverageAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Failure		409	{ object }	map[string]string	"the coffee has been discontinued"
+//	@Router			/beverages/{id} [put]
+func PutBeverage(service *beverage.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("beverage service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &BeverageRequest{}
+		if err := c.ShouldBindJSON(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		b, err := service.Change(c.Param("id"), r.Name, r.CoffeeID, r.Recipe, r.Pricing)
+		if err != nil {
+			respondBeverageError(c, err)
+			return
+		}
+		respondBeverage(c, service, b, http.StatusOK)
+	}
+}
+
+// DeleteBeverage takes a beverage off the catalog. Its consumptions are kept.
+//
+//	@Summary		remove a beverage
+//	@Schemes		http
+//	@Description	Takes a beverage off the catalog, it cannot be consumed anymore.
+//	@ID				remove-beverage
+//	@Tags			beverages
+//	@Param			id	path	string	true	"beverage ID"
+//	@Produce		json
+//	@Success		200	{object}	BeverageAlias
+//	@Failure		404	{ object }	map[string]string
+//	@Failure		409	{ object }	map[string]string	"the beverage has been removed already"
+//	@Router			/beverages/{id} [delete]
+func DeleteBeverage(service *beverage.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("beverage service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		b, err := service.Remove(c.Param("id"))
+		if err != nil {
+			respondBeverageError(c, err)
+			return
+		}
+		respondBeverage(c, service, b, http.StatusOK)
+	}
+}
+
+func respondBeverage(c *gin.Context, service *beverage.Service, b *beverage.Beverage, status int) {
+	alias, err := toBeverageAlias(service, b)
+	if err != nil {
+		respondBeverageError(c, err)
+		return
+	}
+	c.JSON(status, alias)
+}
+
+func respondBeverageError(c *gin.Context, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, beverage.ErrorInvalidProperty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, beverage.ErrorNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Beverage not found"})
+	case errors.Is(err, product.ErrorNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coffee not found"})
+	case errors.Is(err, beverage.ErrorRemoved):
+		c.JSON(http.StatusConflict, gin.H{"error": "Beverage has been removed"})
+	case errors.Is(err, product.ErrorDiscontinued):
+		c.JSON(http.StatusConflict, gin.H{"error": "Coffee has been discontinued"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{})
+	}
+}
+
+func toBeverageAlias(service *beverage.Service, b *beverage.Beverage) (BeverageAlias, error) {
+	price, err := service.PriceAt(b, time.Now())
+	if err != nil {
+		return BeverageAlias{}, err
+	}
+	return BeverageAlias{ID: b.ID, Name: b.Name(), CoffeeID: b.CoffeeID(), Recipe: b.Recipe(), Pricing: b.Pricing(), Price: price, Removed: b.Removed()}, nil
+}
+
+type BeverageAlias struct {
+	ID       string           `json:"id"`
+	Name     string           `json:"name"`
+	CoffeeID string           `json:"coffee_id"`
+	Recipe   beverage.Recipe  `json:"recipe"`
+	Pricing  beverage.Pricing `json:"pricing"`
+	Price    float64          `json:"price"` // the current price of a cup
+	Removed  bool             `json:"removed,omitempty"`
+}
+
+type BeverageRequest struct {
+	Name     string           `json:"name" binding:"required"`
+	CoffeeID string           `json:"coffee_id" binding:"required"`
+	Recipe   beverage.Recipe  `json:"recipe"`
+	Pricing  beverage.Pricing `json:"pricing"` // a fixed price, or a factor of the coffee price and a surcharge
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"coffy/internal/cashbox"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+// GetCashBox returns the revenue expected in the cash box and the history of counts.
+//
+//	@Summary		access the cash box
+//	@Schemes		http
+//	@Description	Request the expected revenue of cash sales since the last count and all counts.
+//	@ID				get-cash-box
+//	@Tags			cashbox
+//	@Produce		json
+//	@Success		200	{object}	CashBoxAlias
+//	@Router			/cashbox [get]
+func GetCashBox(service *cashbox.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("cash box service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		box, err := service.Find()
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, CashBoxAlias{Expected: box.Expected(), Sales: box.Sales(), Counts: box.Counts()})
+	}
+}
+
+// CountCashBox records the money counted in the cash box.
+//
+//	@Summary		count the cash box
+//	@Schemes		http
+//	@Description	Records the money counted in the cash box and returns the discrepancy against the expected revenue. The money is taken out of the box.
+//	@ID				count-cash-box
+//	@Tags			cashbox
+//	@Param			request	body	CashCountRequest	true	"cash count request"
+//	@Produce		json
+//	@Success		201	{object}	cashbox.Count
+//	@Failure		400	{ object }	map[string]string
+//	@Router			/cashbox/count [post]
+func CountCashBox(service *cashbox.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("cash box service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &CashCountRequest{}
+		if err := c.ShouldBindJSON(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		count, err := service.Count(*r.Amount)
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, cashbox.ErrorInvalidProperty):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		c.JSON(http.StatusCreated, count)
+	}
+}
+
+type CashBoxAlias struct {
+	Expected float64         `json:"expected"` // the revenue of the cash sales since the last count
+	Sales    int             `json:"sales"`    // the number of cups sold since the last count
+	Counts   []cashbox.Count `json:"counts"`
+}
+
+type CashCountRequest struct {
+	Amount *float64 `json:"amount" binding:"required,min=0"` // the money counted in the box, zero for an empty box
+}
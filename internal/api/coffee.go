@@ -2,43 +2,80 @@ package api
 
 import (
 	"coffy/internal/product"
+	"coffy/internal/rating"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 )
 
-// GetCoffees returns all available coffees in coffy.
+// GetCoffees returns all available coffees in coffy, one page at a time. Discontinued coffees are listed on request.
+// Coffees that have been rated include their average rating.
 //
 //	@Summary		get all coffees
 //	@Schemes		http
-//	@Description	Lists all available coffees in coffy.
+//	@Description	Lists all available coffees in coffy, sorted by name.
 //	@ID				get-all-coffees
 //	@Tags			coffees
+//	@Param			status	query	string	false	"active (default), discontinued or all"
+//	@Param			cursor	query	string	false	"cursor of the next page from the previous response"
+//	@Param			limit	query	int		false	"page size, 50 by default, 200 at most"
 //	@Produce		json
-//	@Success		200	{array}	CoffeeInfo
+//	@Success		200	{object}	Page[CoffeeInfo]
+//	@Failure		400	{ object }	map[string]string
 //	@Router			/coffees [get]
-func GetCoffees(service *product.Service) func(*gin.Context) {
-	if service == nil {
+func GetCoffees(service *product.Service, ratings *rating.Service) func(*gin.Context) {
+	if service == nil || ratings == nil {
 		return func(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{})
 		}
 	}
 	return func(c *gin.Context) {
-		bev, err := service.ListAll()
+		pageReq, err := parsePageRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		status := c.DefaultQuery("status", "active")
+		if status != "active" && status != "discontinued" && status != "all" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be 'active', 'discontinued' or 'all'"})
+			return
+		}
+		all, err := service.ListAll()
 		if err != nil {
 			log.Println(err)
 			c.JSON(http.StatusInternalServerError, gin.H{})
 			return
 		}
-		list, err := allToCoffeeInfo(bev)
+		bev := make([]product.Coffee, 0, len(all))
+		for _, b := range all {
+			if status == "all" || (status == "discontinued") == b.Discontinued() {
+				bev = append(bev, b)
+			}
+		}
+		page, err := paginate(bev, func(b product.Coffee) namedPosition {
+			return namedPosition{Name: strings.ToLower(b.Type), ID: b.AggregateID}
+		}, compareNamed, pageReq)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		list, err := allToCoffeeInfo(page.Items)
 		if err != nil {
 			log.Println("conversion to coffee info failed:", err)
 			c.JSON(http.StatusInternalServerError, gin.H{})
 			return
 		}
+		summaries, err := ratings.Summaries()
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
 
-		c.JSON(http.StatusOK, list)
+		c.JSON(http.StatusOK, Page[CoffeeInfo]{Items: withRatings(list, summaries), Total: page.Total, NextCursor: page.NextCursor})
 	}
 }
 
@@ -49,10 +86,11 @@ func GetCoffees(service *product.Service) func(*gin.Context) {
 //	@Description	Changes the coffee details information.
 //	@ID				change-details-coffee
 //	@Tags			coffees
-//	@Param			request	body	DetailsUpdateRequest	true	"coffee details update request"
-//	@Param			id		path	string					true	"coffee ID"
+//	@Param			request	body	product.Details	true	"coffee details update request"
+//	@Param			id		path	string			true	"coffee ID"
 //	@Produce		json
 //	@Success		200	{object}	CoffeeInfo
+//	@Failure		400	{ object }	map[string]string
 //	@Failure		404	{ object }	map[string]string
 //	@Router			/coffees/{id}/info [patch]
 func PatchCoffeeDetails(service *product.Service) func(*gin.Context) {
@@ -62,22 +100,21 @@ func PatchCoffeeDetails(service *product.Service) func(*gin.Context) {
 		}
 	}
 	return func(c *gin.Context) {
-		coffeeID := c.Param("id")
-		coffee, err := service.Find(coffeeID)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{})
-		}
 		details := product.Details{}
 		if err := c.ShouldBindJSON(&details); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		err = coffee.UpdateDetails(details)
+		coffee, err := service.UpdateDetails(c.Param("id"), details)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{})
+			respondCoffeeError(c, err)
+			return
 		}
 		info, err := toCoffeeInfo(coffee)
 		if err != nil {
+			log.Println(err)
 			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
 		}
 		c.JSON(http.StatusOK, info)
 	}
@@ -94,6 +131,7 @@ func PatchCoffeeDetails(service *product.Service) func(*gin.Context) {
 //	@Param			id		path	string				true	"coffee ID"
 //	@Produce		json
 //	@Success		200	{object}	CoffeeInfo
+//	@Failure		400	{ object }	map[string]string
 //	@Failure		404	{ object }	map[string]string
 //	@Router			/coffees/{id}/price [patch]
 func PatchCoffeePrice(service *product.Service) func(*gin.Context) {
@@ -103,31 +141,221 @@ func PatchCoffeePrice(service *product.Service) func(*gin.Context) {
 		}
 	}
 	return func(c *gin.Context) {
-		coffeeID := c.Param("id")
-		coffee, err := service.Find(coffeeID)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{})
-			return
-		}
 		request := PriceUpdateRequest{}
 		if err := c.ShouldBindJSON(&request); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		err = coffee.ChangePrice(request.Price, request.Reason)
+		coffee, err := service.ChangePrice(c.Param("id"), request.Price, request.Reason)
 		if err != nil {
-			if err.Error() == "invalid price" {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-				return
-			}
+			respondCoffeeError(c, err)
+			return
+		}
+		info, err := toCoffeeInfo(coffee)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+// DiscontinueCoffee takes a coffee out of the catalog
+//
+//	@Summary		discontinue a coffee
+//	@Schemes		http
+//	@Description	Hides the coffee from the catalog and blocks it from consumption and from being loaded into machines. It remains available for the history of consumptions.
+//	@ID				discontinue-coffee
+//	@Tags			coffees
+//	@Param			id	path	string	true	"coffee ID"
+//	@Produce		json
+//	@Success		200	{object}	CoffeeInfo
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/discontinue [post]
+func DiscontinueCoffee(service *product.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return changeCoffee(service.Discontinue)
+}
+
+// ReinstateCoffee puts a discontinued coffee back into the catalog
+//
+//	@Summary		reinstate a coffee
+//	@Schemes		http
+//	@Description	Puts a discontinued coffee back into the catalog.
+//	@ID				reinstate-coffee
+//	@Tags			coffees
+//	@Param			id	path	string	true	"coffee ID"
+//	@Produce		json
+//	@Success		200	{object}	CoffeeInfo
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/reinstate [post]
+func ReinstateCoffee(service *product.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return changeCoffee(service.Reinstate)
+}
+
+func changeCoffee(change func(coffeeID string) (*product.Coffee, error)) func(*gin.Context) {
+	return func(c *gin.Context) {
+		coffee, err := change(c.Param("id"))
+		if err != nil {
+			respondCoffeeError(c, err)
 			return
 		}
 		info, err := toCoffeeInfo(coffee)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
 		c.JSON(http.StatusOK, info)
 	}
 }
 
+// GetCoffeePrices returns the price history of a coffee up to now
+//
+//	@Summary		price history of a coffee
+//	@Schemes		http
+//	@Description	Lists all prices of the coffee with the reason of the change and the time it took effect, oldest first.
+//	@ID				get-coffee-prices
+//	@Tags			coffees
+//	@Param			id	path	string	true	"coffee ID"
+//	@Produce		json
+//	@Success		200	{array}		product.PriceChange
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/prices [get]
+func GetCoffeePrices(service *product.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		coffee, err := service.Find(c.Param("id"))
+		if err != nil {
+			respondCoffeeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, coffee.Prices(time.Now()))
+	}
+}
+
+// GetUpcomingCoffeePrices returns the scheduled prices of a coffee
+//
+//	@Summary		upcoming prices of a coffee
+//	@Schemes		http
+//	@Description	Lists the announced prices of the coffee that do not apply yet, ordered by the time they apply from.
+//	@ID				get-upcoming-coffee-prices
+//	@Tags			coffees
+//	@Param			id	path	string	true	"coffee ID"
+//	@Produce		json
+//	@Success		200	{array}		product.PriceChange
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/prices/upcoming [get]
+func GetUpcomingCoffeePrices(service *product.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		coffee, err := service.Find(c.Param("id"))
+		if err != nil {
+			respondCoffeeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, coffee.Upcoming(time.Now()))
+	}
+}
+
+// ScheduleCoffeePrice announces a future price of a coffee
+//
+//	@Summary		schedule a price change
+//	@Schemes		http
+//	@Description	Announces a price of the coffee that applies from a time in the future. Until then, the current price is charged.
+//	@ID				schedule-coffee-price
+//	@Tags			coffees
+//	@Param			id		path	string					true	"coffee ID"
+//	@Param			request	body	PriceScheduleRequest	true	"price schedule request"
+//	@Produce		json
+//	@Success		201	{object}	product.PriceChange
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/prices [post]
+func ScheduleCoffeePrice(service *product.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		request := PriceScheduleRequest{}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		change, err := service.SchedulePrice(c.Param("id"), request.Price, request.Reason, request.EffectiveFrom)
+		if err != nil {
+			respondCoffeeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, change)
+	}
+}
+
+// CancelCoffeePrice withdraws a scheduled price of a coffee
+//
+//	@Summary		cancel a scheduled price change
+//	@Schemes		http
+//	@Description	Withdraws an announced price of the coffee that does not apply yet.
+//	@ID				cancel-coffee-price
+//	@Tags			coffees
+//	@Param			id			path	string	true	"coffee ID"
+//	@Param			change_id	path	string	true	"ID of the scheduled price change"
+//	@Produce		json
+//	@Success		200	{array}		product.PriceChange	"the remaining upcoming prices"
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/prices/{change_id} [delete]
+func CancelCoffeePrice(service *product.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		coffee, err := service.CancelPriceChange(c.Param("id"), c.Param("change_id"))
+		if err != nil {
+			respondCoffeeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, coffee.Upcoming(time.Now()))
+	}
+}
+
+func respondCoffeeError(c *gin.Context, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, product.ErrorNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coffee not found"})
+	case errors.Is(err, product.ErrorPriceChangeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, product.InvalidPropertyError):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{})
+	}
+}
+
 // CreateCoffee creates a new coffee in coffy with an initial price.
 //
 //	@Summary		create new coffee
@@ -182,8 +410,10 @@ type PriceUpdateRequest struct {
 	Reason string  `form:"reason" json:"reason" binding:"required"`
 }
 
-type DetailsUpdateRequest struct {
-	Details product.CoffeeDetails `form:"info" json:"info"`
+type PriceScheduleRequest struct {
+	Price         float64   `json:"price" binding:"required"`
+	Reason        string    `json:"reason" binding:"required"`
+	EffectiveFrom time.Time `json:"effective_from" binding:"required"` // the time the price applies from, e.g. 2025-03-01T00:00:00+01:00
 }
 
 type CoffeeInfo struct {
@@ -192,6 +422,8 @@ type CoffeeInfo struct {
 	Price        float64               `json:"price"`
 	CuppingScore int                   `json:"cupping_score"`
 	Details      product.CoffeeDetails `json:"info"`
+	Discontinued bool                  `json:"discontinued,omitempty"`
+	Rating       *rating.Summary       `json:"rating,omitempty"` // the average rating of the drinkers, if rated
 }
 
 func allToCoffeeInfo(list []product.Coffee) ([]CoffeeInfo, error) {
@@ -214,9 +446,9 @@ func toCoffeeInfo(b *product.Coffee) (CoffeeInfo, error) {
 		return CoffeeInfo{}, errors.New("beverage is nil")
 	}
 	d := toCoffeeDetails(b.Details())
-	return CoffeeInfo{ID: b.AggregateID, Name: b.Type, Price: b.Price(), CuppingScore: b.CoffeeValue().Value, Details: d}, nil
+	return CoffeeInfo{ID: b.AggregateID, Name: b.Type, Price: b.Price(), CuppingScore: b.CoffeeValue().Value, Details: d, Discontinued: b.Discontinued()}, nil
 }
 
 func toCoffeeDetails(d product.Details) product.CoffeeDetails {
-	return product.CoffeeDetails{Origin: d.Origin, Description: d.Description, Misc: d.Misc}
+	return product.CoffeeDetails{Origin: d.Origin, Description: d.Description, RoastHouse: d.RoastHouse, Misc: d.Misc}
 }
@@ -1,23 +1,43 @@
 package api
 
 import (
+	"coffy/internal/beverage"
 	"coffy/internal/consume"
+	"coffy/internal/equipment"
+	"coffy/internal/product"
+	"coffy/internal/quota"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
+	"strings"
 )
 
+const idempotencyKeyHeader = "Idempotency-Key"
+
 // Consume applies an actual consume request to a user's account
 //
+// Orders of a beverage are charged with the price of the beverage and record the coffee it is prepared from.
+//
+// Guests without an account pay cash, their consumption is recorded as sale of the cash box.
+//
+// Requests with an Idempotency-Key header are charged only once, repetitions within the TTL
+// of the key return the receipt of the first request.
+//
 //	@Summary		consume a coffee
 //	@Schemes		http
 //	@Description	Informs coffy about a user consumed a coffee.
 //	@ID				consume-a-coffee
 //	@Tags			consume
-//	@Param			request	body	ConsumeRequest	true	"consume coffee request"
+//	@Param			request			body	ConsumeRequest	true	"consume coffee request"
+//	@Param			Idempotency-Key	header	string			false	"unique key of the request, retries with the same key are charged once"
 //	@Produce		json
-//	@Success		200	{object}	consume.Receipt
+//	@Success		201	{object}	consume.Receipt
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		403	{ object }	map[string]string	"a quota has been exceeded"
+//	@Failure		404	{ object }	map[string]string
+//	@Failure		409	{ object }	map[string]string	"the coffee has been discontinued or the beverage has been removed"
+//	@Failure		422	{ object }	map[string]string	"the idempotency key has been used for a different request"
 //	@Router			/consume [post]
 func Consume(s *consume.Service) func(c *gin.Context) {
 	if s == nil {
@@ -31,12 +51,261 @@ func Consume(s *consume.Service) func(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		receipt, err := s.Consume(r.AccountID, r.ProductID, r.Quantity)
+		order := consume.Order{AccountID: r.AccountID, CoffeeID: r.ProductID, BeverageID: r.BeverageID, Quantity: r.Quantity, Payment: consume.Payment(r.Payment)}
+		consumeOrder(c, s, order)
+	}
+}
+
+// ConsumeOverride charges a user's account without applying the quota rules, e.g. for a team event.
+//
+// The route is meant for admins only, the reason is recorded on the receipt to keep overrides auditable.
+//
+//	@Summary		consume a coffee beyond the quotas
+//	@Schemes		http
+//	@Description	Informs coffy about a user consumed a coffee, skipping the quota rules for the given reason.
+//	@ID				consume-override
+//	@Tags			consume
+//	@Param			request			body	OverrideConsumeRequest	true	"consume request with the reason of the override"
+//	@Param			Idempotency-Key	header	string					false	"unique key of the request, retries with the same key are charged once"
+//	@Produce		json
+//	@Success		201	{object}	consume.Receipt
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Failure		409	{ object }	map[string]string	"the coffee has been discontinued or the beverage has been removed"
+//	@Failure		422	{ object }	map[string]string	"the idempotency key has been used for a different request"
+//	@Router			/consume/override [post]
+func ConsumeOverride(s *consume.Service) func(c *gin.Context) {
+	if s == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &OverrideConsumeRequest{}
+		if err := c.ShouldBindJSON(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		reason := strings.TrimSpace(r.Reason)
+		if reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reason is missing"})
+			return
+		}
+		if r.AccountID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "account_id is missing"})
+			return
+		}
+		order := consume.Order{AccountID: r.AccountID, CoffeeID: r.ProductID, BeverageID: r.BeverageID, MachineID: r.MachineID, Quantity: r.Quantity, Override: reason}
+		consumeOrder(c, s, order)
+	}
+}
+
+// ConsumeFromMachine charges a user's account with the coffee currently loaded in a machine.
+//
+//	@Summary		consume the coffee loaded in a machine
+//	@Schemes		http
+//	@Description	Informs coffy about a user consumed the coffee currently loaded in a machine.
+//	@ID				consume-from-machine
+//	@Tags			machines
+//	@Param			id				path	string					true	"machine ID"
+//	@Param			request			body	MachineConsumeRequest	true	"consume request"
+//	@Param			Idempotency-Key	header	string					false	"unique key of the request, retries with the same key are charged once"
+//	@Produce		json
+//	@Success		201	{object}	consume.Receipt
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		403	{ object }	map[string]string	"a quota has been exceeded"
+//	@Failure		404	{ object }	map[string]string
+//	@Failure		409	{ object }	map[string]string	"no coffee is loaded in the machine or the coffee has been discontinued"
+//	@Failure		422	{ object }	map[string]string	"the idempotency key has been used for a different request"
+//	@Router			/machines/{id}/consume [post]
+func ConsumeFromMachine(s *consume.Service) func(c *gin.Context) {
+	if s == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &MachineConsumeRequest{}
+		if err := c.ShouldBind(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		consumeOrder(c, s, consume.Order{AccountID: r.AccountID, MachineID: c.Param("id"), Quantity: r.Quantity})
+	}
+}
+
+// ConsumeBatch records the consumptions of a tally sheet with their original timestamps.
+//
+// The rows are sent as JSON or as CSV tally sheet with the content type text/csv. Either all rows are
+// recorded or none, the report names the errors of each row.
+//
+//	@Summary		consume in batch
+//	@Schemes		http
+//	@Description	Records the consumptions of a tally sheet, all or nothing.
+//	@ID				consume-batch
+//	@Tags			consume
+//	@Accept			json,text/csv
+//	@Param			request	body	BatchConsumeRequest	true	"rows of account, coffee, quantity and timestamp"
+//	@Produce		json
+//	@Success		201	{object}	consume.BatchReport
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		422	{object}	consume.BatchReport	"the batch has been rejected"
+//	@Router			/consume/batch [post]
+func ConsumeBatch(s *consume.Service) func(c *gin.Context) {
+	if s == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		var rows []consume.Row
+		if c.ContentType() == "text/csv" {
+			parsed, err := consume.ParseTally(c.Request.Body)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			rows = parsed
+		} else {
+			r := &BatchConsumeRequest{}
+			if err := c.ShouldBindJSON(r); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			rows = r.Rows
+		}
+		if len(rows) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "batch is empty"})
+			return
+		}
+		report, err := s.ConsumeBatch(rows)
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, consume.ErrorBatchRejected):
+				c.JSON(http.StatusUnprocessableEntity, report)
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		c.JSON(http.StatusCreated, report)
+	}
+}
+
+// consumeOrder charges an order and responds with the receipt. Orders with an Idempotency-Key
+// header are charged only once.
+func consumeOrder(c *gin.Context, s *consume.Service, order consume.Order) {
+	var receipt *consume.Receipt
+	var err error
+	if key, ok := c.Request.Header[idempotencyKeyHeader]; ok {
+		var replayed bool
+		receipt, replayed, err = s.ConsumeOnce(strings.Join(key, ","), order)
+		if replayed {
+			c.Header("Idempotent-Replayed", "true")
+		}
+	} else {
+		receipt, err = s.Consume(order)
+	}
+	if err != nil {
+		log.Println(err)
+		if status, message := consumeError(err); message != "" {
+			c.JSON(status, gin.H{"error": message})
+		} else {
+			c.JSON(status, gin.H{})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, receipt)
+}
+
+// consumeError maps an error of charging an order to the HTTP status and the message shown to the client.
+// The message is empty for internal errors.
+func consumeError(err error) (int, string) {
+	switch {
+	case errors.Is(err, consume.ErrorInvalidQuantity), errors.Is(err, consume.ErrorInvalidIdempotencyKey),
+		errors.Is(err, consume.ErrorInvalidPayment):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, consume.ErrorIdempotencyKeyReused):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, quota.ErrorQuotaExceeded):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, equipment.ErrorNoCoffeeLoaded):
+		return http.StatusConflict, "No coffee loaded in the machine"
+	case errors.Is(err, product.ErrorDiscontinued):
+		return http.StatusConflict, "Coffee has been discontinued"
+	case errors.Is(err, beverage.ErrorRemoved):
+		return http.StatusConflict, "Beverage has been removed"
+	case errors.Is(err, beverage.ErrorNotFound):
+		return http.StatusNotFound, "Beverage not found"
+	case errors.Is(err, consume.ErrorMachineNotFound):
+		return http.StatusNotFound, "Machine not found"
+	case errors.Is(err, consume.ErrorProductNotFound):
+		return http.StatusNotFound, "Product not found"
+	case errors.Is(err, consume.ErrorAccountNotFound):
+		return http.StatusNotFound, "Account not found"
+	default:
+		return http.StatusInternalServerError, ""
+	}
+}
+
+// GetReceipt returns a receipt issued for a consumption.
+//
+//	@Summary		access a receipt by ID
+//	@Schemes		http
+//	@Description	Request a receipt by ID, e.g. to resolve a dispute.
+//	@ID				get-receipt-by-id
+//	@Tags			consume
+//	@Param			id	path	string	true	"receipt ID"
+//	@Produce		json
+//	@Success		200	{object}	consume.Receipt
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/receipts/{id} [get]
+func GetReceipt(s *consume.Service) func(c *gin.Context) {
+	if s == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		receipt, err := s.Receipt(c.Param("id"))
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, consume.ErrorReceiptNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, receipt)
+	}
+}
+
+// GetAccountReceipts lists all receipts issued for an account, including the receipts of merged accounts.
+//
+//	@Summary		list the receipts of an account
+//	@Schemes		http
+//	@Description	Request all receipts of an account, ordered by date.
+//	@ID				get-account-receipts
+//	@Tags			accounts
+//	@Param			id	path	string	true	"account ID"
+//	@Produce		json
+//	@Success		200	{array}	consume.Receipt
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/accounts/{id}/receipts [get]
+func GetAccountReceipts(s *consume.Service) func(c *gin.Context) {
+	if s == nil {
+		return func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		receipts, err := s.Receipts(c.Param("id"))
 		if err != nil {
 			log.Println(err)
 			switch {
-			case errors.Is(err, consume.ErrorProductNotFound):
-				c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 			case errors.Is(err, consume.ErrorAccountNotFound):
 				c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
 			default:
@@ -44,12 +313,32 @@ func Consume(s *consume.Service) func(c *gin.Context) {
 			}
 			return
 		}
-		c.JSON(http.StatusCreated, receipt)
+		c.JSON(http.StatusOK, receipts)
 	}
 }
 
-type ConsumeRequest struct {
+type BatchConsumeRequest struct {
+	Rows []consume.Row `json:"rows"`
+}
+
+type MachineConsumeRequest struct {
 	AccountID string `json:"account_id"`
-	ProductID string `json:"product_id"`
 	Quantity  int    `json:"quantity"`
 }
+
+type ConsumeRequest struct {
+	AccountID  string `json:"account_id"`  // empty for guests paying cash
+	ProductID  string `json:"product_id"`  // the coffee, optional if a beverage is ordered
+	BeverageID string `json:"beverage_id"` // the beverage, optional
+	Quantity   int    `json:"quantity"`
+	Payment    string `json:"payment"` // 'account' (default) or 'cash'
+}
+
+type OverrideConsumeRequest struct {
+	AccountID  string `json:"account_id"`
+	ProductID  string `json:"product_id"`  // the coffee, optional if a beverage is ordered or a machine is given
+	BeverageID string `json:"beverage_id"` // the beverage, optional
+	MachineID  string `json:"machine_id"`  // the machine the coffee was taken from, optional
+	Quantity   int    `json:"quantity"`
+	Reason     string `json:"reason"` // why the quota rules are skipped, recorded on the receipt
+}
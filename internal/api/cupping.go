@@ -0,0 +1,221 @@
+package api
+
+import (
+	"coffy/internal/cupping"
+	"coffy/internal/product"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"time"
+)
+
+// OpenCuppingSession starts a cupping session for a coffee.
+//
+//	@Summary		open a cupping session
+//	@Schemes		http
+//	@Description	Starts a cupping session, in which several assessors score the coffee on the SCA cupping form.
+//	@ID				open-cupping-session
+//	@Tags			cuppings
+//	@Param			id		path	string				true	"coffee ID"
+//	@Param			request	body	CuppingOpenRequest	false	"cupping session request"
+//	@Produce		json
+//	@Success		201	{object}	CuppingSessionAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/cuppings [post]
+func OpenCuppingSession(service *cupping.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("cupping service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &CuppingOpenRequest{}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(r); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		session, err := service.Open(c.Param("id"), r.Name)
+		if err != nil {
+			respondCuppingError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, toCuppingSessionAlias(session))
+	}
+}
+
+// GetCuppingSessions returns the cupping sessions of a coffee.
+//
+//	@Summary		list the cupping sessions of a coffee
+//	@Schemes		http
+//	@Description	Lists the cupping sessions of a coffee with the forms of all assessors, the latest first.
+//	@ID				get-cupping-sessions
+//	@Tags			cuppings
+//	@Param			id	path	string	true	"coffee ID"
+//	@Produce		json
+//	@Success		200	{array}		CuppingSessionAlias
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/cuppings [get]
+func GetCuppingSessions(service *cupping.Service, coffees *product.Service) func(*gin.Context) {
+	if service == nil || coffees == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("cupping or coffee service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		if _, err := coffees.Find(c.Param("id")); err != nil {
+			respondCuppingError(c, err)
+			return
+		}
+		sessions, err := service.ListByCoffee(c.Param("id"))
+		if err != nil {
+			respondCuppingError(c, err)
+			return
+		}
+		result := make([]CuppingSessionAlias, 0, len(sessions))
+		for _, s := range sessions {
+			result = append(result, toCuppingSessionAlias(&s))
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// GetCuppingSession returns a cupping session.
+//
+//	@Summary		access a cupping session by ID
+//	@Schemes		http
+//	@Description	Request a cupping session with the forms of all assessors and the result, once it has been closed.
+//	@ID				get-cupping-session
+//	@Tags			cuppings
+//	@Param			id	path	string	true	"cupping session ID"
+//	@Produce		json
+//	@Success		200	{object}	CuppingSessionAlias
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/cuppings/{id} [get]
+func GetCuppingSession(service *cupping.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("cupping service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		session, err := service.Find(c.Param("id"))
+		if err != nil {
+			respondCuppingError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, toCuppingSessionAlias(session))
+	}
+}
+
+// SubmitCuppingScores records the cupping form of an assessor.
+//
+//	@Summary		submit cupping scores
+//	@Schemes		http
+//	@Description	Records the SCA cupping form of an assessor. Assessors can correct their form until the session is closed.
+//	@ID				submit-cupping-scores
+//	@Tags			cuppings
+//	@Param			id		path	string					true	"cupping session ID"
+//	@Param			request	body	CuppingScoresRequest	true	"cupping form"
+//	@Produce		json
+//	@Success		200	{object}	CuppingSessionAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/cuppings/{id}/scores [post]
+func SubmitCuppingScores(service *cupping.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("cupping service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &CuppingScoresRequest{}
+		if err := c.ShouldBindJSON(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		session, err := service.Submit(c.Param("id"), r.Assessor, r.Scores)
+		if err != nil {
+			respondCuppingError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, toCuppingSessionAlias(session))
+	}
+}
+
+// CloseCuppingSession ends a cupping session and assesses the coffee with the averaged score.
+//
+//	@Summary		close a cupping session
+//	@Schemes		http
+//	@Description	Ends the session, averages the forms of all assessors and sets the result as cupping score of the coffee.
+//	@ID				close-cupping-session
+//	@Tags			cuppings
+//	@Param			id	path	string	true	"cupping session ID"
+//	@Produce		json
+//	@Success		200	{object}	CuppingSessionAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/cuppings/{id}/close [post]
+func CloseCuppingSession(service *cupping.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("cupping service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		session, err := service.Close(c.Param("id"))
+		if err != nil {
+			respondCuppingError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, toCuppingSessionAlias(session))
+	}
+}
+
+func respondCuppingError(c *gin.Context, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, cupping.ErrorInvalidProperty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, cupping.ErrorNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cupping session not found"})
+	case errors.Is(err, product.ErrorNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coffee not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{})
+	}
+}
+
+func toCuppingSessionAlias(s *cupping.Session) CuppingSessionAlias {
+	alias := CuppingSessionAlias{ID: s.ID, CoffeeID: s.CoffeeID, Name: s.Name, Opened: s.Opened(), Assessments: s.Assessments()}
+	if result, ok := s.Result(); ok {
+		alias.Result = &result
+	}
+	return alias
+}
+
+type CuppingSessionAlias struct {
+	ID          string               `json:"id"`
+	CoffeeID    string               `json:"coffee_id"`
+	Name        string               `json:"name,omitempty"`
+	Opened      time.Time            `json:"opened"`
+	Assessments []cupping.Assessment `json:"assessments"`
+	Result      *cupping.Result      `json:"result,omitempty"` // set once the session has been closed
+}
+
+type CuppingOpenRequest struct {
+	Name string `json:"name"` // an optional name of the session, e.g. 'Monday cupping'
+}
+
+type CuppingScoresRequest struct {
+	Assessor string         `json:"assessor" binding:"required"`
+	Scores   cupping.Scores `json:"scores"`
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"coffy/internal/inventory"
+	"coffy/internal/product"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PurchaseBag adds a bag of beans to the stock of a coffee.
+//
+//	@Summary		add a bag of beans
+//	@Schemes		http
+//	@Description	Records a bag of beans purchased for a coffee, which is depleted by the consumptions.
+//	@ID				purchase-bag
+//	@Tags			inventory
+//	@Param			id		path	string		true	"coffee ID"
+//	@Param			request	body	BagRequest	true	"bag request"
+//	@Produce		json
+//	@Success		201	{object}	inventory.Bag
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/bags [post]
+func PurchaseBag(service *inventory.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("inventory service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &BagRequest{}
+		if err := c.ShouldBindJSON(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		bag, err := service.Purchase(c.Param("id"), r.Weight, r.Cost, r.Purchased, r.Roasted)
+		if err != nil {
+			respondInventoryError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, bag)
+	}
+}
+
+// GetCoffeeBags returns the bags purchased for a coffee.
+//
+//	@Summary		list the bags of a coffee
+//	@Schemes		http
+//	@Description	Lists the bags of beans purchased for a coffee, ordered by purchase date.
+//	@ID				get-coffee-bags
+//	@Tags			inventory
+//	@Param			id	path	string	true	"coffee ID"
+//	@Produce		json
+//	@Success		200	{array}		inventory.Bag
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/bags [get]
+func GetCoffeeBags(service *inventory.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("inventory service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		bags, err := service.Bags(c.Param("id"))
+		if err != nil {
+			respondInventoryError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, bags)
+	}
+}
+
+// GetCoffeeStock returns the beans left of a coffee and how long they are expected to last.
+//
+//	@Summary		access the stock of a coffee
+//	@Schemes		http
+//	@Description	Request the beans left of a coffee, the recent daily usage and the estimated days remaining.
+//	@ID				get-coffee-stock
+//	@Tags			inventory
+//	@Param			id	path	string	true	"coffee ID"
+//	@Produce		json
+//	@Success		200	{object}	inventory.Stock
+//	@Failure		404	{ object }	map[string]string	"the coffee is unknown or no bags have been purchased"
+//	@Router			/coffees/{id}/stock [get]
+func GetCoffeeStock(service *inventory.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("inventory service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		stock, err := service.Stock(c.Param("id"), time.Now())
+		if err != nil {
+			respondInventoryError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, stock)
+	}
+}
+
+// GetInventory returns the stock of all coffees bags have been purchased for.
+//
+//	@Summary		list the bean stock
+//	@Schemes		http
+//	@Description	Lists the stock of all coffees, the ones running out first. With low=true, only the low stock alerts are listed.
+//	@ID				get-inventory
+//	@Tags			inventory
+//	@Param			low	query	bool	false	"lists only coffees that are low on stock"
+//	@Produce		json
+//	@Success		200	{array}		inventory.Stock
+//	@Failure		400	{ object }	map[string]string
+//	@Router			/inventory [get]
+func GetInventory(service *inventory.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("inventory service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		low, err := strconv.ParseBool(c.DefaultQuery("low", "false"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "low must be true or false"})
+			return
+		}
+		var stocks []inventory.Stock
+		if low {
+			stocks, err = service.LowStock(time.Now())
+		} else {
+			stocks, err = service.Stocks(time.Now())
+		}
+		if err != nil {
+			respondInventoryError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, stocks)
+	}
+}
+
+func respondInventoryError(c *gin.Context, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, inventory.ErrorInvalidProperty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrorNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coffee not found"})
+	case errors.Is(err, inventory.ErrorNotTracked):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No bags have been purchased for the coffee"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{})
+	}
+}
+
+type BagRequest struct {
+	Weight    float64    `json:"weight" binding:"required"` // grams of beans
+	Cost      float64    `json:"cost"`
+	Purchased time.Time  `json:"purchased"` // the purchase date, now by default
+	Roasted   *time.Time `json:"roasted"`   // the roast date printed on the bag, optional
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"coffy/internal/kiosk"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const maxSyncCommands = 500
+
+// Sync applies the queued commands of an offline client and returns the changes since its last checkpoint.
+//
+//	@Summary		synchronizes an offline client
+//	@Schemes		http
+//	@Description	Applies queued consumption commands idempotently and in order, and returns the account and coffee state changed since the checkpoint.
+//	@ID				sync-kiosk
+//	@Tags			kiosk
+//	@Param			request	body	SyncRequest	true	"queued commands and the last checkpoint"
+//	@Produce		json
+//	@Success		200	{object}	SyncResponse
+//	@Failure		400	{ object }	map[string]string
+//	@Router			/sync [post]
+func Sync(service *kiosk.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("kiosk service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &SyncRequest{}
+		if err := c.ShouldBindJSON(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(r.Commands) > maxSyncCommands {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d commands per sync", maxSyncCommands)})
+			return
+		}
+		checkpoint, err := decodeCheckpoint(r.Checkpoint)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		results, err := service.Apply(r.Commands)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		delta, err := service.Changes(checkpoint)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		response := SyncResponse{
+			Results:         results,
+			Accounts:        make([]AccountAlias, 0, len(delta.Accounts)),
+			RemovedAccounts: delta.Removed,
+			Checkpoint:      encodeCheckpoint(delta.Checkpoint),
+		}
+		for _, a := range delta.Accounts {
+			alias, err := convertAccount(&a)
+			if err != nil {
+				log.Println(err)
+				c.JSON(http.StatusInternalServerError, gin.H{})
+				return
+			}
+			response.Accounts = append(response.Accounts, alias)
+		}
+		if response.Coffees, err = allToCoffeeInfo(delta.Coffees); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func encodeCheckpoint(checkpoint int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(checkpoint)))
+}
+
+// decodeCheckpoint reads an opaque checkpoint, the empty checkpoint requests the complete state.
+func decodeCheckpoint(checkpoint string) (int, error) {
+	if checkpoint == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(checkpoint)
+	if err != nil {
+		return 0, errors.New("invalid checkpoint")
+	}
+	n, err := strconv.Atoi(string(data))
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid checkpoint")
+	}
+	return n, nil
+}
+
+type SyncRequest struct {
+	Checkpoint string          `json:"checkpoint"` // the checkpoint of the last sync, empty for the first sync
+	Commands   []kiosk.Command `json:"commands"`
+}
+
+type SyncResponse struct {
+	Results         []kiosk.Result `json:"results"`
+	Accounts        []AccountAlias `json:"accounts"`
+	RemovedAccounts []string       `json:"removed_accounts"`
+	Coffees         []CoffeeInfo   `json:"coffees"`
+	Checkpoint      string         `json:"checkpoint"`
+}
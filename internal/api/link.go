@@ -0,0 +1,333 @@
+package api
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/consume"
+	"coffy/internal/equipment"
+	"coffy/internal/link"
+	"coffy/internal/product"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//go:embed templates/quick.html
+var templateFiles embed.FS
+
+var quickTemplates = template.Must(template.ParseFS(templateFiles, "templates/quick.html"))
+
+// GetMachineQRCode renders the QR code of a machine, scanning it opens the consume page of the machine.
+//
+//	@Summary		QR code of a machine
+//	@Schemes		http
+//	@Description	Renders a QR code with a signed, expiring link to the consume page of the machine.
+//	@ID				get-machine-qr-code
+//	@Tags			machines
+//	@Param			id		path	string	true	"machine ID"
+//	@Param			format	query	string	false	"png (default) or svg"
+//	@Param			size	query	int		false	"width and height of the png in pixels, default is 256"
+//	@Produce		png,image/svg+xml
+//	@Success		200
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/machines/{id}/qrcode [get]
+func GetMachineQRCode(links *link.Links, machines *equipment.Service) func(*gin.Context) {
+	if links == nil || machines == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("links or machine service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		m, err := machines.FindById(c.Param("id"))
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, equipment.ErrorNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Machine not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		renderQRCode(c, links.URL(link.KindMachine, m.AggregateID, time.Now()))
+	}
+}
+
+// GetAccountQRCode renders the QR code of an account, scanning it opens the personal page of the account.
+//
+//	@Summary		QR code of an account
+//	@Schemes		http
+//	@Description	Renders a QR code with a signed, expiring link to the personal page of the account.
+//	@ID				get-account-qr-code
+//	@Tags			accounts
+//	@Param			id		path	string	true	"account ID"
+//	@Param			format	query	string	false	"png (default) or svg"
+//	@Param			size	query	int		false	"width and height of the png in pixels, default is 256"
+//	@Produce		png,image/svg+xml
+//	@Success		200
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/accounts/{id}/qrcode [get]
+func GetAccountQRCode(links *link.Links, accounting *account.Accounting) func(*gin.Context) {
+	if links == nil || accounting == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("links or account service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		a, err := accounting.Resolve(c.Param("id"))
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, account.ErrorNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		renderQRCode(c, links.URL(link.KindAccount, a.ID(), time.Now()))
+	}
+}
+
+// MachinePage shows the coffee loaded in a machine and lets the user choose the account to charge.
+func MachinePage(links *link.Links, machines *equipment.Service, coffees *product.Service, accounting *account.Accounting) func(*gin.Context) {
+	return func(c *gin.Context) {
+		token, ok := verifyLink(c, links, link.KindMachine)
+		if !ok {
+			return
+		}
+		m, err := machines.FindById(token.ID)
+		if err != nil {
+			renderPageError(c, err)
+			return
+		}
+		coffeeID, err := m.Coffee()
+		if err != nil {
+			renderPageError(c, err)
+			return
+		}
+		coffee, err := coffees.Find(coffeeID)
+		if err != nil {
+			renderPageError(c, err)
+			return
+		}
+		if coffee.Discontinued() {
+			renderPageError(c, product.ErrorDiscontinued)
+			return
+		}
+		accounts, err := accounting.Search(account.Query{Status: account.StatusActive, Sort: account.SortByOwner})
+		if err != nil {
+			renderPageError(c, err)
+			return
+		}
+		page := machinePage{Machine: machineName(m), Coffee: coffee.Type, Price: coffee.Price(), Key: uuid.NewString()}
+		for _, a := range accounts {
+			page.Accounts = append(page.Accounts, pageAccount{ID: a.ID(), Owner: a.Owner()})
+		}
+		renderPage(c, http.StatusOK, "machine", page)
+	}
+}
+
+// ConfirmMachineConsume charges the chosen account with the coffee loaded in the machine of the link.
+func ConfirmMachineConsume(links *link.Links, s *consume.Service, accounting *account.Accounting) func(*gin.Context) {
+	return func(c *gin.Context) {
+		token, ok := verifyLink(c, links, link.KindMachine)
+		if !ok {
+			return
+		}
+		quantity, err := strconv.Atoi(c.DefaultPostForm("quantity", "1"))
+		if err != nil {
+			renderPage(c, http.StatusBadRequest, "message", pageData{Error: "Invalid number of cups"})
+			return
+		}
+		order := consume.Order{AccountID: c.PostForm("account_id"), MachineID: token.ID, Quantity: quantity}
+		confirmConsume(c, s, accounting, c.PostForm("key"), order)
+	}
+}
+
+// AccountPage shows the balance of an account and lets the owner consume the coffee of any machine.
+func AccountPage(links *link.Links, accounting *account.Accounting, machines *equipment.Service, coffees *product.Service) func(*gin.Context) {
+	return func(c *gin.Context) {
+		token, ok := verifyLink(c, links, link.KindAccount)
+		if !ok {
+			return
+		}
+		a, err := accounting.Resolve(token.ID)
+		if err != nil {
+			renderPageError(c, err)
+			return
+		}
+		all, err := machines.ListAll()
+		if err != nil {
+			renderPageError(c, err)
+			return
+		}
+		page := accountPage{Owner: a.Owner(), Balance: a.Balance()}
+		for _, m := range all {
+			coffeeID, err := m.Coffee()
+			if err != nil {
+				continue
+			}
+			coffee, err := coffees.Find(coffeeID)
+			if err != nil {
+				renderPageError(c, err)
+				return
+			}
+			if coffee.Discontinued() {
+				continue
+			}
+			page.Machines = append(page.Machines, pageMachine{ID: m.AggregateID, Name: machineName(&m), Coffee: coffee.Type, Price: coffee.Price(), Key: uuid.NewString()})
+		}
+		renderPage(c, http.StatusOK, "account", page)
+	}
+}
+
+// ConfirmAccountConsume charges the account of the link with the coffee loaded in the chosen machine.
+func ConfirmAccountConsume(links *link.Links, s *consume.Service, accounting *account.Accounting) func(*gin.Context) {
+	return func(c *gin.Context) {
+		token, ok := verifyLink(c, links, link.KindAccount)
+		if !ok {
+			return
+		}
+		order := consume.Order{AccountID: token.ID, MachineID: c.PostForm("machine_id"), Quantity: 1}
+		confirmConsume(c, s, accounting, c.PostForm("key"), order)
+	}
+}
+
+// confirmConsume charges an order submitted by a page. The key of the form makes sure a page submitted
+// twice is charged once.
+func confirmConsume(c *gin.Context, s *consume.Service, accounting *account.Accounting, key string, order consume.Order) {
+	receipt, replayed, err := s.ConsumeOnce(key, order)
+	if err != nil {
+		log.Println(err)
+		status, message := consumeError(err)
+		if message == "" {
+			message = "Something went wrong, please try again"
+		}
+		renderPage(c, status, "message", pageData{Error: message})
+		return
+	}
+	a, err := accounting.Find(receipt.AccountID)
+	if err != nil {
+		renderPageError(c, err)
+		return
+	}
+	renderPage(c, http.StatusCreated, "receipt", receiptPage{Receipt: receipt, Replayed: replayed, Balance: a.Balance()})
+}
+
+func verifyLink(c *gin.Context, links *link.Links, kind link.Kind) (link.Token, bool) {
+	token, err := links.Verify(kind, c.Param("token"), time.Now())
+	switch {
+	case errors.Is(err, link.ErrorTokenExpired):
+		renderPage(c, http.StatusGone, "message", pageData{Error: "This QR code has expired, please ask for a new one"})
+		return token, false
+	case err != nil:
+		renderPage(c, http.StatusForbidden, "message", pageData{Error: "This QR code is not valid"})
+		return token, false
+	}
+	return token, true
+}
+
+func renderPageError(c *gin.Context, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, equipment.ErrorNoCoffeeLoaded):
+		renderPage(c, http.StatusConflict, "message", pageData{Error: "No coffee loaded in the machine"})
+	case errors.Is(err, product.ErrorDiscontinued):
+		renderPage(c, http.StatusConflict, "message", pageData{Error: "Coffee has been discontinued"})
+	case errors.Is(err, equipment.ErrorNotFound):
+		renderPage(c, http.StatusNotFound, "message", pageData{Error: "Machine not found"})
+	case errors.Is(err, account.ErrorNotFound):
+		renderPage(c, http.StatusNotFound, "message", pageData{Error: "Account not found"})
+	default:
+		renderPage(c, http.StatusInternalServerError, "message", pageData{Error: "Something went wrong, please try again"})
+	}
+}
+
+func renderPage(c *gin.Context, status int, name string, data any) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := quickTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
+		log.Println(err)
+	}
+}
+
+func renderQRCode(c *gin.Context, url string) {
+	switch c.DefaultQuery("format", "png") {
+	case "png":
+		size, err := strconv.Atoi(c.DefaultQuery("size", "256"))
+		if err != nil || size < 64 || size > 2048 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 64 and 2048"})
+			return
+		}
+		png, err := link.PNG(url, size)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.Data(http.StatusOK, "image/png", png)
+	case "svg":
+		svg, err := link.SVG(url)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.Data(http.StatusOK, "image/svg+xml", svg)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be 'png' or 'svg'"})
+	}
+}
+
+func machineName(m *equipment.Machine) string {
+	return fmt.Sprintf("%s %s", m.Brand, m.Model)
+}
+
+type pageData struct {
+	Error string
+}
+
+type pageAccount struct {
+	ID    string
+	Owner string
+}
+
+type pageMachine struct {
+	ID     string
+	Name   string
+	Coffee string
+	Price  float64
+	Key    string // the idempotency key of the form
+}
+
+type machinePage struct {
+	pageData
+	Machine  string
+	Coffee   string
+	Price    float64
+	Key      string // the idempotency key of the form
+	Accounts []pageAccount
+}
+
+type accountPage struct {
+	pageData
+	Owner    string
+	Balance  float64
+	Machines []pageMachine
+}
+
+type receiptPage struct {
+	pageData
+	Receipt  *consume.Receipt
+	Replayed bool
+	Balance  float64
+}
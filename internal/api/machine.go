@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
+	"strings"
 )
 
 type MachineAlias struct {
@@ -16,15 +17,18 @@ type MachineAlias struct {
 	CoffeeID  string `json:"coffee_id"`
 }
 
-// GetMachines lists all available machines in coffy.
+// GetMachines lists all available machines in coffy, one page at a time.
 //
 //	@Summary		list all machines
 //	@Schemes		http
-//	@Description	Lists all available machines in coffy.
+//	@Description	Lists all available machines in coffy, sorted by brand and model.
 //	@ID				list-machines
 //	@Tags			machines
+//	@Param			cursor	query	string	false	"cursor of the next page from the previous response"
+//	@Param			limit	query	int		false	"page size, 50 by default, 200 at most"
 //	@Produce		json
-//	@Success		200	{array}	MachineAlias
+//	@Success		200	{object}	Page[MachineAlias]
+//	@Failure		400	{ object }	map[string]string
 //	@Router			/machines [get]
 func GetMachines(service *equipment.Service) func(*gin.Context) {
 	if service == nil {
@@ -34,14 +38,27 @@ func GetMachines(service *equipment.Service) func(*gin.Context) {
 		}
 	}
 	return func(c *gin.Context) {
+		pageReq, err := parsePageRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		machines, err := service.ListAll()
 		if err != nil {
 			log.Println(err)
 			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		page, err := paginate(machines, func(m equipment.Machine) namedPosition {
+			return namedPosition{Name: strings.ToLower(machineName(&m)), ID: m.AggregateID}
+		}, compareNamed, pageReq)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		alias := make([]MachineAlias, 0)
-		for _, m := range machines {
-			alias = append(alias, toAlias(m))
+		alias := Page[MachineAlias]{Items: make([]MachineAlias, 0, len(page.Items)), Total: page.Total, NextCursor: page.NextCursor}
+		for _, m := range page.Items {
+			alias.Items = append(alias.Items, toAlias(m))
 		}
 		c.JSON(http.StatusOK, alias)
 	}
@@ -96,11 +113,12 @@ func CreateMachine(service *equipment.Service) func(*gin.Context) {
 //	@Produce		json
 //	@Success		200	{object}	MachineAlias
 //	@Failure		404	{ object }	map[string]string
+//	@Failure		409	{ object }	map[string]string	"the coffee has been discontinued"
 //	@Router			/machines/{id} [patch]
-func PatchMachines(service *equipment.Service, coffeeService *product.Service) func(*gin.Context) {
-	if service == nil || coffeeService == nil {
+func PatchMachines(service *equipment.Service) func(*gin.Context) {
+	if service == nil {
 		return func(c *gin.Context) {
-			log.Println(errors.New("machine service or coffee service is nil"))
+			log.Println(errors.New("machine service is nil"))
 			c.JSON(http.StatusInternalServerError, gin.H{})
 			return
 		}
@@ -113,16 +131,19 @@ func PatchMachines(service *equipment.Service, coffeeService *product.Service) f
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
 			return
 		}
-		coffee, err := coffeeService.Find(request.CoffeeID)
-		if err != nil {
-			log.Println(err)
-			c.JSON(http.StatusNotFound, "unknown resource: coffee not found")
-			return
-		}
-		machine, err := service.LoadCoffee(machineId, coffee.AggregateID)
+		machine, err := service.LoadCoffee(machineId, request.CoffeeID)
 		if err != nil {
 			log.Println(err)
-			c.JSON(http.StatusInternalServerError, gin.H{})
+			switch {
+			case errors.Is(err, product.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Coffee not found"})
+			case errors.Is(err, equipment.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Machine not found"})
+			case errors.Is(err, product.ErrorDiscontinued):
+				c.JSON(http.StatusConflict, gin.H{"error": "Coffee has been discontinued"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
 			return
 		}
 		c.JSON(http.StatusOK, toAlias(*machine))
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"slices"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+var errorInvalidCursor = errors.New("invalid cursor")
+
+// Page is the envelope of all paginated listings. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"` // the number of items matching the request across all pages
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// PageRequest holds the pagination parameters of a listing request.
+type PageRequest struct {
+	Cursor string // the opaque cursor of the previous page, empty for the first page
+	Limit  int    // the maximal number of items on the page
+}
+
+// parsePageRequest reads the query parameters 'cursor' and 'limit'.
+func parsePageRequest(c *gin.Context) (PageRequest, error) {
+	req := PageRequest{Cursor: c.Query("cursor"), Limit: defaultPageLimit}
+	if limit := c.Query("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 1 || l > maxPageLimit {
+			return PageRequest{}, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+		req.Limit = l
+	}
+	return req, nil
+}
+
+// paginate sorts the items by their position and cuts the page described by req out of them.
+//
+// The cursor encodes the position of the last item on the previous page, i.e. the values it is sorted
+// by and its ID, and the next page starts at the first item after that position. Pages therefore neither
+// repeat nor skip items that have not changed, even if the last item has been changed or removed in between.
+func paginate[T any, P any](items []T, position func(T) P, compare func(P, P) int, req PageRequest) (Page[T], error) {
+	slices.SortStableFunc(items, func(a T, b T) int { return compare(position(a), position(b)) })
+	start := 0
+	if req.Cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(req.Cursor)
+		if err != nil {
+			return Page[T]{}, errorInvalidCursor
+		}
+		var last P
+		if err := json.Unmarshal(data, &last); err != nil {
+			return Page[T]{}, errorInvalidCursor
+		}
+		start = len(items)
+		if i := slices.IndexFunc(items, func(item T) bool { return compare(position(item), last) > 0 }); i >= 0 {
+			start = i
+		}
+	}
+	end := min(start+req.Limit, len(items))
+	page := Page[T]{Items: append(make([]T, 0, end-start), items[start:end]...), Total: len(items)}
+	if end < len(items) {
+		data, err := json.Marshal(position(items[end-1]))
+		if err != nil {
+			return Page[T]{}, err
+		}
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+	return page, nil
+}
+
+// namedPosition orders items by their case-insensitive name and their ID.
+type namedPosition struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+func compareNamed(a namedPosition, b namedPosition) int {
+	return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+}
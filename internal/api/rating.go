@@ -0,0 +1,165 @@
+package api
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/product"
+	"coffy/internal/rating"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// RateCoffee records the rating of an account for a coffee. Rating a coffee again replaces the previous rating.
+//
+//	@Summary		rate a coffee
+//	@Schemes		http
+//	@Description	Rates a coffee with 1 to 5 stars and short tasting notes, one rating per account.
+//	@ID				rate-coffee
+//	@Tags			coffees
+//	@Param			id		path	string				true	"coffee ID"
+//	@Param			request	body	RatingRequest		true	"rating request"
+//	@Produce		json
+//	@Success		200	{object}	rating.Rating	"the previous rating of the account has been replaced"
+//	@Success		201	{object}	rating.Rating
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/ratings [post]
+func RateCoffee(service *rating.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("rating service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		r := &RatingRequest{}
+		if err := c.ShouldBindJSON(r); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rated, replaced, err := service.Rate(c.Param("id"), r.AccountID, r.Stars, r.Notes)
+		if err != nil {
+			respondRatingError(c, err)
+			return
+		}
+		if replaced {
+			c.JSON(http.StatusOK, rated)
+			return
+		}
+		c.JSON(http.StatusCreated, rated)
+	}
+}
+
+// GetCoffeeRatings returns the ratings of a coffee.
+//
+//	@Summary		list the ratings of a coffee
+//	@Schemes		http
+//	@Description	Lists the current rating of each account with the tasting notes, the latest first.
+//	@ID				get-coffee-ratings
+//	@Tags			coffees
+//	@Param			id	path	string	true	"coffee ID"
+//	@Produce		json
+//	@Success		200	{array}		rating.Rating
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/coffees/{id}/ratings [get]
+func GetCoffeeRatings(service *rating.Service, coffees *product.Service) func(*gin.Context) {
+	if service == nil || coffees == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("rating or coffee service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		if _, err := coffees.Find(c.Param("id")); err != nil {
+			respondRatingError(c, err)
+			return
+		}
+		ratings, err := service.Ratings(c.Param("id"))
+		if err != nil {
+			respondRatingError(c, err)
+			return
+		}
+		if ratings == nil {
+			ratings = []rating.Rating{}
+		}
+		c.JSON(http.StatusOK, ratings)
+	}
+}
+
+// GetTopRatedCoffees returns the coffees with the best average rating, including discontinued coffees.
+//
+//	@Summary		list the top rated coffees
+//	@Schemes		http
+//	@Description	Lists the coffees ordered by their average rating, the best first.
+//	@ID				get-top-rated-coffees
+//	@Tags			coffees
+//	@Param			limit		query	int	false	"number of coffees, 10 by default"
+//	@Param			min_ratings	query	int	false	"minimum number of ratings of a listed coffee, 1 by default"
+//	@Produce		json
+//	@Success		200	{array}		CoffeeInfo
+//	@Failure		400	{ object }	map[string]string
+//	@Router			/coffees/top-rated [get]
+func GetTopRatedCoffees(service *rating.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("rating service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+		minRatings, err := strconv.Atoi(c.DefaultQuery("min_ratings", "1"))
+		if err != nil || minRatings < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_ratings must be a positive number"})
+			return
+		}
+		top, summaries, err := service.TopRated(limit, minRatings)
+		if err != nil {
+			respondRatingError(c, err)
+			return
+		}
+		list, err := allToCoffeeInfo(top)
+		if err != nil {
+			log.Println("conversion to coffee info failed:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, withRatings(list, summaries))
+	}
+}
+
+func respondRatingError(c *gin.Context, err error) {
+	log.Println(err)
+	switch {
+	case errors.Is(err, rating.ErrorInvalidProperty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, product.ErrorNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coffee not found"})
+	case errors.Is(err, account.ErrorNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{})
+	}
+}
+
+// withRatings adds the aggregated ratings to the coffees that have been rated.
+func withRatings(list []CoffeeInfo, summaries map[string]rating.Summary) []CoffeeInfo {
+	for i := range list {
+		if summary, ok := summaries[list[i].ID]; ok {
+			list[i].Rating = &summary
+		}
+	}
+	return list
+}
+
+type RatingRequest struct {
+	AccountID string `json:"account_id" binding:"required"`
+	Stars     int    `json:"stars" binding:"required"` // from 1 (worst) to 5 (best)
+	Notes     string `json:"notes"`                    // short tasting notes
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/report"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetSubsidyReport returns the subsidised amounts of all consumptions per period.
+//
+//	@Summary		report subsidies
+//	@Schemes		http
+//	@Description	Summarises charged and subsidised amounts per period, including cash sales to guests, e.g. for the finance department.
+//	@ID				get-subsidy-report
+//	@Tags			reports
+//	@Param			from	query	string	false	"start date (inclusive), e.g. 2025-01-01. Default is the start of the current year"
+//	@Param			to		query	string	false	"end date (exclusive), e.g. 2025-02-01. Default is tomorrow"
+//	@Param			period	query	string	false	"aggregation period: day, week or month (default)"
+//	@Produce		json
+//	@Success		200	{object}	report.SubsidyReport
+//	@Failure		400	{ object }	map[string]string
+//	@Router			/reports/subsidies [get]
+func GetSubsidyReport(service *report.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("report service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		from, to, err := parseDateRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		period, err := report.ParsePeriod(c.Query("period"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		r, err := service.Subsidies(from, to, period)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, r)
+	}
+}
+
+// GetCostReport returns the actual costs per cup of each coffee and the price that achieves the target margin.
+//
+//	@Summary		report costs per cup
+//	@Schemes		http
+//	@Description	Computes the costs per cup of each coffee from the bean purchases, maintenance payments and consumptions, and recommends a price for a target margin.
+//	@ID				get-cost-report
+//	@Tags			reports
+//	@Param			from	query	string	false	"start date (inclusive), e.g. 2025-01-01. Default is the start of the current year"
+//	@Param			to		query	string	false	"end date (exclusive), e.g. 2025-02-01. Default is tomorrow"
+//	@Param			margin	query	number	false	"target margin in percent of the price. Default is the configured margin"
+//	@Produce		json
+//	@Success		200	{object}	report.CostReport
+//	@Failure		400	{ object }	map[string]string
+//	@Router			/reports/costs [get]
+func GetCostReport(service *report.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("report service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		from, to, err := parseDateRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		margin := service.TargetMargin()
+		if value := c.Query("margin"); value != "" {
+			if margin, err = strconv.ParseFloat(value, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid margin '%s'", value)})
+				return
+			}
+		}
+		r, err := service.Costs(from, to, margin)
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, report.ErrorInvalidProperty):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, r)
+	}
+}
+
+// GetAccountStats returns the consumption habits of an account.
+//
+//	@Summary		account statistics
+//	@Schemes		http
+//	@Description	Summarises cups and spending of an account per coffee type, day, week and month.
+//	@ID				get-account-stats
+//	@Tags			accounts
+//	@Param			id		path	string	true	"account ID"
+//	@Param			from	query	string	false	"start date (inclusive), e.g. 2025-01-01. Default is the start of the current year"
+//	@Param			to		query	string	false	"end date (exclusive), e.g. 2025-02-01. Default is tomorrow"
+//	@Produce		json
+//	@Success		200	{object}	report.AccountStats
+//	@Failure		400	{ object }	map[string]string
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/accounts/{id}/stats [get]
+func GetAccountStats(service *report.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("report service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		from, to, err := parseDateRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		stats, err := service.Stats(c.Param("id"), from, to)
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, account.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// parseDateRange reads the optional query parameters 'from' and 'to' as dates in local time.
+// The range defaults to the start of the current year until the end of today.
+func parseDateRange(c *gin.Context) (time.Time, time.Time, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	from := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.Local)
+	to := today.AddDate(0, 0, 1)
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, value, time.Local); err != nil {
+			return from, to, fmt.Errorf("invalid date '%s', expected format YYYY-MM-DD", value)
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, value, time.Local); err != nil {
+			return from, to, fmt.Errorf("invalid date '%s', expected format YYYY-MM-DD", value)
+		}
+	}
+	if !from.Before(to) {
+		return from, to, errors.New("'from' must be before 'to'")
+	}
+	return from, to, nil
+}
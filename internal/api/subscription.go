@@ -0,0 +1,184 @@
+package api
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/subscription"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+// GetPlans lists all available subscription plans.
+//
+//	@Summary		list all subscription plans
+//	@Schemes		http
+//	@Description	Lists all available flat-rate subscription plans.
+//	@ID				list-plans
+//	@Tags			subscriptions
+//	@Produce		json
+//	@Success		200	{array}	PlanAlias
+//	@Router			/plans [get]
+func GetPlans(service *subscription.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("subscription service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		plans, err := service.ListAll()
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		alias := make([]PlanAlias, 0)
+		for _, p := range plans {
+			alias = append(alias, toPlanAlias(&p))
+		}
+		c.JSON(http.StatusOK, alias)
+	}
+}
+
+// CreatePlan creates a new flat-rate subscription plan.
+//
+//	@Summary		create a subscription plan
+//	@Schemes		http
+//	@Description	Creates a new flat-rate subscription plan with a monthly fee, an optional cup cap and overage price.
+//	@ID				create-plan
+//	@Tags			subscriptions
+//	@Param			request	body	PlanCreationRequest	true	"plan creation request"
+//	@Produce		json
+//	@Success		201	{object}	PlanAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Router			/plans [post]
+func CreatePlan(service *subscription.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("subscription service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		var request PlanCreationRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		p, err := service.Create(request.Name, request.MonthlyFee, request.CupCap, request.OveragePrice)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, toPlanAlias(p))
+	}
+}
+
+// PutAccountSubscription subscribes an account to a plan.
+//
+//	@Summary		subscribe an account
+//	@Schemes		http
+//	@Description	Subscribes an account to a flat-rate plan. An existing subscription is replaced. Cups are covered once the fee of a billing period has been charged.
+//	@ID				subscribe-account
+//	@Tags			subscriptions
+//	@Param			request	body	SubscriptionRequest	true	"subscription request"
+//	@Param			id		path	string				true	"account ID"
+//	@Produce		json
+//	@Success		200	{object}	AccountAlias
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/accounts/{id}/subscription [put]
+func PutAccountSubscription(service *subscription.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("subscription service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		var request SubscriptionRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		acc, err := service.Subscribe(c.Param("id"), request.PlanID)
+		respondSubscription(c, acc, err)
+	}
+}
+
+// DeleteAccountSubscription ends the subscription of an account.
+//
+//	@Summary		unsubscribe an account
+//	@Schemes		http
+//	@Description	Ends the subscription of an account, following cups are charged per cup again.
+//	@ID				unsubscribe-account
+//	@Tags			subscriptions
+//	@Param			id	path	string	true	"account ID"
+//	@Produce		json
+//	@Success		200	{object}	AccountAlias
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/accounts/{id}/subscription [delete]
+func DeleteAccountSubscription(service *subscription.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("subscription service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		acc, err := service.Unsubscribe(c.Param("id"))
+		respondSubscription(c, acc, err)
+	}
+}
+
+func respondSubscription(c *gin.Context, acc *account.Account, err error) {
+	if err != nil {
+		log.Println(err)
+		switch {
+		case errors.Is(err, account.ErrorNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		case errors.Is(err, subscription.ErrorNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "plan not found"})
+		case errors.Is(err, account.ErrorNotSubscribed):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+		return
+	}
+	alias, err := convertAccount(acc)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	c.JSON(http.StatusOK, alias)
+}
+
+type PlanAlias struct {
+	ID           string  `json:"id"`
+	Name         string  `json:"name"`
+	MonthlyFee   float64 `json:"monthly_fee"`
+	CupCap       int     `json:"cup_cap"`
+	OveragePrice float64 `json:"overage_price"`
+}
+
+type PlanCreationRequest struct {
+	Name         string  `json:"name" binding:"required"`
+	MonthlyFee   float64 `json:"monthly_fee" binding:"required"`
+	CupCap       int     `json:"cup_cap"`       // zero means unlimited
+	OveragePrice float64 `json:"overage_price"` // zero means the regular price applies
+}
+
+type SubscriptionRequest struct {
+	PlanID string `json:"plan_id" binding:"required"`
+}
+
+func toPlanAlias(p *subscription.Plan) PlanAlias {
+	cupCap, _ := p.CupCap()
+	overage, _ := p.OveragePrice()
+	return PlanAlias{ID: p.AggregateID, Name: p.Name, MonthlyFee: p.Fee(), CupCap: cupCap, OveragePrice: overage}
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/voucher"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"time"
+)
+
+// GetVoucherBatches lists all issued voucher batches.
+//
+//	@Summary		list all voucher batches
+//	@Schemes		http
+//	@Description	Lists all issued voucher batches with their redemption status.
+//	@ID				list-voucher-batches
+//	@Tags			vouchers
+//	@Produce		json
+//	@Success		200	{array}	VoucherBatchAlias
+//	@Router			/vouchers [get]
+func GetVoucherBatches(service *voucher.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("voucher service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		batches, err := service.ListAll()
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		alias := make([]VoucherBatchAlias, 0)
+		now := time.Now()
+		for _, b := range batches {
+			alias = append(alias, toVoucherBatchAlias(&b, now, false))
+		}
+		c.JSON(http.StatusOK, alias)
+	}
+}
+
+// GetVoucherBatch returns a voucher batch including all of its codes.
+//
+//	@Summary		access a voucher batch by ID
+//	@Schemes		http
+//	@Description	Request a voucher batch including all of its codes.
+//	@ID				get-voucher-batch
+//	@Tags			vouchers
+//	@Param			id	path	string	true	"voucher batch ID"
+//	@Produce		json
+//	@Success		200	{object}	VoucherBatchAlias
+//	@Failure		404	{ object }	map[string]string
+//	@Router			/vouchers/{id} [get]
+func GetVoucherBatch(service *voucher.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("voucher service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		b, err := service.Find(c.Param("id"))
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, voucher.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "voucher batch not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, toVoucherBatchAlias(b, time.Now(), true))
+	}
+}
+
+// CreateVoucherBatch generates a new batch of one-time voucher codes.
+//
+//	@Summary		issue vouchers
+//	@Schemes		http
+//	@Description	Generates a batch of one-time voucher codes with a value.
+//	@ID				create-voucher-batch
+//	@Tags			vouchers
+//	@Param			request	body	VoucherBatchRequest	true	"voucher batch request"
+//	@Produce		json
+//	@Success		201	{object}	VoucherBatchAlias
+//	@Failure		400	{ object }	map[string]string
+//	@Router			/vouchers [post]
+func CreateVoucherBatch(service *voucher.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("voucher service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		var request VoucherBatchRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var expiresOn time.Time
+		if request.ExpiresOn != nil {
+			expiresOn = *request.ExpiresOn
+		}
+		b, err := service.Issue(request.Value, request.Count, expiresOn)
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, voucher.ErrorInvalidProperty):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		c.JSON(http.StatusCreated, toVoucherBatchAlias(b, time.Now(), true))
+	}
+}
+
+// RedeemVoucher redeems a voucher code and tops up the account with the voucher's value.
+//
+//	@Summary		redeem a voucher
+//	@Schemes		http
+//	@Description	Redeems a one-time voucher code for an account.
+//	@ID				redeem-voucher
+//	@Tags			accounts
+//	@Param			request	body	RedeemRequest	true	"voucher redemption request"
+//	@Param			id		path	string			true	"account ID"
+//	@Produce		json
+//	@Success		200	{object}	AccountAlias
+//	@Failure		404	{ object }	map[string]string
+//	@Failure		409	{ object }	map[string]string
+//	@Router			/accounts/{id}/redeem [post]
+func RedeemVoucher(service *voucher.Service) func(*gin.Context) {
+	if service == nil {
+		return func(c *gin.Context) {
+			log.Println(errors.New("voucher service is nil"))
+			c.JSON(http.StatusInternalServerError, gin.H{})
+		}
+	}
+	return func(c *gin.Context) {
+		var request RedeemRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		acc, err := service.Redeem(c.Param("id"), request.Code)
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, account.ErrorNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			case errors.Is(err, voucher.ErrorUnknownCode):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			case errors.Is(err, voucher.ErrorAlreadyRedeemed), errors.Is(err, voucher.ErrorExpired):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{})
+			}
+			return
+		}
+		alias, err := convertAccount(acc)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, alias)
+	}
+}
+
+type VoucherBatchRequest struct {
+	Value     float64    `json:"value" binding:"required"`
+	Count     int        `json:"count" binding:"required,min=1,max=1000"` // at most voucher.MaxBatchSize
+	ExpiresOn *time.Time `json:"expires_on,omitempty"`
+}
+
+type RedeemRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type VoucherBatchAlias struct {
+	ID        string         `json:"id"`
+	Value     float64        `json:"value"`
+	ExpiresOn *time.Time     `json:"expires_on,omitempty"`
+	Issued    int            `json:"issued"`
+	Redeemed  int            `json:"redeemed"`
+	Expired   int            `json:"expired"`
+	Vouchers  []VoucherAlias `json:"vouchers,omitempty"`
+}
+
+type VoucherAlias struct {
+	Code       string     `json:"code"`
+	RedeemedBy string     `json:"redeemed_by,omitempty"`
+	RedeemedOn *time.Time `json:"redeemed_on,omitempty"`
+}
+
+func toVoucherBatchAlias(b *voucher.Batch, now time.Time, withCodes bool) VoucherBatchAlias {
+	alias := VoucherBatchAlias{
+		ID:       b.AggregateID,
+		Value:    b.Value(),
+		Issued:   b.Issued(),
+		Redeemed: b.Redeemed(),
+		Expired:  b.Expired(now),
+	}
+	if expiresOn, ok := b.ExpiresOn(); ok {
+		alias.ExpiresOn = &expiresOn
+	}
+	if !withCodes {
+		return alias
+	}
+	for _, v := range b.Vouchers() {
+		entry := VoucherAlias{Code: v.Code, RedeemedBy: v.RedeemedBy}
+		if v.Redeemed() {
+			redeemedOn := v.RedeemedOn
+			entry.RedeemedOn = &redeemedOn
+		}
+		alias.Vouchers = append(alias.Vouchers, entry)
+	}
+	return alias
+}
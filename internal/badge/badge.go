@@ -0,0 +1,226 @@
+package badge
+
+import (
+	"coffy/internal/event"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrorInvalidUID = errors.New("badge UID must have 4 to 32 letters or digits")
+
+// Status is the state of a badge.
+type Status string
+
+const (
+	StatusPending Status = "pending" // the badge has been scanned, but is not registered yet
+	StatusActive  Status = "active"  // the badge identifies an account
+	StatusRevoked Status = "revoked" // the badge has been returned and can be registered again
+	StatusLost    Status = "lost"    // the badge has been lost and stays blocked
+)
+
+// Badge maps the UID of an RFID/NFC badge to an account.
+type Badge struct {
+	UID       string        // the normalized UID of the badge
+	accountID string        // the account the badge identifies, empty if not registered
+	status    Status        // the current state of the badge
+	seen      time.Time     // the first time an unregistered badge has been scanned
+	events    []event.Event // uncommitted events of the aggregate
+}
+
+// NormalizeUID removes separators from a UID and converts it to upper case, e.g. '04:a2:2b' to '04A22B'.
+func NormalizeUID(uid string) (string, error) {
+	normalized := strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == ':' || r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, uid))
+	if len(normalized) < 4 || len(normalized) > 32 {
+		return "", ErrorInvalidUID
+	}
+	for _, r := range normalized {
+		if (r < '0' || r > '9') && (r < 'A' || r > 'Z') {
+			return "", ErrorInvalidUID
+		}
+	}
+	return normalized, nil
+}
+
+func aggregateID(uid string) string {
+	return "badge:" + uid
+}
+
+func newBadge(uid string) *Badge {
+	return &Badge{UID: uid}
+}
+
+// AccountID returns the account of an active badge.
+func (b *Badge) AccountID() (string, bool) {
+	return b.accountID, b.status == StatusActive
+}
+
+// Status returns the current state of the badge, empty for a badge that is unknown.
+func (b *Badge) Status() Status {
+	return b.status
+}
+
+// Seen returns the time an unregistered badge has been scanned first.
+func (b *Badge) Seen() time.Time {
+	return b.seen
+}
+
+// Scan records that an unregistered badge has been scanned, so it can be claimed by an admin.
+// Scanning a badge that is pending already does not change it.
+func (b *Badge) Scan(t time.Time) error {
+	if b.status != "" {
+		return nil
+	}
+	return b.apply(BadgeScanned{ID: aggregateID(b.UID), OccurredOn: t, EventType: "BadgeScanned", UID: b.UID})
+}
+
+// Register assigns the badge to an account. Lost badges cannot be registered again.
+func (b *Badge) Register(accountID string) error {
+	switch b.status {
+	case StatusActive:
+		return fmt.Errorf("badge is registered already")
+	case StatusLost:
+		return fmt.Errorf("badge has been lost")
+	}
+	if accountID == "" {
+		return fmt.Errorf("account cannot be empty")
+	}
+	return b.apply(BadgeRegistered{ID: aggregateID(b.UID), OccurredOn: time.Now(), EventType: "BadgeRegistered", UID: b.UID, AccountID: accountID})
+}
+
+// Revoke releases an active badge from its account, e.g. when it has been returned.
+func (b *Badge) Revoke() error {
+	if b.status != StatusActive {
+		return fmt.Errorf("badge is not registered")
+	}
+	return b.apply(BadgeRevoked{ID: aggregateID(b.UID), OccurredOn: time.Now(), EventType: "BadgeRevoked", AccountID: b.accountID})
+}
+
+// ReportLost blocks an active badge for good.
+func (b *Badge) ReportLost() error {
+	if b.status != StatusActive {
+		return fmt.Errorf("badge is not registered")
+	}
+	return b.apply(BadgeLost{ID: aggregateID(b.UID), OccurredOn: time.Now(), EventType: "BadgeLost", AccountID: b.accountID})
+}
+
+// Events returns all uncommitted events of the badge.
+func (b *Badge) Events() []event.Event {
+	return b.events
+}
+
+// Clear empties the event cache of the badge.
+func (b *Badge) Clear() {
+	b.events = []event.Event{}
+}
+
+func (b *Badge) apply(e event.Event) error {
+	if e.AggregateID() != aggregateID(b.UID) {
+		return fmt.Errorf("event does not belong to this aggregate")
+	}
+	switch theEvent := e.(type) {
+	case BadgeScanned:
+		b.status = StatusPending
+		b.seen = theEvent.OccurredOn
+	case BadgeRegistered:
+		b.status = StatusActive
+		b.accountID = theEvent.AccountID
+	case BadgeRevoked:
+		b.status = StatusRevoked
+		b.accountID = ""
+	case BadgeLost:
+		b.status = StatusLost
+		b.accountID = ""
+	default:
+		return fmt.Errorf("unknown event type '%T'", theEvent)
+	}
+	b.events = append(b.events, e)
+	return nil
+}
+
+// The BadgeScanned event records the first scan of an unregistered badge.
+type BadgeScanned struct {
+	ID         string    `json:"id"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	UID        string    `json:"uid"`
+}
+
+func (e BadgeScanned) AggregateID() string {
+	return e.ID
+}
+
+func (e BadgeScanned) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e BadgeScanned) Type() string {
+	return e.EventType
+}
+
+// The BadgeRegistered event assigns a badge to an account.
+type BadgeRegistered struct {
+	ID         string    `json:"id"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	UID        string    `json:"uid"`
+	AccountID  string    `json:"accountID"`
+}
+
+func (e BadgeRegistered) AggregateID() string {
+	return e.ID
+}
+
+func (e BadgeRegistered) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e BadgeRegistered) Type() string {
+	return e.EventType
+}
+
+// The BadgeRevoked event releases a badge from its account.
+type BadgeRevoked struct {
+	ID         string    `json:"id"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	AccountID  string    `json:"accountID"` // the account the badge was registered for
+}
+
+func (e BadgeRevoked) AggregateID() string {
+	return e.ID
+}
+
+func (e BadgeRevoked) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e BadgeRevoked) Type() string {
+	return e.EventType
+}
+
+// The BadgeLost event blocks a badge that has been lost.
+type BadgeLost struct {
+	ID         string    `json:"id"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	AccountID  string    `json:"accountID"` // the account the badge was registered for
+}
+
+func (e BadgeLost) AggregateID() string {
+	return e.ID
+}
+
+func (e BadgeLost) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e BadgeLost) Type() string {
+	return e.EventType
+}
@@ -0,0 +1,79 @@
+package badge
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/storage/storagetest"
+	"errors"
+	"testing"
+)
+
+func TestNormalizeUID(t *testing.T) {
+	tests := map[string]string{"04:a2:2b:7c": "04A22B7C", "04-A2-2B-7C": "04A22B7C", "04 a2 2b 7c": "04A22B7C"}
+	for uid, expected := range tests {
+		if normalized, err := NormalizeUID(uid); err != nil || normalized != expected {
+			t.Errorf("Expected '%s' for '%s', got '%s' (%v)", expected, uid, normalized, err)
+		}
+	}
+	for _, uid := range []string{"", "04a", "04:a2:2b:7c/", "0123456789abcdef0123456789abcdef0"} {
+		if _, err := NormalizeUID(uid); !errors.Is(err, ErrorInvalidUID) {
+			t.Errorf("Expected ErrorInvalidUID for '%s', got %v", uid, err)
+		}
+	}
+}
+
+func TestBadgeLifecycle(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	s := NewService(&repo, accounting)
+	a, err := accounting.Create("Coffy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Identify("04:a2:2b:7c"); !errors.Is(err, ErrorUnknownBadge) {
+		t.Fatalf("Expected ErrorUnknownBadge, got %v", err)
+	}
+	pending, err := s.Pending()
+	if err != nil || len(pending) != 1 || pending[0].UID != "04A22B7C" {
+		t.Fatalf("Expected the scanned badge to be pending, got %+v (%v)", pending, err)
+	}
+
+	if _, err := s.Register("04a22b7c", a.ID()); err != nil {
+		t.Fatalf("Error claiming badge: %s", err.Error())
+	}
+	if owner, err := s.Identify("04-A2-2B-7C"); err != nil || owner != a.ID() {
+		t.Errorf("Expected badge to identify '%s', got '%s' (%v)", a.ID(), owner, err)
+	}
+	if pending, _ := s.Pending(); len(pending) != 0 {
+		t.Errorf("Expected no pending badges after claiming, got %d", len(pending))
+	}
+	if _, err := s.Register("04a22b7c", a.ID()); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("Expected registering an active badge to fail, got %v", err)
+	}
+
+	if _, err := s.Revoke("04a22b7c"); err != nil {
+		t.Fatalf("Error revoking badge: %s", err.Error())
+	}
+	if _, err := s.Identify("04a22b7c"); !errors.Is(err, ErrorRevoked) {
+		t.Errorf("Expected ErrorRevoked, got %v", err)
+	}
+	if _, err := s.Register("04a22b7c", a.ID()); err != nil {
+		t.Fatalf("Expected revoked badge to be registered again, got %v", err)
+	}
+
+	if _, err := s.ReportLost("04a22b7c"); err != nil {
+		t.Fatalf("Error reporting badge lost: %s", err.Error())
+	}
+	if _, err := s.Identify("04a22b7c"); !errors.Is(err, ErrorLost) {
+		t.Errorf("Expected ErrorLost, got %v", err)
+	}
+	if _, err := s.Register("04a22b7c", a.ID()); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("Expected lost badge not to be registered again, got %v", err)
+	}
+	if _, err := s.Revoke("ffffffff"); !errors.Is(err, ErrorNotFound) {
+		t.Errorf("Expected ErrorNotFound for unknown badge, got %v", err)
+	}
+	if _, err := s.Register("ffffffff", "unknown"); !errors.Is(err, account.ErrorNotFound) {
+		t.Errorf("Expected account.ErrorNotFound, got %v", err)
+	}
+}
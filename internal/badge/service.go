@@ -0,0 +1,271 @@
+package badge
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/event"
+	"coffy/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrorInvalidProperty = errors.New("invalid property")
+	ErrorNotFound        = errors.New("badge not found")
+	ErrorUnknownBadge    = errors.New("badge is not registered")
+	ErrorRevoked         = errors.New("badge has been revoked")
+	ErrorLost            = errors.New("badge has been reported lost")
+)
+
+type Service struct {
+	repo       storage.EventRepository
+	accounting *account.Accounting
+	// serialises changes of badges, so a badge cannot be claimed twice by concurrent requests
+	mu sync.Mutex
+}
+
+func NewService(repo *storage.EventRepository, accounting *account.Accounting) *Service {
+	return &Service{repo: *repo, accounting: accounting}
+}
+
+// Find returns the badge with the given UID.
+func (s *Service) Find(uid string) (*Badge, error) {
+	normalized, err := NormalizeUID(uid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	b, err := s.load(normalized)
+	if err != nil {
+		return nil, err
+	}
+	if b.Status() == "" {
+		return nil, fmt.Errorf("%w: '%s'", ErrorNotFound, normalized)
+	}
+	return b, nil
+}
+
+// ListAll returns all known badges ordered by UID, including pending badges that have been scanned but not registered.
+func (s *Service) ListAll() ([]Badge, error) {
+	ids := make(map[string]bool)
+	for _, eventType := range []string{"BadgeScanned", "BadgeRegistered"} {
+		entries, err := s.repo.FetchByEventType(eventType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load badges: %w", err)
+		}
+		for _, entry := range entries {
+			ids[entry.AggregateID] = true
+		}
+	}
+	badges := make([]Badge, 0, len(ids))
+	for id := range ids {
+		entries, err := s.repo.LoadAll(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load badges: %w", err)
+		}
+		b, err := restore(entries)
+		if err != nil {
+			return nil, err
+		}
+		badges = append(badges, *b)
+	}
+	sort.Slice(badges, func(i, j int) bool { return badges[i].UID < badges[j].UID })
+	return badges, nil
+}
+
+// Pending returns the badges that have been scanned but not registered yet, oldest first.
+func (s *Service) Pending() ([]Badge, error) {
+	badges, err := s.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]Badge, 0)
+	for _, b := range badges {
+		if b.Status() == StatusPending {
+			pending = append(pending, b)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].Seen().Before(pending[j].Seen()) })
+	return pending, nil
+}
+
+// Identify returns the account of an active badge.
+//
+// An unknown badge is recorded as pending registration, so an admin can claim it for an account later on.
+// Merged accounts are resolved to the account they have been merged into.
+func (s *Service) Identify(uid string) (string, error) {
+	normalized, err := NormalizeUID(uid)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	s.mu.Lock()
+	b, err := s.load(normalized)
+	if err == nil && b.Status() == "" {
+		if err = b.Scan(time.Now()); err == nil {
+			err = s.save(b)
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	switch b.Status() {
+	case StatusPending:
+		return "", fmt.Errorf("%w: '%s' is pending registration", ErrorUnknownBadge, normalized)
+	case StatusRevoked:
+		return "", fmt.Errorf("%w: '%s'", ErrorRevoked, normalized)
+	case StatusLost:
+		return "", fmt.Errorf("%w: '%s'", ErrorLost, normalized)
+	}
+	accountID, _ := b.AccountID()
+	acc, err := s.accounting.Resolve(accountID)
+	if err != nil {
+		return "", fmt.Errorf("failed to find owner of badge '%s': %w", normalized, err)
+	}
+	return acc.ID(), nil
+}
+
+// Register assigns a badge to an account. This claims pending badges as well as revoked badges.
+func (s *Service) Register(uid string, accountID string) (*Badge, error) {
+	normalized, err := NormalizeUID(uid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	acc, err := s.accounting.Resolve(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.modify(normalized, false, func(b *Badge) error { return b.Register(acc.ID()) })
+}
+
+// Revoke releases a badge from its account, so it can be registered again.
+func (s *Service) Revoke(uid string) (*Badge, error) {
+	normalized, err := NormalizeUID(uid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	return s.modify(normalized, true, func(b *Badge) error { return b.Revoke() })
+}
+
+// ReportLost blocks a badge for good.
+func (s *Service) ReportLost(uid string) (*Badge, error) {
+	normalized, err := NormalizeUID(uid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	return s.modify(normalized, true, func(b *Badge) error { return b.ReportLost() })
+}
+
+func (s *Service) modify(uid string, mustExist bool, change func(*Badge) error) (*Badge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.load(uid)
+	if err != nil {
+		return nil, err
+	}
+	if mustExist && b.Status() == "" {
+		return nil, fmt.Errorf("%w: '%s'", ErrorNotFound, uid)
+	}
+	if err := change(b); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	if err := s.save(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *Service) load(uid string) (*Badge, error) {
+	entries, err := s.repo.LoadAll(aggregateID(uid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load badge '%s': %w", uid, err)
+	}
+	if len(entries) == 0 {
+		return newBadge(uid), nil
+	}
+	return restore(entries)
+}
+
+func (s *Service) save(b *Badge) error {
+	entries := make([]storage.EventEntry, 0)
+	for _, e := range b.Events() {
+		entry, err := toEventEntry(e)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	if err := s.repo.SaveAll(entries); err != nil {
+		return fmt.Errorf("failed to save badge '%s': %w", b.UID, err)
+	}
+	b.Clear()
+	return nil
+}
+
+func restore(entries []storage.EventEntry) (*Badge, error) {
+	var b *Badge
+	for _, entry := range entries {
+		e, err := convert(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load badge: %w", err)
+		}
+		if b == nil {
+			b = newBadge(uidOf(e))
+		}
+		if err := b.apply(e); err != nil {
+			return nil, fmt.Errorf("failed to load badge: %w", err)
+		}
+	}
+	b.Clear()
+	return b, nil
+}
+
+func uidOf(e event.Event) string {
+	return e.AggregateID()[len(aggregateID("")):]
+}
+
+func convert(entry storage.EventEntry) (event.Event, error) {
+	switch entry.EventType {
+	case "BadgeScanned":
+		e := BadgeScanned{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as BadgeScanned: %w", err)
+		}
+		return e, nil
+	case "BadgeRegistered":
+		e := BadgeRegistered{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as BadgeRegistered: %w", err)
+		}
+		return e, nil
+	case "BadgeRevoked":
+		e := BadgeRevoked{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as BadgeRevoked: %w", err)
+		}
+		return e, nil
+	case "BadgeLost":
+		e := BadgeLost{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as BadgeLost: %w", err)
+		}
+		return e, nil
+	default:
+		return nil, fmt.Errorf("unknown event type '%s'", entry.EventType)
+	}
+}
+
+func toEventEntry(e event.Event) (storage.EventEntry, error) {
+	switch t := e.(type) {
+	case BadgeScanned, BadgeRegistered, BadgeRevoked, BadgeLost:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: e.AggregateID(), EventType: e.Type(), Date: e.Occurred(), EventData: data}, nil
+	default:
+		return storage.EventEntry{}, fmt.Errorf("failed to convert event to entry: unknown event type '%T'", t)
+	}
+}
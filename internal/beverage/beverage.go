@@ -0,0 +1,236 @@
+package beverage
+
+import (
+	"coffy/internal/event"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Recipe describes how a beverage is prepared from a coffee.
+type Recipe struct {
+	Beans float64 `json:"beans"`           // grams of beans per cup
+	Milk  int     `json:"milk,omitempty"`  // millilitres of milk per cup
+	Water int     `json:"water,omitempty"` // millilitres of water per cup
+}
+
+// Validate checks that the recipe uses beans and no negative amount of anything.
+func (r Recipe) Validate() error {
+	if r.Beans <= 0 {
+		return errors.New("beans must be greater than zero")
+	}
+	if r.Milk < 0 || r.Water < 0 {
+		return errors.New("milk and water cannot be negative")
+	}
+	return nil
+}
+
+// Pricing determines the price of a cup. A fixed price applies as is, otherwise the price is derived
+// from the price of the coffee: coffee price × factor + surcharge, e.g. a double espresso with factor 2.
+type Pricing struct {
+	Price     float64 `json:"price,omitempty"`     // the fixed price of a cup
+	Factor    float64 `json:"factor,omitempty"`    // the multiple of the coffee price
+	Surcharge float64 `json:"surcharge,omitempty"` // added to the multiple of the coffee price, e.g. for milk
+}
+
+// Validate checks that the pricing is either a fixed price or a formula.
+func (p Pricing) Validate() error {
+	if p.Price < 0 || p.Factor < 0 || p.Surcharge < 0 {
+		return errors.New("price, factor and surcharge cannot be negative")
+	}
+	formula := p.Factor > 0 || p.Surcharge > 0
+	if p.Price > 0 && formula {
+		return errors.New("pricing is either a fixed price or a factor and surcharge")
+	}
+	if p.Price == 0 && !formula {
+		return errors.New("pricing requires a fixed price or a factor and surcharge")
+	}
+	return nil
+}
+
+// PriceFor returns the price of a cup, given the price of the coffee, rounded to cents.
+func (p Pricing) PriceFor(coffeePrice float64) float64 {
+	if p.Price > 0 {
+		return p.Price
+	}
+	return math.Round((coffeePrice*p.Factor+p.Surcharge)*100) / 100
+}
+
+// Beverage is a drink of the catalog, e.g. a cappuccino, which is prepared from a coffee after a recipe.
+type Beverage struct {
+	ID       string
+	name     string
+	coffeeID string
+	recipe   Recipe
+	pricing  Pricing
+	removed  bool
+	events   []event.Event
+}
+
+// NewBeverage adds a beverage to the catalog.
+func NewBeverage(id string, name string, coffeeID string, recipe Recipe, pricing Pricing) (*Beverage, error) {
+	name = strings.TrimSpace(name)
+	if err := validate(name, coffeeID, recipe, pricing); err != nil {
+		return nil, err
+	}
+	created := BeverageCreated{ID: id, Name: name, CoffeeID: coffeeID, Recipe: recipe, Pricing: pricing, OccurredOn: time.Now()}
+	b := &Beverage{}
+	if err := b.apply(created); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Beverage) Name() string {
+	return b.name
+}
+
+// CoffeeID returns the coffee the beverage is prepared from.
+func (b *Beverage) CoffeeID() string {
+	return b.coffeeID
+}
+
+func (b *Beverage) Recipe() Recipe {
+	return b.recipe
+}
+
+func (b *Beverage) Pricing() Pricing {
+	return b.pricing
+}
+
+// Removed reports whether the beverage has been removed from the catalog.
+func (b *Beverage) Removed() bool {
+	return b.removed
+}
+
+// Change replaces the name, the coffee, the recipe and the pricing of the beverage.
+func (b *Beverage) Change(name string, coffeeID string, recipe Recipe, pricing Pricing) error {
+	if b.removed {
+		return errors.New("beverage has been removed")
+	}
+	name = strings.TrimSpace(name)
+	if err := validate(name, coffeeID, recipe, pricing); err != nil {
+		return err
+	}
+	return b.apply(BeverageChanged{ID: b.ID, Name: name, CoffeeID: coffeeID, Recipe: recipe, Pricing: pricing, OccurredOn: time.Now()})
+}
+
+// Remove takes the beverage off the catalog, it cannot be consumed anymore.
+func (b *Beverage) Remove() error {
+	if b.removed {
+		return errors.New("beverage has been removed already")
+	}
+	return b.apply(BeverageRemoved{ID: b.ID, OccurredOn: time.Now()})
+}
+
+func validate(name string, coffeeID string, recipe Recipe, pricing Pricing) error {
+	if name == "" {
+		return errors.New("name cannot be empty")
+	}
+	if coffeeID == "" {
+		return errors.New("coffee cannot be empty")
+	}
+	if err := recipe.Validate(); err != nil {
+		return err
+	}
+	return pricing.Validate()
+}
+
+// Events returns all uncommitted events of the beverage.
+func (b *Beverage) Events() []event.Event {
+	return b.events
+}
+
+// Clear empties the event cache of the beverage.
+func (b *Beverage) Clear() {
+	b.events = []event.Event{}
+}
+
+func (b *Beverage) apply(e event.Event) error {
+	if b.ID != "" && e.AggregateID() != b.ID {
+		return fmt.Errorf("event does not belong to this aggregate")
+	}
+	switch theEvent := e.(type) {
+	case BeverageCreated:
+		b.ID = theEvent.ID
+		b.name = theEvent.Name
+		b.coffeeID = theEvent.CoffeeID
+		b.recipe = theEvent.Recipe
+		b.pricing = theEvent.Pricing
+	case BeverageChanged:
+		b.name = theEvent.Name
+		b.coffeeID = theEvent.CoffeeID
+		b.recipe = theEvent.Recipe
+		b.pricing = theEvent.Pricing
+	case BeverageRemoved:
+		b.removed = true
+	default:
+		return fmt.Errorf("unknown event type '%T'", theEvent)
+	}
+	b.events = append(b.events, e)
+	return nil
+}
+
+// The BeverageCreated event adds a beverage to the catalog.
+type BeverageCreated struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	CoffeeID   string    `json:"coffeeID"`
+	Recipe     Recipe    `json:"recipe"`
+	Pricing    Pricing   `json:"pricing"`
+	OccurredOn time.Time `json:"occurredOn"`
+}
+
+func (e BeverageCreated) AggregateID() string {
+	return e.ID
+}
+
+func (e BeverageCreated) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e BeverageCreated) Type() string {
+	return "BeverageCreated"
+}
+
+// The BeverageChanged event replaces the name, the coffee, the recipe and the pricing of a beverage.
+type BeverageChanged struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	CoffeeID   string    `json:"coffeeID"`
+	Recipe     Recipe    `json:"recipe"`
+	Pricing    Pricing   `json:"pricing"`
+	OccurredOn time.Time `json:"occurredOn"`
+}
+
+func (e BeverageChanged) AggregateID() string {
+	return e.ID
+}
+
+func (e BeverageChanged) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e BeverageChanged) Type() string {
+	return "BeverageChanged"
+}
+
+// The BeverageRemoved event takes a beverage off the catalog.
+type BeverageRemoved struct {
+	ID         string    `json:"id"`
+	OccurredOn time.Time `json:"occurredOn"`
+}
+
+func (e BeverageRemoved) AggregateID() string {
+	return e.ID
+}
+
+func (e BeverageRemoved) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e BeverageRemoved) Type() string {
+	return "BeverageRemoved"
+}
@@ -0,0 +1,85 @@
+package beverage
+
+import (
+	"coffy/internal/product"
+	"coffy/internal/storage/storagetest"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPricing(t *testing.T) {
+	for _, tt := range []struct {
+		pricing Pricing
+		price   float64
+	}{
+		{Pricing{Price: 1.20}, 1.20},
+		{Pricing{Factor: 2}, 1.00},
+		{Pricing{Factor: 1, Surcharge: 0.35}, 0.85},
+		{Pricing{Factor: 1.5}, 0.75},
+	} {
+		if err := tt.pricing.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", tt.pricing, err)
+		}
+		if price := tt.pricing.PriceFor(0.50); price != tt.price {
+			t.Errorf("Expected price %.2f for %+v, got %.2f", tt.price, tt.pricing, price)
+		}
+	}
+	for _, invalid := range []Pricing{{}, {Price: 1, Factor: 1}, {Price: -1}, {Factor: -1, Surcharge: 1}} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", invalid)
+		}
+	}
+}
+
+func TestRecipe(t *testing.T) {
+	if err := (Recipe{Beans: 7, Milk: 120}).Validate(); err != nil {
+		t.Errorf("Expected recipe to be valid, got %v", err)
+	}
+	for _, invalid := range []Recipe{{}, {Beans: 7, Milk: -1}, {Beans: 7, Water: -1}} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", invalid)
+		}
+	}
+}
+
+func TestBeverageCatalog(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	coffees := product.NewService(&repo)
+	s := NewService(&repo, coffees)
+	espresso, _ := coffees.Create("Espresso", 0.50, nil, nil)
+
+	b, err := s.Create("Cappuccino", espresso.AggregateID, Recipe{Beans: 7, Milk: 120}, Pricing{Factor: 1, Surcharge: 0.30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(" cappuccino", espresso.AggregateID, Recipe{Beans: 7}, Pricing{Price: 1}); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("Expected ErrorInvalidProperty for a duplicate name, got %v", err)
+	}
+	if _, err := s.Create("Latte", "unknown", Recipe{Beans: 7}, Pricing{Price: 1}); !errors.Is(err, product.ErrorNotFound) {
+		t.Errorf("Expected product.ErrorNotFound, got %v", err)
+	}
+	if price, err := s.PriceAt(b, time.Now()); err != nil || price != 0.80 {
+		t.Errorf("Expected price 0.80, got %.2f (%v)", price, err)
+	}
+
+	if _, err := s.Change(b.ID, "Cappuccino", espresso.AggregateID, Recipe{Beans: 7, Milk: 150}, Pricing{Price: 1.10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Remove(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	found, err := s.Find(b.ID)
+	if err != nil || !found.Removed() || found.Recipe().Milk != 150 || found.Pricing().Price != 1.10 {
+		t.Errorf("Expected removed beverage with the changed recipe, got %+v (%v)", found, err)
+	}
+	if _, err := s.Remove(b.ID); !errors.Is(err, ErrorRemoved) {
+		t.Errorf("Expected ErrorRemoved, got %v", err)
+	}
+	if _, err := s.Create("Cappuccino", espresso.AggregateID, Recipe{Beans: 8}, Pricing{Price: 1}); err != nil {
+		t.Errorf("Expected the name of a removed beverage to be reusable, got %v", err)
+	}
+	if _, err := s.Find("unknown"); !errors.Is(err, ErrorNotFound) {
+		t.Errorf("Expected ErrorNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package beverage
+
+import (
+	"coffy/internal/event"
+	"coffy/internal/product"
+	"coffy/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrorInvalidProperty = errors.New("invalid property")
+	ErrorNotFound        = errors.New("beverage not found")
+	ErrorRemoved         = errors.New("beverage has been removed")
+)
+
+type Service struct {
+	repo    storage.EventRepository
+	coffees *product.Service
+	// serialises changes of beverages, so names stay unique
+	mu sync.Mutex
+}
+
+func NewService(repo *storage.EventRepository, coffees *product.Service) *Service {
+	return &Service{repo: *repo, coffees: coffees}
+}
+
+// Create adds a beverage prepared from an available coffee to the catalog. Names of beverages are unique.
+func (s *Service) Create(name string, coffeeID string, recipe Recipe, pricing Pricing) (*Beverage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.available(coffeeID); err != nil {
+		return nil, err
+	}
+	if err := s.unique("", name); err != nil {
+		return nil, err
+	}
+	b, err := NewBeverage(uuid.NewString(), name, coffeeID, recipe, pricing)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	if err := s.save(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Change replaces the name, the coffee, the recipe and the pricing of a beverage.
+func (s *Service) Change(beverageID string, name string, coffeeID string, recipe Recipe, pricing Pricing) (*Beverage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.Find(beverageID)
+	if err != nil {
+		return nil, err
+	}
+	if coffeeID != b.CoffeeID() {
+		if err := s.available(coffeeID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.unique(beverageID, name); err != nil {
+		return nil, err
+	}
+	if err := b.Change(name, coffeeID, recipe, pricing); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	if err := s.save(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Remove takes a beverage off the catalog.
+func (s *Service) Remove(beverageID string) (*Beverage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.Find(beverageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Remove(); err != nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrorRemoved, b.Name())
+	}
+	if err := s.save(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// PriceAt returns the price of a cup of the beverage at the given time.
+func (s *Service) PriceAt(b *Beverage, t time.Time) (float64, error) {
+	c, err := s.coffees.Find(b.CoffeeID())
+	if err != nil {
+		return 0, err
+	}
+	return b.Pricing().PriceFor(c.PriceAt(t)), nil
+}
+
+// Find returns the beverage with the given ID, including removed beverages.
+func (s *Service) Find(beverageID string) (*Beverage, error) {
+	entries, err := s.repo.LoadAll(beverageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load beverage '%s': %w", beverageID, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: '%s'", ErrorNotFound, beverageID)
+	}
+	b := &Beverage{}
+	for _, entry := range entries {
+		e, err := convert(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load beverage '%s': %w", beverageID, err)
+		}
+		if err := b.apply(e); err != nil {
+			return nil, fmt.Errorf("failed to load beverage '%s': %w", beverageID, err)
+		}
+	}
+	b.Clear()
+	return b, nil
+}
+
+// ListAll returns all beverages of the catalog ordered by name, including removed beverages.
+func (s *Service) ListAll() ([]Beverage, error) {
+	entries, err := s.repo.FetchByEventType("BeverageCreated")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load beverages: %w", err)
+	}
+	beverages := make([]Beverage, 0, len(entries))
+	for _, entry := range entries {
+		b, err := s.Find(entry.AggregateID)
+		if err != nil {
+			return nil, err
+		}
+		beverages = append(beverages, *b)
+	}
+	sort.SliceStable(beverages, func(i, j int) bool { return beverages[i].Name() < beverages[j].Name() })
+	return beverages, nil
+}
+
+// available checks that a coffee exists and has not been discontinued.
+func (s *Service) available(coffeeID string) error {
+	c, err := s.coffees.Find(coffeeID)
+	if err != nil {
+		return err
+	}
+	if c.Discontinued() {
+		return fmt.Errorf("%w: '%s'", product.ErrorDiscontinued, c.Type)
+	}
+	return nil
+}
+
+// unique checks that no other beverage of the catalog has the given name, which is compared case-insensitive.
+func (s *Service) unique(beverageID string, name string) error {
+	all, err := s.ListAll()
+	if err != nil {
+		return err
+	}
+	for _, b := range all {
+		if b.ID != beverageID && !b.Removed() && strings.EqualFold(b.Name(), strings.TrimSpace(name)) {
+			return fmt.Errorf("%w: beverage '%s' exists already", ErrorInvalidProperty, b.Name())
+		}
+	}
+	return nil
+}
+
+func (s *Service) save(b *Beverage) error {
+	entries, err := convertAll(b.Events())
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SaveAll(entries); err != nil {
+		return fmt.Errorf("failed to save beverage: %w", err)
+	}
+	b.Clear()
+	return nil
+}
+
+func convertAll(events []event.Event) ([]storage.EventEntry, error) {
+	entries := make([]storage.EventEntry, 0, len(events))
+	for _, e := range events {
+		entry, err := toEventEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func convert(entry storage.EventEntry) (event.Event, error) {
+	switch entry.EventType {
+	case "BeverageCreated":
+		e := BeverageCreated{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as BeverageCreated: %w", err)
+		}
+		return e, nil
+	case "BeverageChanged":
+		e := BeverageChanged{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as BeverageChanged: %w", err)
+		}
+		return e, nil
+	case "BeverageRemoved":
+		e := BeverageRemoved{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as BeverageRemoved: %w", err)
+		}
+		return e, nil
+	default:
+		return nil, fmt.Errorf("unknown event type '%s'", entry.EventType)
+	}
+}
+
+func toEventEntry(e event.Event) (storage.EventEntry, error) {
+	switch t := e.(type) {
+	case BeverageCreated, BeverageChanged, BeverageRemoved:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: e.AggregateID(), EventType: e.Type(), Date: e.Occurred(), EventData: data}, nil
+	default:
+		return storage.EventEntry{}, fmt.Errorf("failed to convert event to entry: unknown event type '%T'", t)
+	}
+}
@@ -0,0 +1,185 @@
+package cashbox
+
+import (
+	"coffy/internal/event"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// BoxID is the ID of the cash box next to the coffee machines.
+const BoxID = "cash-box"
+
+// Box is the cash box guests pay their coffee into. It knows the revenue expected from cash sales
+// since the last count, counting the box empties it.
+type Box struct {
+	AggregateID string
+	expected    float64       // the revenue of all cash sales since the last count
+	sales       int           // the number of cups sold since the last count
+	counts      []Count       // all counts in chronological order
+	events      []event.Event // uncommitted events of the aggregate
+}
+
+// A Sale is a consumption that has been paid cash.
+type Sale struct {
+	CoffeeID   string
+	CoffeeType string
+	MachineID  string // the machine the coffee was taken from, empty if unknown
+	Quantity   int
+	UnitPrice  float64 // the price of a single cup
+	Amount     float64 // the price of all cups, considering pricing rules
+	ReceiptID  string
+	Time       time.Time
+}
+
+// Count is the result of counting the money in the box.
+type Count struct {
+	Date        time.Time `json:"date"`
+	Counted     float64   `json:"counted"`     // the money found in the box
+	Expected    float64   `json:"expected"`    // the revenue of the cash sales since the last count
+	Sales       int       `json:"sales"`       // the number of cups sold since the last count
+	Discrepancy float64   `json:"discrepancy"` // counted minus expected, negative if money is missing
+}
+
+func newBox() *Box {
+	return &Box{AggregateID: BoxID, counts: []Count{}}
+}
+
+// Expected returns the revenue expected in the box.
+func (b *Box) Expected() float64 {
+	return b.expected
+}
+
+// Sales returns the number of cups sold since the last count.
+func (b *Box) Sales() int {
+	return b.sales
+}
+
+// Counts returns all counts of the box in chronological order.
+func (b *Box) Counts() []Count {
+	return b.counts
+}
+
+// Sell records a cash sale.
+func (b *Box) Sell(s Sale) error {
+	if s.Quantity < 1 {
+		return errors.New("quantity must be at least one")
+	}
+	if s.Amount < 0 {
+		return errors.New("amount cannot be negative")
+	}
+	return b.apply(CashSale{
+		BoxID:      b.AggregateID,
+		OccurredOn: s.Time,
+		EventType:  "CashSale",
+		CoffeeID:   s.CoffeeID,
+		CoffeeType: s.CoffeeType,
+		MachineID:  s.MachineID,
+		Quantity:   s.Quantity,
+		UnitPrice:  s.UnitPrice,
+		Amount:     s.Amount,
+		ReceiptID:  s.ReceiptID,
+	})
+}
+
+// Count records the money counted in the box and returns the discrepancy against the expected revenue.
+// The money is taken out of the box, so the next count starts from zero.
+func (b *Box) Count(counted float64, t time.Time) (Count, error) {
+	if counted < 0 {
+		return Count{}, errors.New("counted money cannot be negative")
+	}
+	e := CashCounted{BoxID: b.AggregateID, OccurredOn: t, EventType: "CashCounted", Counted: counted, Expected: b.expected, Sales: b.sales}
+	if err := b.apply(e); err != nil {
+		return Count{}, err
+	}
+	return b.counts[len(b.counts)-1], nil
+}
+
+// Events returns all uncommitted events of the box.
+func (b *Box) Events() []event.Event {
+	return b.events
+}
+
+// Clear empties the event cache of the box.
+func (b *Box) Clear() {
+	b.events = []event.Event{}
+}
+
+func (b *Box) apply(e event.Event) error {
+	if e.AggregateID() != b.AggregateID {
+		return fmt.Errorf("event does not belong to this aggregate")
+	}
+	switch theEvent := e.(type) {
+	case CashSale:
+		b.expected = round(b.expected + theEvent.Amount)
+		b.sales += theEvent.Quantity
+	case CashCounted:
+		b.counts = append(b.counts, Count{
+			Date:        theEvent.OccurredOn,
+			Counted:     theEvent.Counted,
+			Expected:    theEvent.Expected,
+			Sales:       theEvent.Sales,
+			Discrepancy: round(theEvent.Counted - theEvent.Expected),
+		})
+		b.expected = 0
+		b.sales = 0
+	default:
+		return fmt.Errorf("unknown event type '%T'", theEvent)
+	}
+	b.events = append(b.events, e)
+	return nil
+}
+
+// round rounds an amount to full cents.
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// The CashSale event records cups of coffee that have been paid cash into the box.
+type CashSale struct {
+	BoxID      string    `json:"boxID"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	CoffeeID   string    `json:"coffeeID"`
+	CoffeeType string    `json:"coffeeType"`
+	MachineID  string    `json:"machineID,omitempty"`
+	Quantity   int       `json:"quantity"`
+	UnitPrice  float64   `json:"unitPrice"`
+	Amount     float64   `json:"amount"`
+	ReceiptID  string    `json:"receiptID,omitempty"`
+}
+
+func (e CashSale) AggregateID() string {
+	return e.BoxID
+}
+
+func (e CashSale) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e CashSale) Type() string {
+	return e.EventType
+}
+
+// The CashCounted event records the money counted in the box and the revenue expected at that time.
+type CashCounted struct {
+	BoxID      string    `json:"boxID"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	Counted    float64   `json:"counted"`
+	Expected   float64   `json:"expected"`
+	Sales      int       `json:"sales"`
+}
+
+func (e CashCounted) AggregateID() string {
+	return e.BoxID
+}
+
+func (e CashCounted) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e CashCounted) Type() string {
+	return e.EventType
+}
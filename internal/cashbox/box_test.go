@@ -0,0 +1,46 @@
+package cashbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCashCount(t *testing.T) {
+	b := newBox()
+	now := time.Now()
+	if err := b.Sell(Sale{CoffeeID: "c", Quantity: 2, UnitPrice: 0.50, Amount: 1.00, Time: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Sell(Sale{CoffeeID: "c", Quantity: 1, UnitPrice: 0.50, Amount: 0.40, Time: now}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Expected() != 1.40 || b.Sales() != 3 {
+		t.Errorf("Expected revenue should be 1.40 for 3 cups, got %.2f for %d", b.Expected(), b.Sales())
+	}
+	count, err := b.Count(1.20, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.Discrepancy != -0.20 || count.Expected != 1.40 {
+		t.Errorf("Discrepancy should be -0.20, got %+v", count)
+	}
+	if b.Expected() != 0 || b.Sales() != 0 {
+		t.Errorf("Counting should empty the box, got %.2f", b.Expected())
+	}
+	if len(b.Events()) != 3 {
+		t.Errorf("Expected 3 events, got %d", len(b.Events()))
+	}
+}
+
+func TestCashSaleMalicious(t *testing.T) {
+	b := newBox()
+	if err := b.Sell(Sale{Quantity: 0, Amount: 0.50}); err == nil {
+		t.Errorf("Expected error for zero cups, got none")
+	}
+	if err := b.Sell(Sale{Quantity: 1, Amount: -0.50}); err == nil {
+		t.Errorf("Expected error for negative amount, got none")
+	}
+	if _, err := b.Count(-1, time.Now()); err == nil {
+		t.Errorf("Expected error for negative count, got none")
+	}
+}
@@ -0,0 +1,121 @@
+package cashbox
+
+import (
+	"coffy/internal/event"
+	"coffy/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrorInvalidProperty = errors.New("invalid property")
+
+type Service struct {
+	repo storage.EventRepository
+	// serialises changes of the box, so concurrent sales and counts do not miss each other
+	mu sync.Mutex
+}
+
+func NewService(repo *storage.EventRepository) *Service {
+	return &Service{repo: *repo}
+}
+
+// Find returns the cash box. A box without any sales or counts is empty.
+func (s *Service) Find() (*Box, error) {
+	entries, err := s.repo.LoadAll(BoxID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cash box: %w", err)
+	}
+	b := newBox()
+	for _, entry := range entries {
+		e, err := convert(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cash box: %w", err)
+		}
+		if err := b.apply(e); err != nil {
+			return nil, fmt.Errorf("failed to load cash box: %w", err)
+		}
+	}
+	b.Clear()
+	return b, nil
+}
+
+// Sell records a cash sale. Related event entries of other aggregates are saved in the same transaction,
+// e.g. the receipt of the sale.
+func (s *Service) Sell(sale Sale, related ...storage.EventEntry) error {
+	_, err := s.modify(func(b *Box) error { return b.Sell(sale) }, related...)
+	return err
+}
+
+// Count records the money counted in the box and returns the discrepancy against the expected revenue.
+func (s *Service) Count(counted float64) (*Count, error) {
+	var count Count
+	_, err := s.modify(func(b *Box) error {
+		c, err := b.Count(counted, time.Now())
+		count = c
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+func (s *Service) modify(change func(*Box) error, related ...storage.EventEntry) (*Box, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.Find()
+	if err != nil {
+		return nil, err
+	}
+	if err := change(b); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	entries := related
+	for _, e := range b.Events() {
+		entry, err := toEventEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := s.repo.SaveAll(entries); err != nil {
+		return nil, fmt.Errorf("failed to save cash box: %w", err)
+	}
+	b.Clear()
+	return b, nil
+}
+
+func convert(entry storage.EventEntry) (event.Event, error) {
+	switch entry.EventType {
+	case "CashSale":
+		e := CashSale{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as CashSale: %w", err)
+		}
+		return e, nil
+	case "CashCounted":
+		e := CashCounted{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as CashCounted: %w", err)
+		}
+		return e, nil
+	default:
+		return nil, fmt.Errorf("unknown event type '%s'", entry.EventType)
+	}
+}
+
+func toEventEntry(e event.Event) (storage.EventEntry, error) {
+	switch t := e.(type) {
+	case CashSale, CashCounted:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: e.AggregateID(), EventType: e.Type(), Date: e.Occurred(), EventData: data}, nil
+	default:
+		return storage.EventEntry{}, fmt.Errorf("failed to convert event to entry: unknown event type '%T'", t)
+	}
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"coffy/internal/coffy"
+	"github.com/spf13/cobra"
+)
+
+var importCallBack func(config *coffy.Config, file string) error
+
+var importCmd = &cobra.Command{
+	Use:   "import-tally file.csv",
+	Short: "Imports consumptions from a tally sheet",
+	Long: `Imports the consumptions of a tally sheet in CSV format with the columns account, coffee, quantity and timestamp.
+Accounts and coffees are given by ID or by name. All rows are validated first and either all of them are recorded
+with their original timestamps or none.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+func init() {
+	serverCmd.AddCommand(importCmd)
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+	return importCallBack(config, args[0])
+}
+
+// OnImportTally registers the function that imports a tally sheet file.
+func OnImportTally(callback func(config *coffy.Config, file string) error) {
+	importCallBack = callback
+}
@@ -19,21 +19,24 @@ coffy-server is the heart of the coffy-suite and comes with a HTTP REST interfac
 }
 
 func init() {
-	serverCmd.Flags().StringVarP(&cfgPath, "config", "c", "./coffy.yaml", "path to coffy_machine.yaml")
+	serverCmd.PersistentFlags().StringVarP(&cfgPath, "config", "c", "./coffy.yaml", "path to coffy_machine.yaml")
 }
 
 func run(cmd *cobra.Command, args []string) {
-	f, err := os.Open(cfgPath)
+	config, err := loadConfig()
 	if err != nil {
 		panic(err)
 	}
-	defer f.Close()
+	callBack(config)
+}
 
-	config, err := coffy.ParseFile(f)
+func loadConfig() (*coffy.Config, error) {
+	f, err := os.Open(cfgPath)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	callBack(config)
+	defer f.Close()
+	return coffy.ParseFile(f)
 }
 
 func Execute(callback func(cfg *coffy.Config)) {
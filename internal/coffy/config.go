@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"gopkg.in/yaml.v3"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 )
 
 func ParseFile(file *os.File) (*Config, error) {
@@ -42,9 +45,213 @@ func validateConfig(cfg *Config) error {
 	if err := validateDatabase(cfg.Database); err != nil {
 		return err
 	}
+
+	// notifications are optional
+	if cfg.Notification != nil {
+		if err := validateNotification(cfg.Notification); err != nil {
+			return err
+		}
+	}
+
+	// pricing rules are optional
+	if cfg.Pricing != nil {
+		if err := validatePricing(cfg.Pricing); err != nil {
+			return err
+		}
+	}
+
+	// quotas are optional
+	if cfg.Quotas != nil {
+		if err := validateQuotas(cfg.Quotas); err != nil {
+			return err
+		}
+	}
+
+	// links are optional, the QR code endpoints are disabled without them
+	if cfg.Links != nil {
+		if err := validateLinks(cfg.Links); err != nil {
+			return err
+		}
+	}
+
+	// inventory falls back to the defaults if not provided
+	if cfg.Inventory == nil {
+		cfg.Inventory = &InventoryCfg{}
+	}
+	if err := validateInventory(cfg.Inventory); err != nil {
+		return err
+	}
+	if cfg.Inventory.AlertEmail != "" && cfg.Notification == nil {
+		return InvalidPropertyError{"alert_email", "requires the notification settings"}
+	}
+
+	// costs fall back to the defaults if not provided
+	if cfg.Costs == nil {
+		cfg.Costs = &CostsCfg{}
+	}
+	if err := validateCosts(cfg.Costs); err != nil {
+		return err
+	}
+
+	// billing falls back to the default if not provided
+	if cfg.Billing == nil {
+		cfg.Billing = &BillingCfg{Day: 1, Hour: 6}
+	}
+	if err := validateBilling(cfg.Billing); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateBilling(b *BillingCfg) error {
+	if b.Day < 1 || b.Day > 28 {
+		return InvalidPropertyError{"day", "must be between 1 and 28"}
+	}
+	if b.Hour < 0 || b.Hour > 23 {
+		return InvalidPropertyError{"hour", "must be between 0 and 23"}
+	}
+	return nil
+}
+
+func validatePricing(p *PricingCfg) error {
+	for _, r := range p.Rules {
+		if r.Name == "" {
+			return MissingPropertyError{"name", "missing property"}
+		}
+		if (r.Percentage == 0) == (r.Fixed == 0) {
+			return InvalidPropertyError{"rules", fmt.Sprintf("rule '%s' needs either a percentage or a fixed discount", r.Name)}
+		}
+		if r.Percentage < 0 || r.Percentage > 100 {
+			return InvalidPropertyError{"percentage", "must be between 0 and 100"}
+		}
+		if r.Fixed < 0 {
+			return InvalidPropertyError{"fixed", "must not be negative"}
+		}
+		for _, day := range r.Weekdays {
+			if _, err := parseWeekday(day); err != nil {
+				return err
+			}
+		}
+		if (r.From == "") != (r.To == "") {
+			return InvalidPropertyError{"rules", fmt.Sprintf("rule '%s' needs both, a start and an end time", r.Name)}
+		}
+		if r.From != "" {
+			if _, err := r.Window(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateQuotas(q *QuotaCfg) error {
+	for _, r := range q.Rules {
+		if r.Name == "" {
+			return MissingPropertyError{"name", "missing property"}
+		}
+		if r.Period != "day" && r.Period != "week" {
+			return InvalidPropertyError{"period", fmt.Sprintf("rule '%s' needs a period of 'day' or 'week'", r.Name)}
+		}
+		if r.Max < 0 {
+			return InvalidPropertyError{"max", "must not be negative"}
+		}
+	}
+	return nil
+}
+
+func validateLinks(l *LinksCfg) error {
+	if len(l.Secret) < 16 {
+		return InvalidPropertyError{"secret", "must have at least 16 characters"}
+	}
+	u, err := url.Parse(l.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return InvalidPropertyError{"base_url", "must be an absolute http or https URL"}
+	}
+	if l.TTLDays < 0 {
+		return InvalidPropertyError{"ttl_days", "must not be negative"}
+	}
+	if l.TTLDays == 0 {
+		l.TTLDays = 365
+	}
+	return nil
+}
+
+func validateInventory(i *InventoryCfg) error {
+	if i.GramsPerCup < 0 {
+		return InvalidPropertyError{"grams_per_cup", "must not be negative"}
+	}
+	if i.GramsPerCup == 0 {
+		i.GramsPerCup = 8
+	}
+	if i.RateDays < 0 {
+		return InvalidPropertyError{"rate_days", "must not be negative"}
+	}
+	if i.RateDays == 0 {
+		i.RateDays = 14
+	}
+	if i.LowStockDays < 0 {
+		return InvalidPropertyError{"low_stock_days", "must not be negative"}
+	}
+	if i.LowStockDays == 0 {
+		i.LowStockDays = 7
+	}
+	if i.AlertHour < 0 || i.AlertHour > 23 {
+		return InvalidPropertyError{"alert_hour", "must be between 0 and 23"}
+	}
+	return nil
+}
+
+func validateCosts(c *CostsCfg) error {
+	if c.TargetMargin < 0 || c.TargetMargin >= 100 {
+		return InvalidPropertyError{"target_margin", "must be at least 0 and less than 100"}
+	}
+	if len(c.MaintenanceKeywords) == 0 {
+		c.MaintenanceKeywords = []string{"maintenance", "descaling"}
+	}
+	for _, k := range c.MaintenanceKeywords {
+		if strings.TrimSpace(k) == "" {
+			return InvalidPropertyError{"maintenance_keywords", "must not contain empty keywords"}
+		}
+	}
+	return nil
+}
+
+func validateNotification(n *NotificationCfg) error {
+	if n.Smtp == nil {
+		return MissingPropertyError{"smtp", "missing property"}
+	}
+	if n.Smtp.Host == "" {
+		return MissingPropertyError{"host", "missing property"}
+	}
+	if n.Smtp.Port == 0 {
+		return MissingPropertyError{"port", "missing property"}
+	}
+	if n.Smtp.From == "" {
+		return MissingPropertyError{"from", "missing property"}
+	}
+	if n.Dunning != nil {
+		if _, err := parseWeekday(n.Dunning.Weekday); err != nil {
+			return err
+		}
+		if n.Dunning.Hour < 0 || n.Dunning.Hour > 23 {
+			return InvalidPropertyError{"hour", "must be between 0 and 23"}
+		}
+		if n.Dunning.DebtAgeDays < 0 {
+			return InvalidPropertyError{"debt_age_days", "must not be negative"}
+		}
+	}
 	return nil
 }
 
+func parseWeekday(day string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), day) {
+			return d, nil
+		}
+	}
+	return time.Sunday, InvalidPropertyError{"weekday", fmt.Sprintf("unknown weekday '%s'", day)}
+}
+
 func validateDatabase(c *DbCfg) error {
 	if c == nil {
 		return MissingPropertyError{"database", "missing property"}
@@ -66,8 +273,15 @@ func validateServer(s *ServerCfg) error {
 }
 
 type Config struct {
-	Server   *ServerCfg `yaml:"server"`
-	Database *DbCfg     `yaml:"database"`
+	Server       *ServerCfg       `yaml:"server"`
+	Database     *DbCfg           `yaml:"database"`
+	Notification *NotificationCfg `yaml:"notification"`
+	Pricing      *PricingCfg      `yaml:"pricing"`
+	Billing      *BillingCfg      `yaml:"billing"`
+	Quotas       *QuotaCfg        `yaml:"quotas"`
+	Links        *LinksCfg        `yaml:"links"`
+	Inventory    *InventoryCfg    `yaml:"inventory"`
+	Costs        *CostsCfg        `yaml:"costs"`
 }
 
 type ServerCfg struct {
@@ -78,6 +292,121 @@ type DbCfg struct {
 	Path string `yaml:"path"`
 }
 
+// NotificationCfg configures email notifications about account balances.
+type NotificationCfg struct {
+	Smtp       *SmtpCfg    `yaml:"smtp"`
+	Thresholds []float64   `yaml:"thresholds"` // balances that trigger a notification when crossed downwards
+	Dunning    *DunningCfg `yaml:"dunning"`    // optional weekly reminder for old debts
+}
+
+type SmtpCfg struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+type DunningCfg struct {
+	Weekday     string `yaml:"weekday"`       // the day of the week the reminder is sent, e.g. monday
+	Hour        int    `yaml:"hour"`          // the hour of the day the reminder is sent
+	DebtAgeDays int    `yaml:"debt_age_days"` // minimum age of a debt in days before a reminder is sent
+}
+
+// Day returns the configured weekday of the dunning reminder.
+func (d *DunningCfg) Day() time.Weekday {
+	day, _ := parseWeekday(d.Weekday)
+	return day
+}
+
+// LinksCfg configures the signed links embedded in QR codes of machines and accounts.
+type LinksCfg struct {
+	Secret  string `yaml:"secret"`   // the key the links are signed with, at least 16 characters. Required
+	BaseURL string `yaml:"base_url"` // the URL coffy-server is reached at from phones, e.g. https://coffy.example.com. Required
+	TTLDays int    `yaml:"ttl_days"` // the number of days a link is valid. Default is 365
+}
+
+// InventoryCfg configures how the bean stock is depleted by consumptions and when it is low.
+type InventoryCfg struct {
+	GramsPerCup  float64 `yaml:"grams_per_cup"`  // the beans used for a cup of plain coffee, beverages use their recipe. Default is 8
+	RateDays     int     `yaml:"rate_days"`      // the number of recent days the consumption rate is averaged over. Default is 14
+	LowStockDays int     `yaml:"low_stock_days"` // the stock of a coffee is low if it lasts fewer days. Default is 7
+	AlertEmail   string  `yaml:"alert_email"`    // optional address that receives a daily low-stock alert, requires notification
+	AlertHour    int     `yaml:"alert_hour"`     // the hour of the day the alert is sent. Default is 0
+}
+
+// CostsCfg configures the cost report and its price recommendation.
+type CostsCfg struct {
+	TargetMargin        float64  `yaml:"target_margin"`        // the margin in percent of the price the recommended prices achieve. Default is 0
+	MaintenanceKeywords []string `yaml:"maintenance_keywords"` // payments with one of these words in their reason are maintenance costs. Default is maintenance and descaling
+}
+
+// BillingCfg configures when monthly subscription fees are charged.
+type BillingCfg struct {
+	Day  int `yaml:"day"`  // the day of the month, between 1 and 28. Default is 1
+	Hour int `yaml:"hour"` // the hour of the day. Default is 6
+}
+
+// PricingCfg configures discounts that are applied when a coffee is consumed.
+type PricingCfg struct {
+	Rules []PricingRuleCfg `yaml:"rules"`
+}
+
+// PricingRuleCfg describes a single discount. All conditions are optional, a rule without
+// conditions applies to every consumption.
+type PricingRuleCfg struct {
+	Name       string   `yaml:"name"`       // a descriptive name, e.g. 'apprentice subsidy'
+	Group      string   `yaml:"group"`      // the account group the rule applies to
+	CoffeeID   string   `yaml:"coffee_id"`  // the coffee the rule applies to
+	Weekdays   []string `yaml:"weekdays"`   // the days of the week the rule applies to
+	From       string   `yaml:"from"`       // the start of the daily time window, e.g. 14:00
+	To         string   `yaml:"to"`         // the end of the daily time window (exclusive), e.g. 16:00. Before the start for windows past midnight
+	Percentage float64  `yaml:"percentage"` // a discount in percent of the price
+	Fixed      float64  `yaml:"fixed"`      // a fixed discount in €
+}
+
+// Days returns the configured weekdays of the rule.
+func (r PricingRuleCfg) Days() []time.Weekday {
+	days := make([]time.Weekday, 0, len(r.Weekdays))
+	for _, d := range r.Weekdays {
+		day, _ := parseWeekday(d)
+		days = append(days, day)
+	}
+	return days
+}
+
+// Window returns the daily time window of the rule as offsets from midnight. A window whose start is
+// after its end wraps past midnight, e.g. from 22:00 to 06:00.
+func (r PricingRuleCfg) Window() ([2]time.Duration, error) {
+	var window [2]time.Duration
+	for i, value := range []string{r.From, r.To} {
+		t, err := time.Parse("15:04", value)
+		if err != nil {
+			return window, InvalidPropertyError{"from/to", fmt.Sprintf("invalid time of day '%s'", value)}
+		}
+		window[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if window[0] == window[1] {
+		return window, InvalidPropertyError{"from/to", fmt.Sprintf("rule '%s' needs a start that differs from its end", r.Name)}
+	}
+	return window, nil
+}
+
+// QuotaCfg configures caps on the number of cups consumed per day or week.
+type QuotaCfg struct {
+	Rules []QuotaRuleCfg `yaml:"rules"`
+}
+
+// QuotaRuleCfg describes a single cap. A rule without accounts and group applies to every account.
+type QuotaRuleCfg struct {
+	Name       string   `yaml:"name"`       // a descriptive name, e.g. 'health'
+	Period     string   `yaml:"period"`     // the period the cups are counted in, 'day' or 'week' (starting on Monday)
+	Max        int      `yaml:"max"`        // the maximum number of cups per period
+	Accounts   []string `yaml:"accounts"`   // the IDs of the accounts the rule applies to
+	Group      string   `yaml:"group"`      // the account group the rule applies to
+	Subsidised bool     `yaml:"subsidised"` // counts subsidised cups only
+}
+
 type MissingPropertyError struct {
 	Property string
 	Message  string
@@ -86,3 +415,12 @@ type MissingPropertyError struct {
 func (e MissingPropertyError) Error() string {
 	return fmt.Sprintf("%s: '%s'", e.Message, e.Property)
 }
+
+type InvalidPropertyError struct {
+	Property string
+	Message  string
+}
+
+func (e InvalidPropertyError) Error() string {
+	return fmt.Sprintf("invalid property '%s': %s", e.Property, e.Message)
+}
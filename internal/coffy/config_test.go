@@ -3,6 +3,7 @@ package coffy
 import (
 	"errors"
 	"testing"
+	"time"
 )
 
 // Working configuration, must contain all config parameters
@@ -109,3 +110,412 @@ func TestParseMissingDatabasePath(t *testing.T) {
 		t.Errorf("Expected message: 'missing property: 'path'', got: %v", err)
 	}
 }
+
+var validNotificationConfig = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+notification:
+    smtp:
+        host: localhost
+        port: 1025
+        from: coffy@example.com
+    thresholds: [0, -10]
+    dunning:
+        weekday: Monday
+        hour: 9
+        debt_age_days: 14
+`
+
+var missingSmtpHost = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+notification:
+    smtp:
+        port: 1025
+        from: coffy@example.com
+`
+
+var invalidDunningWeekday = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+notification:
+    smtp:
+        host: localhost
+        port: 1025
+        from: coffy@example.com
+    dunning:
+        weekday: someday
+`
+
+func TestParseNotification(t *testing.T) {
+	config, err := Parse(validNotificationConfig)
+	if err != nil {
+		t.Errorf("couldn't parse config: %v", err)
+		return
+	}
+	if config.Notification.Smtp.Port != 1025 {
+		t.Errorf("invalid smtp port: %v", config.Notification.Smtp.Port)
+	}
+	if len(config.Notification.Thresholds) != 2 {
+		t.Errorf("expected 2 thresholds, got: %v", config.Notification.Thresholds)
+	}
+	if config.Notification.Dunning.Day() != time.Monday {
+		t.Errorf("expected dunning on Monday, got: %v", config.Notification.Dunning.Day())
+	}
+}
+
+func TestParseMissingSmtpHost(t *testing.T) {
+	_, err := Parse(missingSmtpHost)
+	if err == nil {
+		t.Errorf("Expected error for missing smtp host")
+		return
+	}
+	if err.Error() != "missing property: 'host'" {
+		t.Errorf("Expected message: missing property: 'host', got: %v", err)
+	}
+}
+
+func TestParseInvalidDunningWeekday(t *testing.T) {
+	_, err := Parse(invalidDunningWeekday)
+	if err == nil {
+		t.Errorf("Expected error for invalid weekday")
+		return
+	}
+	var expectedErr = &InvalidPropertyError{}
+	if !errors.As(err, expectedErr) {
+		t.Errorf("Expected invalid property error, got: %v", err)
+	}
+}
+
+var validPricingConfig = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+pricing:
+    rules:
+        - name: apprentice subsidy
+          group: apprentice
+          percentage: 50
+        - name: friday afternoon
+          weekdays: [friday]
+          from: "14:00"
+          to: "16:30"
+          fixed: 0.10
+`
+
+var ambiguousPricingRule = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+pricing:
+    rules:
+        - name: too generous
+          percentage: 50
+          fixed: 0.10
+`
+
+func TestParsePricing(t *testing.T) {
+	config, err := Parse(validPricingConfig)
+	if err != nil {
+		t.Errorf("couldn't parse config: %v", err)
+		return
+	}
+	if len(config.Pricing.Rules) != 2 {
+		t.Errorf("expected 2 pricing rules, got: %v", len(config.Pricing.Rules))
+		return
+	}
+	window, err := config.Pricing.Rules[1].Window()
+	if err != nil {
+		t.Errorf("couldn't parse time window: %v", err)
+		return
+	}
+	if window[1] != 16*time.Hour+30*time.Minute {
+		t.Errorf("expected window to end at 16:30, got: %v", window[1])
+	}
+	if config.Pricing.Rules[1].Days()[0] != time.Friday {
+		t.Errorf("expected rule on Friday, got: %v", config.Pricing.Rules[1].Days())
+	}
+}
+
+var overnightPricingRule = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+pricing:
+    rules:
+        - name: night shift
+          from: "22:00"
+          to: "06:00"
+          fixed: 0.10
+`
+
+var emptyPricingWindow = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+pricing:
+    rules:
+        - name: never
+          from: "14:00"
+          to: "14:00"
+          fixed: 0.10
+`
+
+func TestParseOvernightPricingRule(t *testing.T) {
+	config, err := Parse(overnightPricingRule)
+	if err != nil {
+		t.Fatalf("couldn't parse config: %v", err)
+	}
+	window, err := config.Pricing.Rules[0].Window()
+	if err != nil || window[0] != 22*time.Hour || window[1] != 6*time.Hour {
+		t.Errorf("expected window from 22:00 to 06:00, got: %v (%v)", window, err)
+	}
+	_, err = Parse(emptyPricingWindow)
+	var expectedErr = &InvalidPropertyError{}
+	if !errors.As(err, expectedErr) {
+		t.Errorf("Expected invalid property error for an empty window, got: %v", err)
+	}
+}
+
+func TestParseAmbiguousPricingRule(t *testing.T) {
+	_, err := Parse(ambiguousPricingRule)
+	var expectedErr = &InvalidPropertyError{}
+	if !errors.As(err, expectedErr) {
+		t.Errorf("Expected invalid property error, got: %v", err)
+	}
+}
+
+var invalidBillingDay = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+billing:
+    day: 31
+`
+
+func TestParseDefaultBilling(t *testing.T) {
+	config, err := Parse(validConfig)
+	if err != nil {
+		t.Errorf("couldn't parse config: %v", err)
+		return
+	}
+	if config.Billing.Day != 1 || config.Billing.Hour != 6 {
+		t.Errorf("expected default billing on day 1 at 6:00, got: %+v", config.Billing)
+	}
+}
+
+func TestParseInvalidBillingDay(t *testing.T) {
+	_, err := Parse(invalidBillingDay)
+	var expectedErr = &InvalidPropertyError{}
+	if !errors.As(err, expectedErr) {
+		t.Errorf("Expected invalid property error, got: %v", err)
+	}
+}
+
+var validQuotaConfig = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+quotas:
+    rules:
+        - name: health
+          period: day
+          max: 4
+          accounts: [0b5a6e5c-0a6e-4a4f-8d5e-6f2a0a2f5b1c]
+        - name: subsidised cups
+          period: week
+          max: 10
+          group: apprentice
+          subsidised: true
+`
+
+var invalidQuotaPeriod = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+quotas:
+    rules:
+        - name: monthly
+          period: month
+          max: 100
+`
+
+func TestParseQuotas(t *testing.T) {
+	config, err := Parse(validQuotaConfig)
+	if err != nil {
+		t.Errorf("couldn't parse config: %v", err)
+		return
+	}
+	if len(config.Quotas.Rules) != 2 {
+		t.Errorf("expected 2 quota rules, got: %v", len(config.Quotas.Rules))
+		return
+	}
+	if !config.Quotas.Rules[1].Subsidised || config.Quotas.Rules[1].Max != 10 {
+		t.Errorf("unexpected quota rule: %+v", config.Quotas.Rules[1])
+	}
+}
+
+func TestParseInvalidQuotaPeriod(t *testing.T) {
+	_, err := Parse(invalidQuotaPeriod)
+	var expectedErr = &InvalidPropertyError{}
+	if !errors.As(err, expectedErr) {
+		t.Errorf("Expected invalid property error, got: %v", err)
+	}
+}
+
+var validLinksConfig = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+links:
+    secret: a-secret-of-at-least-16-characters
+    base_url: https://coffy.example.com
+`
+
+var invalidLinksBaseURL = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+links:
+    secret: a-secret-of-at-least-16-characters
+    base_url: coffy.example.com
+`
+
+var missingLinksSecret = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+links:
+    base_url: https://coffy.example.com
+`
+
+func TestParseLinks(t *testing.T) {
+	config, err := Parse(validLinksConfig)
+	if err != nil {
+		t.Errorf("couldn't parse config: %v", err)
+		return
+	}
+	if config.Links.BaseURL != "https://coffy.example.com" || config.Links.TTLDays != 365 {
+		t.Errorf("unexpected links config: %+v", config.Links)
+	}
+}
+
+func TestParseDefaultLinks(t *testing.T) {
+	config, err := Parse(validConfig)
+	if err != nil {
+		t.Errorf("couldn't parse config: %v", err)
+		return
+	}
+	if config.Links != nil {
+		t.Errorf("expected links to be disabled, got: %+v", config.Links)
+	}
+}
+
+func TestParseMissingLinksSecret(t *testing.T) {
+	_, err := Parse(missingLinksSecret)
+	var expectedErr = &InvalidPropertyError{}
+	if !errors.As(err, expectedErr) {
+		t.Errorf("Expected invalid property error, got: %v", err)
+	}
+}
+
+func TestParseInvalidLinksBaseURL(t *testing.T) {
+	_, err := Parse(invalidLinksBaseURL)
+	var expectedErr = &InvalidPropertyError{}
+	if !errors.As(err, expectedErr) {
+		t.Errorf("Expected invalid property error, got: %v", err)
+	}
+}
+
+var validInventoryConfig = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+inventory:
+    grams_per_cup: 7.5
+    low_stock_days: 3
+`
+
+var inventoryAlertWithoutNotification = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+inventory:
+    alert_email: barista@example.com
+`
+
+func TestParseInventory(t *testing.T) {
+	config, err := Parse(validInventoryConfig)
+	if err != nil {
+		t.Errorf("couldn't parse config: %v", err)
+		return
+	}
+	if config.Inventory.GramsPerCup != 7.5 || config.Inventory.LowStockDays != 3 || config.Inventory.RateDays != 14 {
+		t.Errorf("unexpected inventory config: %+v", config.Inventory)
+	}
+}
+
+func TestParseDefaultInventory(t *testing.T) {
+	config, err := Parse(validConfig)
+	if err != nil {
+		t.Errorf("couldn't parse config: %v", err)
+		return
+	}
+	if config.Inventory == nil || config.Inventory.GramsPerCup != 8 || config.Inventory.LowStockDays != 7 {
+		t.Errorf("expected default inventory of 8 grams per cup, got: %+v", config.Inventory)
+	}
+}
+
+func TestParseInventoryAlertWithoutNotification(t *testing.T) {
+	_, err := Parse(inventoryAlertWithoutNotification)
+	var expectedErr = &InvalidPropertyError{}
+	if !errors.As(err, expectedErr) {
+		t.Errorf("Expected invalid property error, got: %v", err)
+	}
+}
+
+var invalidCostsMargin = `
+server:
+    port: 8080
+database:
+    path: ./coffy_path/coffy_machine.db
+costs:
+    target_margin: 100
+`
+
+func TestParseDefaultCosts(t *testing.T) {
+	config, err := Parse(validConfig)
+	if err != nil {
+		t.Errorf("couldn't parse config: %v", err)
+		return
+	}
+	if config.Costs == nil || config.Costs.TargetMargin != 0 || len(config.Costs.MaintenanceKeywords) != 2 {
+		t.Errorf("expected default costs without margin, got: %+v", config.Costs)
+	}
+}
+
+func TestParseInvalidCostsMargin(t *testing.T) {
+	_, err := Parse(invalidCostsMargin)
+	var expectedErr = &InvalidPropertyError{}
+	if !errors.As(err, expectedErr) {
+		t.Errorf("Expected invalid property error, got: %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package consume
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/event"
+	"coffy/internal/product"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrorBatchRejected = errors.New("batch rejected, no consumption has been recorded")
+var ErrorEmptyBatch = errors.New("batch is empty")
+
+// A Row is a single line of a tally sheet: an account consumed a quantity of a coffee at a point in time.
+type Row struct {
+	Account  string    `json:"account"` // the ID or the unique owner of the account
+	Coffee   string    `json:"coffee"`  // the ID or the unique name of the coffee
+	Quantity int       `json:"quantity"`
+	Time     time.Time `json:"timestamp"`
+	line     int       // the line in the source file, zero if the row was not read from a file
+	err      error     // the error reading the row from the source file
+}
+
+// RowResult reports the outcome of a single Row of a batch.
+type RowResult struct {
+	Row       int    `json:"row"` // the line of the row in the source file, or its position starting at 1
+	ReceiptID string `json:"receipt_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BatchReport reports the outcome of a batch. A batch is recorded completely or not at all.
+type BatchReport struct {
+	Recorded bool        `json:"recorded"`
+	Rows     []RowResult `json:"rows"`
+}
+
+// ConsumeBatch validates all rows and records their consumptions with the original timestamps.
+//
+// All consumptions and receipts are saved together. If any row is invalid, nothing is recorded and
+// ErrorBatchRejected is returned together with the report that names the errors of each row.
+// Quota rules and balance notifications are not applied, as the rows record consumptions that already happened.
+func (s *Service) ConsumeBatch(rows []Row) (*BatchReport, error) {
+	if len(rows) == 0 {
+		return nil, ErrorEmptyBatch
+	}
+	report := &BatchReport{Rows: make([]RowResult, len(rows))}
+	for i, r := range rows {
+		report.Rows[i].Row = i + 1
+		if r.line > 0 {
+			report.Rows[i].Row = r.line
+		}
+	}
+	failed := func(i int, err error) {
+		if report.Rows[i].Error != "" {
+			report.Rows[i].Error += "; "
+		}
+		report.Rows[i].Error += err.Error()
+	}
+
+	accounts, err := s.accounting.Search(account.Query{Status: account.StatusAll})
+	if err != nil {
+		return nil, err
+	}
+	coffees, err := s.product.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	resolved := make([]*account.Account, len(rows))
+	products := make([]*product.Coffee, len(rows))
+	for i, r := range rows {
+		if r.err != nil {
+			failed(i, r.err)
+			continue
+		}
+		if r.Quantity < 1 {
+			failed(i, ErrorInvalidQuantity)
+		}
+		switch {
+		case r.Time.IsZero():
+			failed(i, errors.New("timestamp is missing"))
+		case r.Time.After(now):
+			failed(i, errors.New("timestamp lies in the future"))
+		}
+		if resolved[i], err = findAccount(accounts, r.Account); err != nil {
+			failed(i, err)
+		}
+		if products[i], err = findCoffee(coffees, r.Coffee, r.Time); err != nil {
+			failed(i, err)
+		} else if !products[i].AvailableAt(r.Time) {
+			failed(i, fmt.Errorf("%w: '%s'", product.ErrorDiscontinued, products[i].Type))
+		}
+	}
+	if slices.ContainsFunc(report.Rows, func(r RowResult) bool { return r.Error != "" }) {
+		return report, ErrorBatchRejected
+	}
+
+	// charge the rows in chronological order, so subscription plans cover the earliest cups
+	order := make([]int, len(rows))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a int, b int) int { return rows[a].Time.Compare(rows[b].Time) })
+	consumptions := make(map[string][]account.Consumption)
+	events := make([]ReceiptIssued, 0, len(rows))
+	for _, i := range order {
+		a, p := resolved[i], products[i]
+		issued, charged, err := s.issue(a, item{coffee: p}, Order{AccountID: a.ID(), CoffeeID: p.AggregateID, Quantity: rows[i].Quantity}, rows[i].Time)
+		if err != nil {
+			failed(i, err)
+			continue
+		}
+		// record on the loaded account as well, so the next rows consider the consumed cups
+		for _, c := range charged {
+			if err := a.Record(c); err != nil {
+				failed(i, err)
+			}
+		}
+		consumptions[a.ID()] = append(consumptions[a.ID()], charged...)
+		events = append(events, issued)
+		report.Rows[i].ReceiptID = issued.ReceiptID
+	}
+	if slices.ContainsFunc(report.Rows, func(r RowResult) bool { return r.Error != "" }) {
+		for i := range report.Rows {
+			report.Rows[i].ReceiptID = ""
+		}
+		return report, ErrorBatchRejected
+	}
+
+	entries, err := convertAll(toEvents(events))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.accounting.ConsumeAll(consumptions, entries...); err != nil {
+		return nil, errors.Join(errors.New("failed to record batch"), err)
+	}
+	report.Recorded = true
+	return report, nil
+}
+
+// findAccount finds an account by its ID or its unique owner, which is compared case-insensitive.
+// Accounts that have been merged are resolved to the account they have been merged into.
+func findAccount(accounts []account.Account, ref string) (*account.Account, error) {
+	var found *account.Account
+	for i, a := range accounts {
+		if a.ID() == ref {
+			if target, merged := a.MergedInto(); merged {
+				return findAccount(accounts, target)
+			}
+			return &accounts[i], nil
+		}
+		if _, merged := a.MergedInto(); merged {
+			continue
+		}
+		if strings.EqualFold(a.Owner(), ref) {
+			if found != nil {
+				return nil, fmt.Errorf("account '%s' is ambiguous", ref)
+			}
+			found = &accounts[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrorAccountNotFound, ref)
+	}
+	return found, nil
+}
+
+// findCoffee finds a coffee by its ID or its unique name, which is compared case-insensitive.
+func findCoffee(coffees []product.Coffee, ref string, t time.Time) (*product.Coffee, error) {
+	var found *product.Coffee
+	for i, c := range coffees {
+		if c.AggregateID == ref {
+			return &coffees[i], nil
+		}
+		// names of discontinued coffees may have been reused
+		if strings.EqualFold(c.Type, ref) && c.AvailableAt(t) {
+			if found != nil {
+				return nil, fmt.Errorf("coffee '%s' is ambiguous", ref)
+			}
+			found = &coffees[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrorProductNotFound, ref)
+	}
+	return found, nil
+}
+
+// tallyTimeLayouts are the accepted timestamp formats of tally sheets, timestamps without
+// a time zone are read in local time.
+var tallyTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ParseTally reads a tally sheet in CSV format with the columns account, coffee, quantity and timestamp.
+// An optional header line is skipped. Lines that cannot be read are returned as rows with an error,
+// which is reported by ConsumeBatch.
+func ParseTally(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows := make([]Row, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("failed to read tally sheet: %w", err)
+			}
+			rows = append(rows, Row{line: parseErr.Line, err: parseErr.Err})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if line == 1 && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "account") {
+			continue
+		}
+		rows = append(rows, parseRow(record, line))
+	}
+	return rows, nil
+}
+
+func parseRow(record []string, line int) Row {
+	row := Row{line: line}
+	if len(record) != 4 {
+		row.err = fmt.Errorf("expected 4 columns, got %d", len(record))
+		return row
+	}
+	row.Account = strings.TrimSpace(record[0])
+	row.Coffee = strings.TrimSpace(record[1])
+	quantity, err := strconv.Atoi(strings.TrimSpace(record[2]))
+	if err != nil {
+		row.err = fmt.Errorf("invalid quantity '%s'", record[2])
+		return row
+	}
+	row.Quantity = quantity
+	for _, layout := range tallyTimeLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(record[3]), time.Local); err == nil {
+			row.Time = t
+			return row
+		}
+	}
+	row.err = fmt.Errorf("invalid timestamp '%s'", record[3])
+	return row
+}
+
+func toEvents(receipts []ReceiptIssued) []event.Event {
+	events := make([]event.Event, 0, len(receipts))
+	for _, r := range receipts {
+		events = append(events, r)
+	}
+	return events
+}
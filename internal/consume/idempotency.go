@@ -0,0 +1,127 @@
+package consume
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IdempotencyTTL is the duration an idempotency key protects against repeated charges.
+const IdempotencyTTL = 24 * time.Hour
+
+const maxIdempotencyKeyLength = 255
+
+var ErrorInvalidIdempotencyKey = fmt.Errorf("idempotency key must have 1 to %d characters", maxIdempotencyKeyLength)
+var ErrorIdempotencyKeyReused = errors.New("idempotency key has been used for a different order")
+
+// ConsumeOnce works like Consume, but charges an order only once for the same idempotency key.
+//
+// The first receipt issued for a key is returned for every repetition of the order within the IdempotencyTTL,
+// replayed reports whether the receipt has been issued by an earlier request. Reusing a key for a different
+// order within the IdempotencyTTL fails with ErrorIdempotencyKeyReused.
+func (s *Service) ConsumeOnce(key string, o Order) (receipt *Receipt, replayed bool, err error) {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return nil, false, ErrorInvalidIdempotencyKey
+	}
+	return s.consumeOnce(idempotencyID(key), key, o, IdempotencyTTL)
+}
+
+// ConsumeCommand works like ConsumeOnce for commands of offline clients, which are identified by a
+// client-generated command ID. Other than idempotency keys, command IDs never expire.
+func (s *Service) ConsumeCommand(commandID string, o Order) (receipt *Receipt, replayed bool, err error) {
+	if commandID == "" || len(commandID) > maxIdempotencyKeyLength {
+		return nil, false, ErrorInvalidIdempotencyKey
+	}
+	return s.consumeOnce(clientCommandID(commandID), commandID, o, 0)
+}
+
+// consumeOnce charges an order once for the key with the given aggregate ID. A ttl of zero never expires.
+func (s *Service) consumeOnce(id string, key string, o Order, ttl time.Duration) (*Receipt, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used, found, err := s.findIdempotencyKey(id, time.Now(), ttl)
+	if err != nil {
+		return nil, false, err
+	}
+	if !found {
+		receipt, err := s.consume(o, newIdempotencyKeyUsed(id, key, o))
+		return receipt, false, err
+	}
+	if used.Order != fingerprint(o) {
+		return nil, false, ErrorIdempotencyKeyReused
+	}
+	receipt, err := s.Receipt(used.ReceiptID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load receipt of idempotency key: %w", err)
+	}
+	return receipt, true, nil
+}
+
+// findIdempotencyKey returns the latest use of the key with the given aggregate ID, if it has not expired yet.
+func (s *Service) findIdempotencyKey(id string, now time.Time, ttl time.Duration) (IdempotencyKeyUsed, bool, error) {
+	entries, err := s.repo.LoadAll(id)
+	if err != nil {
+		return IdempotencyKeyUsed{}, false, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+	if len(entries) == 0 {
+		return IdempotencyKeyUsed{}, false, nil
+	}
+	e, err := toIdempotencyKeyUsed(entries[len(entries)-1])
+	if err != nil {
+		return IdempotencyKeyUsed{}, false, err
+	}
+	return e, ttl == 0 || now.Sub(e.OccurredOn) < ttl, nil
+}
+
+func idempotencyID(key string) string {
+	return "idempotency-key:" + key
+}
+
+func clientCommandID(commandID string) string {
+	return "client-command:" + commandID
+}
+
+// fingerprint identifies an order to detect the reuse of an idempotency key for a different order.
+func fingerprint(o Order) string {
+	f := fmt.Sprintf("%s|%s|%s|%d", o.AccountID, o.CoffeeID, o.MachineID, o.Quantity)
+	// appended only to orders of a beverage, so fingerprints recorded before beverages existed still match
+	if o.BeverageID != "" {
+		f += "|beverage:" + o.BeverageID
+	}
+	if o.Override != "" {
+		f += "|override:" + o.Override
+	}
+	if !o.Time.IsZero() {
+		f += "|" + o.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return f
+}
+
+// The IdempotencyKeyUsed event records the receipt that has been issued for an idempotency key.
+type IdempotencyKeyUsed struct {
+	ID         string    `json:"id"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	Key        string    `json:"key"`
+	Order      string    `json:"order"` // the fingerprint of the order
+	ReceiptID  string    `json:"receiptID"`
+}
+
+// newIdempotencyKeyUsed prepares the event for the key with the given aggregate ID,
+// the receipt and the time are set when the order is charged.
+func newIdempotencyKeyUsed(id string, key string, o Order) *IdempotencyKeyUsed {
+	return &IdempotencyKeyUsed{ID: id, EventType: "IdempotencyKeyUsed", Key: key, Order: fingerprint(o)}
+}
+
+func (e IdempotencyKeyUsed) AggregateID() string {
+	return e.ID
+}
+
+func (e IdempotencyKeyUsed) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e IdempotencyKeyUsed) Type() string {
+	return e.EventType
+}
@@ -0,0 +1,104 @@
+package consume
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+const recipient string = "Coffy - Consume Service"
+
+// guest is the submitter of receipts that have been paid cash.
+const guest string = "Guest"
+
+// A Receipt documents a single consumption request. It is issued once, when the consumption is
+// charged, and never changes afterward. The account.CoffyConsumed events it covers carry its ID.
+type Receipt struct {
+	ID         string    `json:"id"`
+	AccountID  string    `json:"account_id,omitempty"` // the account that has been charged, empty for cash payments
+	Payment    Payment   `json:"payment"`
+	Recipient  string    `json:"recipient"`
+	Submitter  string    `json:"submitter"`
+	CoffeeID   string    `json:"coffee_id"`
+	BeverageID string    `json:"beverage_id,omitempty"` // the beverage prepared from the coffee, if any
+	MachineID  string    `json:"machine_id,omitempty"`  // the machine the coffee was taken from, if known
+	Quantity   int       `json:"quantity"`
+	UnitPrice  float64   `json:"unit_price"`        // the price of a single cup before pricing rules and plans
+	Covered    int       `json:"covered,omitempty"` // the number of cups covered by a subscription plan
+	Amount     float64   `json:"amount"`            // the amount charged to the account
+	Subsidy    float64   `json:"subsidy"`
+	Override   string    `json:"override,omitempty"` // the reason an admin skipped the quota rules, if so
+	Purpose    string    `json:"purpose"`
+	Date       time.Time `json:"date"`
+}
+
+func newReceipt(e ReceiptIssued) Receipt {
+	payment := e.Payment
+	if payment == "" {
+		payment = PaymentAccount
+	}
+	return Receipt{
+		ID:         e.ReceiptID,
+		AccountID:  e.AccountID,
+		Payment:    payment,
+		Recipient:  recipient,
+		Submitter:  e.Submitter,
+		CoffeeID:   e.CoffeeID,
+		BeverageID: e.BeverageID,
+		MachineID:  e.MachineID,
+		Quantity:   e.Quantity,
+		UnitPrice:  e.UnitPrice,
+		Covered:    e.Covered,
+		Amount:     e.Amount,
+		Subsidy:    e.Subsidy,
+		Override:   e.Override,
+		Purpose:    purpose(e),
+		Date:       e.OccurredOn,
+	}
+}
+
+// The ReceiptIssued event records a receipt with all details of the consumption it covers.
+type ReceiptIssued struct {
+	ReceiptID  string    `json:"receiptID"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	AccountID  string    `json:"accountID"`
+	Payment    Payment   `json:"payment,omitempty"`
+	Submitter  string    `json:"submitter"`
+	CoffeeID   string    `json:"coffeeID"`
+	CoffeeType string    `json:"coffeeType"`
+	BeverageID string    `json:"beverageID,omitempty"`
+	Beverage   string    `json:"beverage,omitempty"`
+	Beans      float64   `json:"beans,omitempty"` // grams of beans per cup of the beverage, zero for plain coffee
+	MachineID  string    `json:"machineID,omitempty"`
+	Quantity   int       `json:"quantity"`
+	UnitPrice  float64   `json:"unitPrice"`
+	Covered    int       `json:"covered,omitempty"`
+	Amount     float64   `json:"amount"`
+	Subsidy    float64   `json:"subsidy"`
+	Override   string    `json:"override,omitempty"` // the reason the quota rules have been skipped
+}
+
+// purpose describes the consumption covered by the receipt.
+func purpose(e ReceiptIssued) string {
+	if e.Beverage != "" {
+		return fmt.Sprintf("consumption of '%s' (%s)", e.Beverage, e.CoffeeType)
+	}
+	return fmt.Sprintf("consumption of '%s'", e.CoffeeType)
+}
+
+func newReceiptIssued(accountID string, occurred time.Time) ReceiptIssued {
+	return ReceiptIssued{ReceiptID: uuid.New().String(), OccurredOn: occurred, EventType: "ReceiptIssued", AccountID: accountID}
+}
+
+func (e ReceiptIssued) AggregateID() string {
+	return e.ReceiptID
+}
+
+func (e ReceiptIssued) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e ReceiptIssued) Type() string {
+	return e.EventType
+}
@@ -2,53 +2,445 @@ package consume
 
 import (
 	"coffy/internal/account"
+	"coffy/internal/beverage"
+	"coffy/internal/cashbox"
+	"coffy/internal/equipment"
+	"coffy/internal/event"
+	"coffy/internal/pricing"
 	"coffy/internal/product"
+	"coffy/internal/quota"
+	"coffy/internal/storage"
+	"coffy/internal/subscription"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 )
 
-const recipient string = "Coffy - Consume Service"
-
 type Service struct {
+	repo storage.EventRepository
+	// serialises consumptions with an idempotency key, so concurrent retries are charged once
+	mu         sync.Mutex
 	accounting *account.Accounting
 	product    *product.Service
+	beverages  *beverage.Service
+	machines   *equipment.Service
+	pricing    *pricing.Engine
+	plans      *subscription.Service
+	quotas     *quota.Limiter
+	cash       *cashbox.Service
 }
 
-type Receipt struct {
-	Recipient string    `json:"recipient"`
-	Submitter string    `json:"submitter"`
-	Amount    float64   `json:"amount"`
-	Purpose   string    `json:"purpose"`
-	Date      time.Time `json:"date"`
+// An Order requests the consumption of a number of cups of a coffee, or of a beverage prepared from a coffee.
+type Order struct {
+	AccountID  string    // the account to charge
+	CoffeeID   string    // the coffee consumed, optional if the coffee is taken from a machine
+	BeverageID string    // the beverage consumed, optional; its coffee replaces the coffee of the order
+	MachineID  string    // the machine the coffee was taken from, optional
+	Quantity   int       // the number of cups
+	Time       time.Time // the time of the consumption, zero or future times are replaced by now
+	Override   string    // the reason an admin skips the quota rules, recorded on the receipt; empty to apply them
+	Payment    Payment   // the payment method, charging the account by default
 }
 
-func NewService(accounting *account.Accounting, product *product.Service) *Service {
-	return &Service{accounting: accounting, product: product}
+// item is the product of an order: a coffee, which is optionally prepared as a beverage.
+type item struct {
+	coffee   *product.Coffee
+	beverage *beverage.Beverage // nil if the coffee is consumed as is
 }
 
-func (s *Service) Consume(accountID string, productID string, n int) (*Receipt, error) {
-	// first fetch the account
-	a, err := s.accounting.Find(accountID)
+// priceAt returns the price of a cup at the given time.
+func (i item) priceAt(t time.Time) float64 {
+	if i.beverage == nil {
+		return i.coffee.PriceAt(t)
+	}
+	return i.beverage.Pricing().PriceFor(i.coffee.PriceAt(t))
+}
+
+// describe records the coffee and the beverage of the item on the receipt.
+func (i item) describe(issued *ReceiptIssued) {
+	issued.CoffeeID = i.coffee.AggregateID
+	issued.CoffeeType = i.coffee.Type
+	if i.beverage != nil {
+		issued.BeverageID = i.beverage.ID
+		issued.Beverage = i.beverage.Name()
+		issued.Beans = i.beverage.Recipe().Beans
+	}
+}
+
+// consumption returns a consumption of a cup of the item, which has yet to be charged.
+func (i item) consumption() account.Consumption {
+	c := account.Consumption{CoffeeType: i.coffee.Type}
+	if i.beverage != nil {
+		c.Beverage = i.beverage.Name()
+	}
+	return c
+}
+
+// Payment is the method an order is paid with.
+type Payment string
+
+const (
+	PaymentAccount Payment = "account" // the order is charged to the account
+	PaymentCash    Payment = "cash"    // a guest pays cash into the cash box
+)
+
+func NewService(repo *storage.EventRepository, accounting *account.Accounting, product *product.Service, beverages *beverage.Service, machines *equipment.Service, pricing *pricing.Engine, plans *subscription.Service, quotas *quota.Limiter, cash *cashbox.Service) *Service {
+	return &Service{repo: *repo, accounting: accounting, product: product, beverages: beverages, machines: machines, pricing: pricing, plans: plans, quotas: quotas, cash: cash}
+}
+
+// Consume charges the account of the order and issues a Receipt. The consumptions and the receipt are saved together.
+//
+// Orders with a machine but without a coffee are charged with the coffee currently loaded in the machine.
+// Orders of a beverage are charged with the price of the beverage.
+// Orders exceeding a quota are rejected with an error wrapping quota.ErrorQuotaExceeded, unless they override it.
+func (s *Service) Consume(o Order) (*Receipt, error) {
+	return s.consume(o, nil)
+}
+
+// consume charges the account of the order. A non-nil idempotency key is recorded together
+// with the receipt, so repeated requests with the same key can be answered with it.
+func (s *Service) consume(o Order, key *IdempotencyKeyUsed) (*Receipt, error) {
+	if o.Quantity < 1 {
+		return nil, ErrorInvalidQuantity
+	}
+	switch o.Payment {
+	case PaymentAccount, "":
+	case PaymentCash:
+		if o.AccountID != "" {
+			return nil, fmt.Errorf("%w: cash payments do not charge an account", ErrorInvalidPayment)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown payment method '%s'", ErrorInvalidPayment, o.Payment)
+	}
+	// first fetch the account, guests paying cash have none
+	var a *account.Account
+	if o.Payment != PaymentCash {
+		found, err := s.accounting.Resolve(o.AccountID)
+		if err != nil {
+			return nil, errors.Join(ErrorAccountNotFound, err)
+		}
+		a = found
+	}
+	i, err := s.item(o)
 	if err != nil {
-		return nil, errors.Join(ErrorAccountNotFound, err)
+		return nil, err
+	}
+
+	now := time.Now()
+	occurred := now
+	if !o.Time.IsZero() && o.Time.Before(now) {
+		occurred = o.Time
 	}
-	p, err := s.product.Find(productID)
+	if !i.coffee.AvailableAt(occurred) {
+		return nil, fmt.Errorf("%w: '%s'", product.ErrorDiscontinued, i.coffee.Type)
+	}
+	if a == nil {
+		return s.sell(i, o, occurred, key)
+	}
+	issued, consumptions, err := s.issue(a, i, o, occurred)
+	if err != nil {
+		return nil, err
+	}
+	if o.Override == "" && s.quotas.Active() {
+		history, err := s.accounting.History(a.ID())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load consumptions: %w", err)
+		}
+		if err := s.quotas.Check(a, history, consumptions, occurred); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := convertAll(withKey([]event.Event{issued}, key, issued.ReceiptID))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = s.accounting.Consume(a.ID(), consumptions, entries...); err != nil {
+		return nil, errors.Join(errors.New("failed to consume product"), err)
+	}
+	receipt := newReceipt(issued)
+	return &receipt, nil
+}
+
+// item finds the coffee and the beverage of the order. Removed beverages cannot be ordered.
+func (s *Service) item(o Order) (item, error) {
+	if o.BeverageID == "" {
+		p, err := s.coffee(o)
+		return item{coffee: p}, err
+	}
+	b, err := s.beverages.Find(o.BeverageID)
+	if err != nil {
+		return item{}, err
+	}
+	if b.Removed() {
+		return item{}, fmt.Errorf("%w: '%s'", beverage.ErrorRemoved, b.Name())
+	}
+	o.CoffeeID = b.CoffeeID()
+	p, err := s.coffee(o)
+	return item{coffee: p, beverage: b}, err
+}
+
+// coffee finds the coffee of the order. Orders with a machine but without a coffee get the coffee
+// currently loaded in the machine.
+func (s *Service) coffee(o Order) (*product.Coffee, error) {
+	if o.MachineID != "" {
+		m, err := s.machines.FindById(o.MachineID)
+		if err != nil {
+			return nil, errors.Join(ErrorMachineNotFound, err)
+		}
+		if o.CoffeeID == "" {
+			if o.CoffeeID, err = m.Coffee(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	p, err := s.product.Find(o.CoffeeID)
 	if err != nil {
 		return nil, errors.Join(ErrorProductNotFound, err)
 	}
+	return p, nil
+}
 
-	if err = s.accounting.Consume(accountID, p.Price(), p.Type, n); err != nil {
-		return nil, errors.Join(errors.New("failed to consume product"), err)
+// sell records the order of a guest as cash sale in the cash box. Pricing rules for all accounts
+// apply to guests as well.
+func (s *Service) sell(i item, o Order, t time.Time, key *IdempotencyKeyUsed) (*Receipt, error) {
+	issued := newReceiptIssued("", t)
+	issued.Submitter = guest
+	issued.Payment = PaymentCash
+	i.describe(&issued)
+	issued.MachineID = o.MachineID
+	issued.Quantity = o.Quantity
+	issued.UnitPrice = i.priceAt(t)
+	for range o.Quantity {
+		quote := s.pricing.Quote(i.priceAt(t), pricing.Context{CoffeeID: i.coffee.AggregateID, Time: t})
+		issued.Amount += quote.Charged
+		issued.Subsidy += quote.Subsidy
+	}
+	entries, err := convertAll(withKey([]event.Event{issued}, key, issued.ReceiptID))
+	if err != nil {
+		return nil, err
+	}
+	sale := cashbox.Sale{
+		CoffeeID:   i.coffee.AggregateID,
+		CoffeeType: i.coffee.Type,
+		MachineID:  o.MachineID,
+		Quantity:   o.Quantity,
+		UnitPrice:  i.priceAt(t),
+		Amount:     issued.Amount,
+		ReceiptID:  issued.ReceiptID,
+		Time:       t,
+	}
+	if err := s.cash.Sell(sale, entries...); err != nil {
+		return nil, errors.Join(errors.New("failed to record cash sale"), err)
 	}
+	receipt := newReceipt(issued)
+	return &receipt, nil
+}
+
+// withKey appends the idempotency key to the events, if there is one.
+func withKey(events []event.Event, key *IdempotencyKeyUsed, receiptID string) []event.Event {
+	if key == nil {
+		return events
+	}
+	key.ReceiptID = receiptID
+	key.OccurredOn = time.Now()
+	return append(events, *key)
+}
+
+// issue determines the consumptions of an order at the given time and the receipt covering them.
+func (s *Service) issue(a *account.Account, i item, o Order, t time.Time) (ReceiptIssued, []account.Consumption, error) {
+	consumptions, err := s.charge(a, i, o.Quantity, t)
+	if err != nil {
+		return ReceiptIssued{}, nil, err
+	}
+	issued := newReceiptIssued(a.ID(), t)
+	issued.Submitter = a.Owner()
+	i.describe(&issued)
+	issued.MachineID = o.MachineID
+	issued.Override = o.Override
+	issued.Quantity = o.Quantity
+	issued.UnitPrice = i.priceAt(t)
+	for n, c := range consumptions {
+		consumptions[n].ReceiptID = issued.ReceiptID
+		consumptions[n].MachineID = o.MachineID
+		consumptions[n].OccurredOn = t
+		issued.Amount += c.Costs
+		issued.Subsidy += c.Subsidy
+		if c.PlanID != "" {
+			issued.Covered++
+		}
+	}
+	return issued, consumptions, nil
+}
+
+// Receipt returns the receipt with the given ID.
+func (s *Service) Receipt(receiptID string) (*Receipt, error) {
+	entries, err := s.repo.LoadAll(receiptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load receipt '%s': %w", receiptID, err)
+	}
+	for _, entry := range entries {
+		if entry.EventType != "ReceiptIssued" {
+			continue
+		}
+		e, err := toReceiptIssued(entry)
+		if err != nil {
+			return nil, err
+		}
+		receipt := newReceipt(e)
+		return &receipt, nil
+	}
+	return nil, ErrorReceiptNotFound
+}
+
+// Receipts returns all receipts issued for an account, including the receipts of accounts
+// that have been merged into it, ordered by date.
+func (s *Service) Receipts(accountID string) ([]Receipt, error) {
+	ids, err := s.accountIDs(accountID)
+	if err != nil {
+		return nil, err
+	}
+	query, err := s.repo.FetchByEventType("ReceiptIssued")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load receipts: %w", err)
+	}
+	receipts := make([]Receipt, 0)
+	for _, entry := range query {
+		e, err := toReceiptIssued(entry)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(ids, e.AccountID) {
+			receipts = append(receipts, newReceipt(e))
+		}
+	}
+	slices.SortStableFunc(receipts, func(a Receipt, b Receipt) int { return a.Date.Compare(b.Date) })
+	return receipts, nil
+}
 
-	return &Receipt{
-		Recipient: recipient,
-		Submitter: a.Owner(),
-		Amount:    float64(n) * p.Price(),
-		Purpose:   fmt.Sprintf("consumption of '%s'", p.Type),
-		Date:      time.Now()}, nil
+// Issued returns the receipts issued since the given time, including cash sales, ordered by date.
+func (s *Service) Issued(since time.Time) ([]ReceiptIssued, error) {
+	query, err := s.repo.FetchByEventType("ReceiptIssued")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load receipts: %w", err)
+	}
+	issued := make([]ReceiptIssued, 0, len(query))
+	for _, entry := range query {
+		e, err := toReceiptIssued(entry)
+		if err != nil {
+			return nil, err
+		}
+		if !e.OccurredOn.Before(since) {
+			issued = append(issued, e)
+		}
+	}
+	slices.SortStableFunc(issued, func(a ReceiptIssued, b ReceiptIssued) int { return a.OccurredOn.Compare(b.OccurredOn) })
+	return issued, nil
+}
+
+// accountIDs returns the ID of the account and of all accounts that have been merged into it.
+func (s *Service) accountIDs(accountID string) ([]string, error) {
+	a, err := s.accounting.Find(accountID)
+	if err != nil {
+		return nil, errors.Join(ErrorAccountNotFound, err)
+	}
+	ids := []string{a.ID()}
+	for _, source := range a.MergedFrom() {
+		merged, err := s.accountIDs(source)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, merged...)
+	}
+	return ids, nil
+}
+
+// charge determines the consumptions for n cups of a coffee or beverage.
+//
+// Cups covered by the account's subscription plan are charged with zero costs, all other cups are charged
+// with the overage price of the plan or the price of the item, considering the pricing rules. The plan
+// applies only once the fee of the current billing period has been charged.
+func (s *Service) charge(a *account.Account, i item, n int, now time.Time) ([]account.Consumption, error) {
+	var plan *subscription.Plan
+	period := s.plans.Period(now)
+	if sub, ok := a.Subscription(); ok && a.Billed(period) {
+		found, err := s.plans.Find(sub.PlanID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load subscription plan: %w", err)
+		}
+		plan = found
+	}
+	covered := a.CoveredCups(period)
+	consumptions := make([]account.Consumption, 0, n)
+	for range n {
+		if plan != nil && plan.Covers(covered) {
+			c := i.consumption()
+			c.PlanID = plan.AggregateID
+			c.Period = period
+			consumptions = append(consumptions, c)
+			covered++
+			continue
+		}
+		price := i.priceAt(now)
+		if plan != nil {
+			if overage, ok := plan.OveragePrice(); ok {
+				price = overage
+			}
+		}
+		quote := s.pricing.Quote(price, pricing.Context{Group: a.Group(), CoffeeID: i.coffee.AggregateID, Time: now})
+		c := i.consumption()
+		c.Costs, c.Subsidy = quote.Charged, quote.Subsidy
+		consumptions = append(consumptions, c)
+	}
+	return consumptions, nil
 }
 
 var ErrorProductNotFound = errors.New("product not found")
 var ErrorAccountNotFound = errors.New("account not found")
+var ErrorMachineNotFound = errors.New("machine not found")
+var ErrorInvalidPayment = errors.New("invalid payment")
+var ErrorReceiptNotFound = errors.New("receipt not found")
+var ErrorInvalidQuantity = errors.New("quantity must be at least one")
+
+func convertAll(events []event.Event) ([]storage.EventEntry, error) {
+	entries := make([]storage.EventEntry, 0, len(events))
+	for _, e := range events {
+		entry, err := toEventEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func toEventEntry(e event.Event) (storage.EventEntry, error) {
+	switch t := e.(type) {
+	case ReceiptIssued, IdempotencyKeyUsed:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: e.AggregateID(), EventType: e.Type(), Date: e.Occurred(), EventData: data}, nil
+	default:
+		return storage.EventEntry{}, fmt.Errorf("failed to convert event to entry: unknown event type '%T'", t)
+	}
+}
+
+func toReceiptIssued(entry storage.EventEntry) (ReceiptIssued, error) {
+	e := ReceiptIssued{}
+	if err := json.Unmarshal(entry.EventData, &e); err != nil {
+		return e, fmt.Errorf("failed to unmarshal event data as ReceiptIssued: %w", err)
+	}
+	return e, nil
+}
+
+func toIdempotencyKeyUsed(entry storage.EventEntry) (IdempotencyKeyUsed, error) {
+	e := IdempotencyKeyUsed{}
+	if err := json.Unmarshal(entry.EventData, &e); err != nil {
+		return e, fmt.Errorf("failed to unmarshal event data as IdempotencyKeyUsed: %w", err)
+	}
+	return e, nil
+}
@@ -0,0 +1,351 @@
+package consume
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/beverage"
+	"coffy/internal/cashbox"
+	"coffy/internal/coffy"
+	"coffy/internal/equipment"
+	"coffy/internal/pricing"
+	"coffy/internal/product"
+	"coffy/internal/quota"
+	"coffy/internal/storage/storagetest"
+	"coffy/internal/subscription"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T) (*Service, *account.Accounting, Order) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	products := product.NewService(&repo)
+	s := NewService(&repo, accounting, products, beverage.NewService(&repo, products), equipment.NewService(&repo, products), pricing.NewEngine(), subscription.NewService(&repo, accounting, &coffy.BillingCfg{Day: 1, Hour: 6}), quota.NewLimiter(), cashbox.NewService(&repo))
+
+	a, err := accounting.Create("Coffy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := products.Create("Espresso", 0.50, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, accounting, Order{AccountID: a.ID(), CoffeeID: p.AggregateID, Quantity: 2}
+}
+
+func TestConsumeReceipt(t *testing.T) {
+	s, _, order := newTestService(t)
+	receipt, err := s.Consume(order)
+	if err != nil {
+		t.Fatalf("Error consuming: %s", err.Error())
+	}
+	if receipt.Quantity != 2 || receipt.UnitPrice != 0.50 || receipt.Amount != 1.00 {
+		t.Errorf("Unexpected receipt %+v", receipt)
+	}
+	stored, err := s.Receipt(receipt.ID)
+	if err != nil || stored.ID != receipt.ID || stored.Amount != receipt.Amount || !stored.Date.Equal(receipt.Date) {
+		t.Errorf("Stored receipt should equal the issued receipt, got %+v (%v)", stored, err)
+	}
+	receipts, err := s.Receipts(order.AccountID)
+	if err != nil || len(receipts) != 1 {
+		t.Errorf("Expected one receipt of the account, got %d (%v)", len(receipts), err)
+	}
+	if _, err := s.Receipt("unknown"); !errors.Is(err, ErrorReceiptNotFound) {
+		t.Errorf("Expected ErrorReceiptNotFound, got %v", err)
+	}
+}
+
+func TestConsumeOnce(t *testing.T) {
+	s, accounting, order := newTestService(t)
+	first, replayed, err := s.ConsumeOnce("retry", order)
+	if err != nil || replayed {
+		t.Fatalf("First request should be charged, got replayed %t (%v)", replayed, err)
+	}
+	again, replayed, err := s.ConsumeOnce("retry", order)
+	if err != nil || !replayed || again.ID != first.ID {
+		t.Errorf("Repeated request should return the first receipt, got replayed %t (%v)", replayed, err)
+	}
+	if a, _ := accounting.Find(order.AccountID); a.Balance() != -1.00 {
+		t.Errorf("Balance should be %.2f, got %.2f", -1.00, a.Balance())
+	}
+
+	order.Quantity = 1
+	if _, _, err := s.ConsumeOnce("retry", order); !errors.Is(err, ErrorIdempotencyKeyReused) {
+		t.Errorf("Expected ErrorIdempotencyKeyReused, got %v", err)
+	}
+	if _, _, err := s.ConsumeOnce("", order); !errors.Is(err, ErrorInvalidIdempotencyKey) {
+		t.Errorf("Expected ErrorInvalidIdempotencyKey, got %v", err)
+	}
+}
+
+func TestIdempotencyKeyExpires(t *testing.T) {
+	s, _, order := newTestService(t)
+	if _, _, err := s.ConsumeOnce("retry", order); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.findIdempotencyKey(idempotencyID("retry"), time.Now(), IdempotencyTTL); !found {
+		t.Errorf("Key should be found within the TTL")
+	}
+	if _, found, _ := s.findIdempotencyKey(idempotencyID("retry"), time.Now().Add(IdempotencyTTL), IdempotencyTTL); found {
+		t.Errorf("Key should be expired after the TTL")
+	}
+}
+
+func TestConsumeFromMachine(t *testing.T) {
+	s, accounting, order := newTestService(t)
+	m, err := s.machines.Create("Phillips", "EP2334/10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := Order{AccountID: order.AccountID, MachineID: m.AggregateID, Quantity: 1}
+	if _, err := s.Consume(empty); !errors.Is(err, equipment.ErrorNoCoffeeLoaded) {
+		t.Errorf("Expected ErrorNoCoffeeLoaded, got %v", err)
+	}
+	if _, err := s.machines.LoadCoffee(m.AggregateID, order.CoffeeID); err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := s.Consume(empty)
+	if err != nil {
+		t.Fatalf("Error consuming from machine: %s", err.Error())
+	}
+	if receipt.CoffeeID != order.CoffeeID || receipt.MachineID != m.AggregateID {
+		t.Errorf("Receipt should name the loaded coffee and the machine, got %+v", receipt)
+	}
+	history, _ := accounting.History(order.AccountID)
+	if len(history) != 1 || history[0].MachineID != m.AggregateID {
+		t.Errorf("Consumption should record the machine ID, got %+v", history)
+	}
+	if _, err := s.Consume(Order{AccountID: order.AccountID, MachineID: "unknown", Quantity: 1}); !errors.Is(err, ErrorMachineNotFound) {
+		t.Errorf("Expected ErrorMachineNotFound, got %v", err)
+	}
+}
+
+func TestConsumeBatch(t *testing.T) {
+	s, accounting, order := newTestService(t)
+	notified := 0
+	accounting.OnBalanceChange(func(*account.Account, float64) { notified++ })
+	if _, err := s.ConsumeBatch(nil); !errors.Is(err, ErrorEmptyBatch) {
+		t.Errorf("Expected ErrorEmptyBatch, got %v", err)
+	}
+	yesterday := time.Now().Add(-24 * time.Hour)
+	rows := []Row{
+		{Account: order.AccountID, Coffee: order.CoffeeID, Quantity: 2, Time: yesterday},
+		{Account: "coffy", Coffee: "espresso", Quantity: 1, Time: yesterday.Add(time.Hour)},
+	}
+	invalid := append(slices.Clone(rows),
+		Row{Account: "unknown", Coffee: "espresso", Quantity: 1, Time: yesterday},
+		Row{Account: "coffy", Coffee: "espresso", Quantity: 0, Time: time.Now().Add(time.Hour)})
+	report, err := s.ConsumeBatch(invalid)
+	if !errors.Is(err, ErrorBatchRejected) || report.Recorded {
+		t.Fatalf("Expected batch to be rejected, got %v", err)
+	}
+	if report.Rows[0].Error != "" || report.Rows[2].Error == "" || !strings.Contains(report.Rows[3].Error, ";") {
+		t.Errorf("Unexpected row errors %+v", report.Rows)
+	}
+	if a, _ := accounting.Find(order.AccountID); a.Balance() != 0 {
+		t.Errorf("Rejected batch should not be charged, got balance %.2f", a.Balance())
+	}
+
+	report, err = s.ConsumeBatch(rows)
+	if err != nil || !report.Recorded {
+		t.Fatalf("Error recording batch: %v", err)
+	}
+	history, _ := accounting.History(order.AccountID)
+	if len(history) != 3 || !history[0].OccurredOn.Equal(yesterday) {
+		t.Errorf("Consumptions should be recorded with the original timestamp, got %+v", history)
+	}
+	if r, err := s.Receipt(report.Rows[1].ReceiptID); err != nil || r.Quantity != 1 {
+		t.Errorf("Expected receipt of the second row, got %+v (%v)", r, err)
+	}
+	if notified != 0 {
+		t.Errorf("Imported consumptions should not notify balance listeners, got %d notifications", notified)
+	}
+}
+
+func TestParseTally(t *testing.T) {
+	sheet := `account,coffee,quantity,timestamp
+Coffy, Espresso, 2, 2024-03-01 09:30
+Coffy,Espresso,two,2024-03-01
+Coffy,Espresso,1
+`
+	rows, err := ParseTally(strings.NewReader(sheet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+	if rows[0].Coffee != "Espresso" || rows[0].Quantity != 2 || rows[0].Time.Hour() != 9 || rows[0].line != 2 {
+		t.Errorf("Unexpected first row %+v", rows[0])
+	}
+	if rows[1].err == nil || rows[2].err == nil {
+		t.Errorf("Expected errors for invalid rows, got %v and %v", rows[1].err, rows[2].err)
+	}
+}
+
+func TestConsumeQuota(t *testing.T) {
+	s, _, order := newTestService(t)
+	s.quotas = quota.NewLimiter(quota.Rule{Name: "health", Period: quota.Day, Max: 3})
+	if _, err := s.Consume(order); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Consume(order); !errors.Is(err, quota.ErrorQuotaExceeded) {
+		t.Errorf("Expected ErrorQuotaExceeded, got %v", err)
+	}
+	order.Override = "team event"
+	receipt, err := s.Consume(order)
+	if err != nil {
+		t.Fatalf("Override should skip the quota, got %v", err)
+	}
+	if receipt.Override != "team event" {
+		t.Errorf("Expected the override to be recorded on the receipt, got '%s'", receipt.Override)
+	}
+	if stored, _ := s.Receipt(receipt.ID); stored == nil || stored.Override != "team event" {
+		t.Errorf("Expected the override to be saved with the receipt, got %+v", stored)
+	}
+}
+
+func TestConsumeCash(t *testing.T) {
+	s, _, order := newTestService(t)
+	guest := Order{CoffeeID: order.CoffeeID, Quantity: 2, Payment: PaymentCash}
+	receipt, err := s.Consume(guest)
+	if err != nil {
+		t.Fatalf("Error selling cash: %s", err.Error())
+	}
+	if receipt.AccountID != "" || receipt.Payment != PaymentCash || receipt.Amount != 1.00 {
+		t.Errorf("Unexpected receipt %+v", receipt)
+	}
+	box, _ := s.cash.Find()
+	if box.Expected() != 1.00 || box.Sales() != 2 {
+		t.Errorf("Cash box should expect 1.00 for 2 cups, got %.2f for %d", box.Expected(), box.Sales())
+	}
+	guest.AccountID = order.AccountID
+	if _, err := s.Consume(guest); !errors.Is(err, ErrorInvalidPayment) {
+		t.Errorf("Expected ErrorInvalidPayment for cash payment with account, got %v", err)
+	}
+}
+
+func TestConsumeSubscriptionBeforeBilling(t *testing.T) {
+	s, accounting, order := newTestService(t)
+	plan, err := s.plans.Create("unlimited", 20, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.plans.Subscribe(order.AccountID, plan.AggregateID); err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := s.Consume(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Covered != 0 || receipt.Amount != 1.00 {
+		t.Errorf("Expected no cups to be covered before the fee is charged, got %+v", receipt)
+	}
+	if _, err := s.plans.Unsubscribe(order.AccountID); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := accounting.Find(order.AccountID)
+	if a.Balance() != -1.00 {
+		t.Errorf("Expected the cups to be paid after unsubscribing, got balance %.2f", a.Balance())
+	}
+
+	if _, err := s.plans.Subscribe(order.AccountID, plan.AggregateID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounting.ChargeFee(order.AccountID, plan.Fee(), s.plans.Period(time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	receipt, err = s.Consume(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Covered != 2 || receipt.Amount != 0 {
+		t.Errorf("Expected the cups to be covered once the fee is charged, got %+v", receipt)
+	}
+}
+
+func TestConsumeChargesPriceAtConsumptionTime(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	products := product.NewService(&repo)
+	s := NewService(&repo, accounting, products, beverage.NewService(&repo, products), equipment.NewService(&repo, products), pricing.NewEngine(), subscription.NewService(&repo, accounting, &coffy.BillingCfg{Day: 1, Hour: 6}), quota.NewLimiter(), cashbox.NewService(&repo))
+	a, err := accounting.Create("Coffy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := products.Create("Espresso", 0.50, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	if _, err := products.ChangePrice(p.AggregateID, 0.80, "new beans"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := products.SchedulePrice(p.AggregateID, 1.00, "roaster raises prices", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	offline, err := s.Consume(Order{AccountID: a.ID(), CoffeeID: p.AggregateID, Quantity: 1, Time: before})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offline.UnitPrice != 0.50 {
+		t.Errorf("Expected the price before the change to be charged, got %.2f", offline.UnitPrice)
+	}
+	current, err := s.Consume(Order{AccountID: a.ID(), CoffeeID: p.AggregateID, Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.UnitPrice != 0.80 {
+		t.Errorf("Expected the current price to be charged before the scheduled price applies, got %.2f", current.UnitPrice)
+	}
+}
+
+func TestConsumeDiscontinuedCoffee(t *testing.T) {
+	s, _, order := newTestService(t)
+	queued := order
+	queued.Time = time.Now()
+	if _, err := s.product.Discontinue(order.CoffeeID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Consume(order); !errors.Is(err, product.ErrorDiscontinued) {
+		t.Errorf("Expected ErrorDiscontinued, got %v", err)
+	}
+	if _, err := s.Consume(queued); err != nil {
+		t.Errorf("Expected an order placed before the coffee has been discontinued to be charged, got %v", err)
+	}
+	report, err := s.ConsumeBatch([]Row{{Account: order.AccountID, Coffee: "Espresso", Quantity: 1, Time: time.Now()}})
+	if !errors.Is(err, ErrorBatchRejected) || report.Rows[0].Error == "" {
+		t.Errorf("Expected batch with a discontinued coffee to be rejected, got %v", err)
+	}
+}
+
+func TestConsumeBeverage(t *testing.T) {
+	s, accounting, order := newTestService(t)
+	cappuccino, err := s.beverages.Create("Cappuccino", order.CoffeeID, beverage.Recipe{Beans: 7, Milk: 120}, beverage.Pricing{Factor: 1, Surcharge: 0.30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := s.Consume(Order{AccountID: order.AccountID, BeverageID: cappuccino.ID, Quantity: 2})
+	if err != nil {
+		t.Fatalf("Error consuming beverage: %s", err.Error())
+	}
+	if receipt.CoffeeID != order.CoffeeID || receipt.BeverageID != cappuccino.ID || receipt.UnitPrice != 0.80 || receipt.Amount != 1.60 {
+		t.Errorf("Receipt should charge the beverage price and name its coffee, got %+v", receipt)
+	}
+	history, _ := accounting.History(order.AccountID)
+	if len(history) != 2 || history[0].Beverage != "Cappuccino" || history[0].CoffyType != "Espresso" {
+		t.Errorf("Consumptions should record the beverage and the coffee, got %+v", history)
+	}
+	if _, err := s.beverages.Remove(cappuccino.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Consume(Order{AccountID: order.AccountID, BeverageID: cappuccino.ID, Quantity: 1}); !errors.Is(err, beverage.ErrorRemoved) {
+		t.Errorf("Expected ErrorRemoved, got %v", err)
+	}
+	if _, err := s.Consume(Order{AccountID: order.AccountID, BeverageID: "unknown", Quantity: 1}); !errors.Is(err, beverage.ErrorNotFound) {
+		t.Errorf("Expected beverage.ErrorNotFound, got %v", err)
+	}
+}
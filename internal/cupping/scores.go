@@ -0,0 +1,95 @@
+package cupping
+
+import (
+	"fmt"
+	"math"
+)
+
+// Scores are the sub-scores of the SCA cupping form an assessor gives a coffee.
+//
+// The quality attributes are scored from 6 to 10 in steps of 0.25. Uniformity, clean cup and sweetness
+// are scored from 0 to 10 in steps of 2, two points for each of the five cups. Defects are subtracted
+// from the total.
+type Scores struct {
+	FragranceAroma float64 `json:"fragrance_aroma"`
+	Flavor         float64 `json:"flavor"`
+	Aftertaste     float64 `json:"aftertaste"`
+	Acidity        float64 `json:"acidity"`
+	Body           float64 `json:"body"`
+	Balance        float64 `json:"balance"`
+	Uniformity     float64 `json:"uniformity"`
+	CleanCup       float64 `json:"clean_cup"`
+	Sweetness      float64 `json:"sweetness"`
+	Overall        float64 `json:"overall"`
+	Defects        float64 `json:"defects"` // taints and faults, e.g. 2 points per tainted cup and 4 per faulty cup
+}
+
+// Total returns the final score of the form, the sum of all attributes less the defects.
+func (s Scores) Total() float64 {
+	return s.FragranceAroma + s.Flavor + s.Aftertaste + s.Acidity + s.Body + s.Balance +
+		s.Uniformity + s.CleanCup + s.Sweetness + s.Overall - s.Defects
+}
+
+// Validate checks the scores against the scales of the cupping form.
+func (s Scores) Validate() error {
+	quality := []attribute{
+		{"fragrance_aroma", s.FragranceAroma}, {"flavor", s.Flavor}, {"aftertaste", s.Aftertaste},
+		{"acidity", s.Acidity}, {"body", s.Body}, {"balance", s.Balance}, {"overall", s.Overall},
+	}
+	for _, a := range quality {
+		if a.value < 6 || a.value > 10 || !isMultiple(a.value, 0.25) {
+			return fmt.Errorf("%s must be between 6 and 10 in steps of 0.25", a.name)
+		}
+	}
+	cups := []attribute{{"uniformity", s.Uniformity}, {"clean_cup", s.CleanCup}, {"sweetness", s.Sweetness}}
+	for _, a := range cups {
+		if a.value < 0 || a.value > 10 || !isMultiple(a.value, 2) {
+			return fmt.Errorf("%s must be between 0 and 10 in steps of 2", a.name)
+		}
+	}
+	if s.Defects < 0 || !isMultiple(s.Defects, 2) {
+		return fmt.Errorf("defects must not be negative and in steps of 2")
+	}
+	return nil
+}
+
+// average returns the mean of each attribute of the scores.
+func average(all []Scores) Scores {
+	var sum Scores
+	for _, s := range all {
+		sum.FragranceAroma += s.FragranceAroma
+		sum.Flavor += s.Flavor
+		sum.Aftertaste += s.Aftertaste
+		sum.Acidity += s.Acidity
+		sum.Body += s.Body
+		sum.Balance += s.Balance
+		sum.Uniformity += s.Uniformity
+		sum.CleanCup += s.CleanCup
+		sum.Sweetness += s.Sweetness
+		sum.Overall += s.Overall
+		sum.Defects += s.Defects
+	}
+	n := float64(len(all))
+	return Scores{
+		FragranceAroma: sum.FragranceAroma / n,
+		Flavor:         sum.Flavor / n,
+		Aftertaste:     sum.Aftertaste / n,
+		Acidity:        sum.Acidity / n,
+		Body:           sum.Body / n,
+		Balance:        sum.Balance / n,
+		Uniformity:     sum.Uniformity / n,
+		CleanCup:       sum.CleanCup / n,
+		Sweetness:      sum.Sweetness / n,
+		Overall:        sum.Overall / n,
+		Defects:        sum.Defects / n,
+	}
+}
+
+type attribute struct {
+	name  string
+	value float64
+}
+
+func isMultiple(v float64, step float64) bool {
+	return math.Abs(math.Remainder(v, step)) < 1e-9
+}
@@ -0,0 +1,192 @@
+package cupping
+
+import (
+	"coffy/internal/event"
+	"coffy/internal/product"
+	"coffy/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrorInvalidProperty = errors.New("invalid property")
+	ErrorNotFound        = errors.New("cupping session not found")
+)
+
+type Service struct {
+	repo    storage.EventRepository
+	coffees *product.Service
+	// serialises changes of sessions, so concurrent submissions do not miss each other
+	mu sync.Mutex
+}
+
+func NewService(repo *storage.EventRepository, coffees *product.Service) *Service {
+	return &Service{repo: *repo, coffees: coffees}
+}
+
+// Open starts a cupping session for a coffee.
+func (s *Service) Open(coffeeID string, name string) (*Session, error) {
+	if _, err := s.coffees.Find(coffeeID); err != nil {
+		return nil, err
+	}
+	session, err := NewSession(uuid.NewString(), coffeeID, name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	entries, err := convertAll(session.Events())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveAll(entries); err != nil {
+		return nil, fmt.Errorf("failed to save cupping session: %w", err)
+	}
+	session.Clear()
+	return session, nil
+}
+
+// Find returns the cupping session with the given ID.
+func (s *Service) Find(sessionID string) (*Session, error) {
+	entries, err := s.repo.LoadAll(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cupping session '%s': %w", sessionID, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: '%s'", ErrorNotFound, sessionID)
+	}
+	session := &Session{}
+	for _, entry := range entries {
+		e, err := convert(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cupping session '%s': %w", sessionID, err)
+		}
+		if err := session.apply(e); err != nil {
+			return nil, fmt.Errorf("failed to load cupping session '%s': %w", sessionID, err)
+		}
+	}
+	session.Clear()
+	return session, nil
+}
+
+// ListByCoffee returns the cupping sessions of a coffee, the latest first.
+func (s *Service) ListByCoffee(coffeeID string) ([]Session, error) {
+	entries, err := s.repo.FetchByEventType("CuppingSessionOpened")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cupping sessions: %w", err)
+	}
+	sessions := make([]Session, 0)
+	for _, entry := range entries {
+		opened := CuppingSessionOpened{}
+		if err := json.Unmarshal(entry.EventData, &opened); err != nil {
+			return nil, fmt.Errorf("failed to load cupping sessions: %w", err)
+		}
+		if opened.CoffeeID != coffeeID {
+			continue
+		}
+		session, err := s.Find(entry.AggregateID)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].Opened().After(sessions[j].Opened()) })
+	return sessions, nil
+}
+
+// Submit records the cupping form of an assessor.
+func (s *Service) Submit(sessionID string, assessor string, scores Scores) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, err := s.Find(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.Submit(assessor, scores); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	entries, err := convertAll(session.Events())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveAll(entries); err != nil {
+		return nil, fmt.Errorf("failed to save cupping session: %w", err)
+	}
+	session.Clear()
+	return session, nil
+}
+
+// Close ends the session and provides the averaged cupping score to the coffee. Both are saved together.
+func (s *Service) Close(sessionID string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, err := s.Find(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := session.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	entries, err := convertAll(session.Events())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.coffees.Assess(session.CoffeeID, result.Score, entries...); err != nil {
+		return nil, fmt.Errorf("failed to close cupping session: %w", err)
+	}
+	session.Clear()
+	return session, nil
+}
+
+func convertAll(events []event.Event) ([]storage.EventEntry, error) {
+	entries := make([]storage.EventEntry, 0, len(events))
+	for _, e := range events {
+		entry, err := toEventEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func convert(entry storage.EventEntry) (event.Event, error) {
+	switch entry.EventType {
+	case "CuppingSessionOpened":
+		e := CuppingSessionOpened{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as CuppingSessionOpened: %w", err)
+		}
+		return e, nil
+	case "ScoresSubmitted":
+		e := ScoresSubmitted{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as ScoresSubmitted: %w", err)
+		}
+		return e, nil
+	case "CuppingSessionClosed":
+		e := CuppingSessionClosed{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as CuppingSessionClosed: %w", err)
+		}
+		return e, nil
+	default:
+		return nil, fmt.Errorf("unknown event type '%s'", entry.EventType)
+	}
+}
+
+func toEventEntry(e event.Event) (storage.EventEntry, error) {
+	switch t := e.(type) {
+	case CuppingSessionOpened, ScoresSubmitted, CuppingSessionClosed:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: e.AggregateID(), EventType: e.Type(), Date: e.Occurred(), EventData: data}, nil
+	default:
+		return storage.EventEntry{}, fmt.Errorf("failed to convert event to entry: unknown event type '%T'", t)
+	}
+}
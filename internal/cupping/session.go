@@ -0,0 +1,216 @@
+package cupping
+
+import (
+	"coffy/internal/event"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// The lowest and highest cupping score a coffee can be assessed with.
+const (
+	minScore = 58
+	maxScore = 100
+)
+
+// Session is a cupping session, in which several assessors score a coffee on the SCA cupping form.
+// Closing the session averages the forms to the final cupping score of the coffee.
+type Session struct {
+	ID          string
+	CoffeeID    string
+	Name        string
+	opened      time.Time
+	assessors   []string          // the assessors in the order of their first submission
+	assessments map[string]Scores // the latest scores of each assessor
+	result      *Result           // the result once the session has been closed
+	events      []event.Event
+}
+
+// Assessment is the form of a single assessor.
+type Assessment struct {
+	Assessor string  `json:"assessor"`
+	Scores   Scores  `json:"scores"`
+	Total    float64 `json:"total"`
+}
+
+// Result is the outcome of a closed session.
+type Result struct {
+	Averages Scores    `json:"averages"` // the average of each attribute over all assessors
+	Total    float64   `json:"total"`    // the total of the averages
+	Score    int       `json:"score"`    // the total rounded to the cupping score of the coffee
+	Closed   time.Time `json:"closed"`
+}
+
+// NewSession opens a cupping session for a coffee.
+func NewSession(id string, coffeeID string, name string) (*Session, error) {
+	if coffeeID == "" {
+		return nil, errors.New("coffee cannot be empty")
+	}
+	s := &Session{}
+	opened := CuppingSessionOpened{ID: id, CoffeeID: coffeeID, Name: strings.TrimSpace(name), OccurredOn: time.Now()}
+	if err := s.apply(opened); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Opened returns the time the session has been opened.
+func (s *Session) Opened() time.Time {
+	return s.opened
+}
+
+// Assessments returns the forms submitted to the session.
+func (s *Session) Assessments() []Assessment {
+	all := make([]Assessment, 0, len(s.assessors))
+	for _, a := range s.assessors {
+		scores := s.assessments[a]
+		all = append(all, Assessment{Assessor: a, Scores: scores, Total: scores.Total()})
+	}
+	return all
+}
+
+// Result returns the outcome of the session, if it has been closed.
+func (s *Session) Result() (Result, bool) {
+	if s.result == nil {
+		return Result{}, false
+	}
+	return *s.result, true
+}
+
+// Submit records the form of an assessor. Assessors can correct their form until the session is closed.
+func (s *Session) Submit(assessor string, scores Scores) error {
+	if s.result != nil {
+		return errors.New("session has been closed")
+	}
+	assessor = strings.TrimSpace(assessor)
+	if assessor == "" {
+		return errors.New("assessor cannot be empty")
+	}
+	if err := scores.Validate(); err != nil {
+		return err
+	}
+	return s.apply(ScoresSubmitted{ID: s.ID, Assessor: assessor, Scores: scores, OccurredOn: time.Now()})
+}
+
+// Close ends the session and averages the forms of all assessors to the final cupping score.
+func (s *Session) Close() (Result, error) {
+	if s.result != nil {
+		return Result{}, errors.New("session has been closed already")
+	}
+	if len(s.assessors) == 0 {
+		return Result{}, errors.New("no scores have been submitted")
+	}
+	all := make([]Scores, 0, len(s.assessors))
+	for _, a := range s.assessors {
+		all = append(all, s.assessments[a])
+	}
+	averages := average(all)
+	total := averages.Total()
+	score := int(math.Round(total))
+	if score < minScore || score > maxScore {
+		return Result{}, fmt.Errorf("final score %.2f is outside the cupping score range of %d to %d", total, minScore, maxScore)
+	}
+	closed := CuppingSessionClosed{ID: s.ID, Averages: averages, Total: total, Score: score, OccurredOn: time.Now()}
+	if err := s.apply(closed); err != nil {
+		return Result{}, err
+	}
+	return *s.result, nil
+}
+
+// Events returns all uncommitted events of the session.
+func (s *Session) Events() []event.Event {
+	return s.events
+}
+
+// Clear empties the event cache of the session.
+func (s *Session) Clear() {
+	s.events = []event.Event{}
+}
+
+func (s *Session) apply(e event.Event) error {
+	if s.ID != "" && e.AggregateID() != s.ID {
+		return fmt.Errorf("event does not belong to this aggregate")
+	}
+	switch theEvent := e.(type) {
+	case CuppingSessionOpened:
+		s.ID = theEvent.ID
+		s.CoffeeID = theEvent.CoffeeID
+		s.Name = theEvent.Name
+		s.opened = theEvent.OccurredOn
+		s.assessments = make(map[string]Scores)
+	case ScoresSubmitted:
+		if _, ok := s.assessments[theEvent.Assessor]; !ok {
+			s.assessors = append(s.assessors, theEvent.Assessor)
+		}
+		s.assessments[theEvent.Assessor] = theEvent.Scores
+	case CuppingSessionClosed:
+		s.result = &Result{Averages: theEvent.Averages, Total: theEvent.Total, Score: theEvent.Score, Closed: theEvent.OccurredOn}
+	default:
+		return fmt.Errorf("unknown event type '%T'", theEvent)
+	}
+	s.events = append(s.events, e)
+	return nil
+}
+
+// The CuppingSessionOpened event starts a cupping session of a coffee.
+type CuppingSessionOpened struct {
+	ID         string    `json:"id"`
+	CoffeeID   string    `json:"coffeeID"`
+	Name       string    `json:"name"`
+	OccurredOn time.Time `json:"occurredOn"`
+}
+
+func (e CuppingSessionOpened) AggregateID() string {
+	return e.ID
+}
+
+func (e CuppingSessionOpened) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e CuppingSessionOpened) Type() string {
+	return "CuppingSessionOpened"
+}
+
+// The ScoresSubmitted event records the cupping form of an assessor.
+type ScoresSubmitted struct {
+	ID         string    `json:"id"`
+	Assessor   string    `json:"assessor"`
+	Scores     Scores    `json:"scores"`
+	OccurredOn time.Time `json:"occurredOn"`
+}
+
+func (e ScoresSubmitted) AggregateID() string {
+	return e.ID
+}
+
+func (e ScoresSubmitted) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e ScoresSubmitted) Type() string {
+	return "ScoresSubmitted"
+}
+
+// The CuppingSessionClosed event ends a cupping session with the averaged scores.
+type CuppingSessionClosed struct {
+	ID         string    `json:"id"`
+	Averages   Scores    `json:"averages"`
+	Total      float64   `json:"total"`
+	Score      int       `json:"score"`
+	OccurredOn time.Time `json:"occurredOn"`
+}
+
+func (e CuppingSessionClosed) AggregateID() string {
+	return e.ID
+}
+
+func (e CuppingSessionClosed) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e CuppingSessionClosed) Type() string {
+	return "CuppingSessionClosed"
+}
@@ -0,0 +1,118 @@
+package cupping
+
+import (
+	"coffy/internal/product"
+	"coffy/internal/storage/storagetest"
+	"errors"
+	"testing"
+)
+
+func form(quality float64, defects float64) Scores {
+	return Scores{
+		FragranceAroma: quality, Flavor: quality, Aftertaste: quality, Acidity: quality, Body: quality, Balance: quality, Overall: quality,
+		Uniformity: 10, CleanCup: 10, Sweetness: 10, Defects: defects,
+	}
+}
+
+func TestScoresValidate(t *testing.T) {
+	if err := form(8, 0).Validate(); err != nil {
+		t.Errorf("Expected valid form, got %v", err)
+	}
+	if total := form(8, 2).Total(); total != 84 {
+		t.Errorf("Expected total of 84, got %.2f", total)
+	}
+	invalid := []Scores{form(5.75, 0), form(8.1, 0), form(10.25, 0), form(8, -2), form(8, 3)}
+	uneven := form(8, 0)
+	uneven.Uniformity = 9
+	invalid = append(invalid, uneven)
+	for _, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Expected form %+v to be invalid", s)
+		}
+	}
+}
+
+func TestSessionAveragesAssessors(t *testing.T) {
+	s, err := NewSession("session", "coffee", "Monday cupping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Close(); err == nil {
+		t.Errorf("Expected a session without scores not to be closed")
+	}
+	if err := s.Submit("Alex", form(7, 0)); err != nil {
+		t.Fatal(err)
+	}
+	// assessors can correct their form until the session is closed
+	if err := s.Submit("Alex", form(8, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Submit("Sam", form(8.5, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Assessments()) != 2 {
+		t.Fatalf("Expected one assessment per assessor, got %+v", s.Assessments())
+	}
+	result, err := s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (86 + 87.5) / 2 = 86.75
+	if result.Averages.Flavor != 8.25 || result.Averages.Defects != 1 || result.Total != 86.75 || result.Score != 87 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if err := s.Submit("Kim", form(9, 0)); err == nil {
+		t.Errorf("Expected submissions to a closed session to fail")
+	}
+}
+
+func TestCloseProvidesCuppingScore(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	coffees := product.NewService(&repo)
+	s := NewService(&repo, coffees)
+	c, err := coffees.Create("Espresso", 0.50, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open("unknown", ""); !errors.Is(err, product.ErrorNotFound) {
+		t.Errorf("Expected product.ErrorNotFound, got %v", err)
+	}
+	session, err := s.Open(c.AggregateID, "Monday cupping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Submit(session.ID, "Alex", form(8, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Submit(session.ID, "Sam", form(11, 0)); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("Expected ErrorInvalidProperty, got %v", err)
+	}
+	if _, err := s.Submit(session.ID, "Sam", form(9, 0)); err != nil {
+		t.Fatal(err)
+	}
+	closed, err := s.Close(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, ok := closed.Result(); !ok || result.Score != 90 {
+		t.Errorf("Expected a final score of 90, got %+v", result)
+	}
+	assessed, _ := coffees.Find(c.AggregateID)
+	if assessed.CoffeeValue().Value != 90 {
+		t.Errorf("Expected the coffee to be assessed with 90, got %d", assessed.CoffeeValue().Value)
+	}
+	if _, err := s.Close(session.ID); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("Expected closing twice to fail, got %v", err)
+	}
+
+	if _, err := s.Open(c.AggregateID, "Friday cupping"); err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := s.ListByCoffee(c.AggregateID)
+	if err != nil || len(sessions) != 2 || sessions[0].Name != "Friday cupping" {
+		t.Errorf("Expected both sessions of the coffee, the latest first, got %+v (%v)", sessions, err)
+	}
+	if _, err := s.Find("unknown"); !errors.Is(err, ErrorNotFound) {
+		t.Errorf("Expected ErrorNotFound, got %v", err)
+	}
+}
@@ -2,11 +2,14 @@ package equipment
 
 import (
 	"coffy/internal/event"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"time"
 )
 
+var ErrorNoCoffeeLoaded = errors.New("no coffee loaded")
+
 type Machine struct {
 	AggregateID string        // machines unique ID in Coffy
 	Brand       string        // brand of the machine, e.g. Phillips
@@ -40,9 +43,10 @@ func (m *Machine) Load(coffeeID string) error {
 	return nil
 }
 
+// Coffee returns the ID of the currently loaded coffee, ErrorNoCoffeeLoaded if the machine is empty.
 func (m *Machine) Coffee() (string, error) {
 	if len(m.coffee) == 0 {
-		return "", fmt.Errorf("no coffee loaded")
+		return "", ErrorNoCoffeeLoaded
 	}
 	return m.coffee, nil
 }
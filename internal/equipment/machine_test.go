@@ -1,6 +1,9 @@
 package equipment
 
 import (
+	"coffy/internal/product"
+	"coffy/internal/storage/storagetest"
+	"errors"
 	"github.com/google/uuid"
 	"testing"
 )
@@ -58,3 +61,20 @@ func TestCoffeeLoaded(t *testing.T) {
 		t.Errorf("Mismatching coffee ref. Expected '%s' but was '%s'", coffeeRef, c)
 	}
 }
+
+func TestLoadDiscontinuedCoffee(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	coffees := product.NewService(&repo)
+	s := NewService(&repo, coffees)
+	m, _ := s.Create("Philips", "EP2334")
+	c, _ := coffees.Create("Espresso", 0.50, nil, nil)
+	if _, err := coffees.Discontinue(c.AggregateID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.LoadCoffee(m.AggregateID, c.AggregateID); !errors.Is(err, product.ErrorDiscontinued) {
+		t.Errorf("Expected ErrorDiscontinued, got %v", err)
+	}
+	if _, err := s.LoadCoffee(m.AggregateID, "unknown"); !errors.Is(err, product.ErrorNotFound) {
+		t.Errorf("Expected product.ErrorNotFound, got %v", err)
+	}
+}
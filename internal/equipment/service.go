@@ -2,6 +2,7 @@ package equipment
 
 import (
 	"coffy/internal/event"
+	"coffy/internal/product"
 	"coffy/internal/storage"
 	"encoding/json"
 	"errors"
@@ -9,11 +10,16 @@ import (
 	"log"
 )
 
+var ErrorNotFound = errors.New("machine not found")
+
 type Service struct {
-	repo storage.EventRepository
+	repo    storage.EventRepository
+	coffees *product.Service
 }
 
-func NewService(repo *storage.EventRepository) *Service { return &Service{*repo} }
+func NewService(repo *storage.EventRepository, coffees *product.Service) *Service {
+	return &Service{repo: *repo, coffees: coffees}
+}
 
 func (s *Service) ListAll() ([]Machine, error) {
 	query, err := s.repo.FetchByEventType("MachineCreated")
@@ -39,7 +45,7 @@ func (s *Service) FindById(machineId string) (*Machine, error) {
 	}
 
 	if len(entries) == 0 {
-		return nil, fmt.Errorf(errorMsg, machineId)
+		return nil, fmt.Errorf("%w: '%s'", ErrorNotFound, machineId)
 	}
 
 	events := make([]event.Event, 0)
@@ -83,11 +89,19 @@ func (s *Service) Create(brand string, model string) (*Machine, error) {
 
 }
 
+// LoadCoffee loads a coffee into a machine. Discontinued coffees cannot be loaded.
 func (s *Service) LoadCoffee(machineId string, coffeeId string) (*Machine, error) {
 	m, err := s.FindById(machineId)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load machine '%s': %w", coffeeId, err)
 	}
+	coffee, err := s.coffees.Find(coffeeId)
+	if err != nil {
+		return nil, err
+	}
+	if coffee.Discontinued() {
+		return nil, fmt.Errorf("%w: '%s'", product.ErrorDiscontinued, coffee.Type)
+	}
 	err = m.Load(coffeeId)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load coffee '%s': %w", coffeeId, err)
@@ -0,0 +1,144 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Bag is a bag of beans purchased for a coffee.
+type Bag struct {
+	ID        string     `json:"id"`
+	CoffeeID  string     `json:"coffee_id"`
+	Weight    float64    `json:"weight"` // grams of beans
+	Cost      float64    `json:"cost"`
+	Purchased time.Time  `json:"purchased"`
+	Roasted   *time.Time `json:"roasted,omitempty"` // the roast date printed on the bag, if known
+}
+
+func (b Bag) validate(now time.Time) error {
+	if b.Weight <= 0 {
+		return errors.New("weight must be greater than zero")
+	}
+	if b.Cost < 0 {
+		return errors.New("cost cannot be negative")
+	}
+	if b.Purchased.After(now) {
+		return errors.New("purchase date lies in the future")
+	}
+	if b.Roasted != nil && b.Roasted.After(b.Purchased) {
+		return errors.New("roast date lies after the purchase date")
+	}
+	return nil
+}
+
+// Stock is the amount of beans left of a coffee and how long they are expected to last.
+type Stock struct {
+	CoffeeID      string   `json:"coffee_id"`
+	Coffee        string   `json:"coffee"`
+	Purchased     float64  `json:"purchased"`                // grams of all bags purchased
+	Consumed      float64  `json:"consumed"`                 // grams consumed since the first bag has been purchased
+	Remaining     float64  `json:"remaining"`                // grams left
+	DailyUsage    float64  `json:"daily_usage"`              // grams per day, averaged over the recent days
+	DaysRemaining *float64 `json:"days_remaining,omitempty"` // the estimated days the beans last, unknown without recent consumptions
+	Low           bool     `json:"low"`                      // the beans are gone or last fewer days than configured
+}
+
+// usage is the amount of beans consumed of a coffee at a point in time.
+type usage struct {
+	coffeeID string
+	grams    float64
+	time     time.Time
+}
+
+// stockOf calculates the stock of a coffee from its bags and the usage of all coffees. Beans consumed
+// before the first bag has been purchased are not considered, as they have not been tracked.
+func stockOf(coffeeID string, coffee string, bags []Bag, used []usage, now time.Time, rate time.Duration, lowDays float64) Stock {
+	stock := Stock{CoffeeID: coffeeID, Coffee: coffee}
+	if len(bags) == 0 {
+		return stock
+	}
+	start := bags[0].Purchased
+	for _, b := range bags {
+		stock.Purchased += b.Weight
+		if b.Purchased.Before(start) {
+			start = b.Purchased
+		}
+	}
+	recent := 0.0
+	for _, u := range used {
+		if u.coffeeID != coffeeID || u.time.Before(start) || u.time.After(now) {
+			continue
+		}
+		stock.Consumed += u.grams
+		if u.time.After(now.Add(-rate)) {
+			recent += u.grams
+		}
+	}
+	// a stock tracked for a shorter time than the rate period is averaged over the tracked days, at least one
+	window := rate
+	if tracked := now.Sub(start); tracked < window {
+		window = tracked
+	}
+	stock.DailyUsage = round(recent/math.Max(window.Hours()/24, 1), 1)
+	stock.Remaining = math.Max(stock.Purchased-stock.Consumed, 0)
+	if stock.DailyUsage > 0 {
+		days := round(stock.Remaining/stock.DailyUsage, 1)
+		stock.DaysRemaining = &days
+	}
+	stock.Low = stock.Remaining == 0 || (stock.DaysRemaining != nil && *stock.DaysRemaining < lowDays)
+	return stock
+}
+
+func round(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}
+
+func aggregateID(coffeeID string) string {
+	return fmt.Sprintf("inventory:%s", coffeeID)
+}
+
+// The BagPurchased event records a bag of beans added to the stock of a coffee.
+type BagPurchased struct {
+	ID         string     `json:"id"`
+	OccurredOn time.Time  `json:"occurredOn"`
+	EventType  string     `json:"eventType"`
+	BagID      string     `json:"bagID"`
+	CoffeeID   string     `json:"coffeeID"`
+	Weight     float64    `json:"weight"`
+	Cost       float64    `json:"cost"`
+	Purchased  time.Time  `json:"purchased"`
+	Roasted    *time.Time `json:"roasted,omitempty"`
+}
+
+func newBagPurchased(b Bag) BagPurchased {
+	return BagPurchased{
+		ID:         aggregateID(b.CoffeeID),
+		OccurredOn: time.Now(),
+		EventType:  "BagPurchased",
+		BagID:      b.ID,
+		CoffeeID:   b.CoffeeID,
+		Weight:     b.Weight,
+		Cost:       b.Cost,
+		Purchased:  b.Purchased,
+		Roasted:    b.Roasted,
+	}
+}
+
+func (e BagPurchased) bag() Bag {
+	return Bag{ID: e.BagID, CoffeeID: e.CoffeeID, Weight: e.Weight, Cost: e.Cost, Purchased: e.Purchased, Roasted: e.Roasted}
+}
+
+func (e BagPurchased) AggregateID() string {
+	return e.ID
+}
+
+func (e BagPurchased) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e BagPurchased) Type() string {
+	return e.EventType
+}
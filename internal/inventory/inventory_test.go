@@ -0,0 +1,112 @@
+package inventory
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/beverage"
+	"coffy/internal/cashbox"
+	"coffy/internal/coffy"
+	"coffy/internal/consume"
+	"coffy/internal/equipment"
+	"coffy/internal/pricing"
+	"coffy/internal/product"
+	"coffy/internal/quota"
+	"coffy/internal/storage/storagetest"
+	"coffy/internal/subscription"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStockOf(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	bags := []Bag{{Weight: 1000, Purchased: now.AddDate(0, 0, -30)}, {Weight: 500, Purchased: now.AddDate(0, 0, -5)}}
+	used := []usage{
+		{coffeeID: "c", grams: 80, time: now.AddDate(0, 0, -40)}, // before the first bag
+		{coffeeID: "c", grams: 500, time: now.AddDate(0, 0, -20)},
+		{coffeeID: "c", grams: 560, time: now.AddDate(0, 0, -7)},
+		{coffeeID: "other", grams: 100, time: now.AddDate(0, 0, -1)},
+	}
+	stock := stockOf("c", "Espresso", bags, used, now, 14*24*time.Hour, 7)
+	if stock.Purchased != 1500 || stock.Consumed != 1060 || stock.Remaining != 440 {
+		t.Errorf("Unexpected stock %+v", stock)
+	}
+	if stock.DailyUsage != 40 || stock.DaysRemaining == nil || *stock.DaysRemaining != 11 || stock.Low {
+		t.Errorf("Expected 40 grams per day lasting 11 days, got %+v", stock)
+	}
+	if stock := stockOf("c", "Espresso", bags, used, now, 14*24*time.Hour, 12); !stock.Low {
+		t.Errorf("Expected stock lasting fewer than 12 days to be low, got %+v", stock)
+	}
+	idle := stockOf("c", "Espresso", bags, used[:2], now, 14*24*time.Hour, 7)
+	if idle.DaysRemaining != nil || idle.Low {
+		t.Errorf("Expected unknown days remaining without recent consumptions, got %+v", idle)
+	}
+}
+
+func TestStockDepletedByConsumptions(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	coffees := product.NewService(&repo)
+	beverages := beverage.NewService(&repo, coffees)
+	receipts := consume.NewService(&repo, accounting, coffees, beverages, equipment.NewService(&repo, coffees), pricing.NewEngine(), subscription.NewService(&repo, accounting, &coffy.BillingCfg{Day: 1, Hour: 6}), quota.NewLimiter(), cashbox.NewService(&repo))
+	s := NewService(&repo, coffees, receipts, &coffy.InventoryCfg{GramsPerCup: 8, RateDays: 14, LowStockDays: 7})
+
+	a, _ := accounting.Create("Coffy", "")
+	espresso, _ := coffees.Create("Espresso", 0.50, nil, nil)
+	double, _ := beverages.Create("Double espresso", espresso.AggregateID, beverage.Recipe{Beans: 16}, beverage.Pricing{Factor: 2})
+	if _, err := s.Stock(espresso.AggregateID, time.Now()); !errors.Is(err, ErrorNotTracked) {
+		t.Errorf("Expected ErrorNotTracked, got %v", err)
+	}
+	roasted := time.Now().AddDate(0, 0, -10)
+	if _, err := s.Purchase(espresso.AggregateID, 250, 7.90, time.Now().Add(-time.Minute), &roasted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := receipts.Consume(consume.Order{AccountID: a.ID(), CoffeeID: espresso.AggregateID, Quantity: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := receipts.Consume(consume.Order{CoffeeID: espresso.AggregateID, BeverageID: double.ID, Quantity: 1, Payment: consume.PaymentCash}); err != nil {
+		t.Fatal(err)
+	}
+
+	stock, err := s.Stock(espresso.AggregateID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stock.Consumed != 32 || stock.Remaining != 218 || stock.DailyUsage != 32 {
+		t.Errorf("Expected 2 plain cups and a double espresso to use 32 grams, got %+v", stock)
+	}
+	if stock.DaysRemaining == nil || *stock.DaysRemaining != 6.8 || !stock.Low {
+		t.Errorf("Expected stock lasting 6.8 days to be low, got %+v", stock)
+	}
+	if low, err := s.LowStock(time.Now()); err != nil || len(low) != 1 {
+		t.Errorf("Expected one low stock, got %+v (%v)", low, err)
+	}
+}
+
+func TestPurchaseValidates(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	coffees := product.NewService(&repo)
+	s := NewService(&repo, coffees, nil, &coffy.InventoryCfg{GramsPerCup: 8, RateDays: 14, LowStockDays: 7})
+	espresso, _ := coffees.Create("Espresso", 0.50, nil, nil)
+	roasted := time.Now()
+	for _, tt := range []struct {
+		weight    float64
+		cost      float64
+		purchased time.Time
+		roasted   *time.Time
+	}{
+		{0, 5, time.Time{}, nil},
+		{250, -1, time.Time{}, nil},
+		{250, 5, time.Now().Add(time.Hour), nil},
+		{250, 5, time.Now().AddDate(0, 0, -1), &roasted},
+	} {
+		if _, err := s.Purchase(espresso.AggregateID, tt.weight, tt.cost, tt.purchased, tt.roasted); !errors.Is(err, ErrorInvalidProperty) {
+			t.Errorf("Expected ErrorInvalidProperty for %+v, got %v", tt, err)
+		}
+	}
+	if _, err := s.Purchase("unknown", 250, 5, time.Time{}, nil); !errors.Is(err, product.ErrorNotFound) {
+		t.Errorf("Expected product.ErrorNotFound, got %v", err)
+	}
+	if bags, err := s.Bags(espresso.AggregateID); err != nil || len(bags) != 0 {
+		t.Errorf("Expected no bags, got %+v (%v)", bags, err)
+	}
+}
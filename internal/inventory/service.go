@@ -0,0 +1,229 @@
+package inventory
+
+import (
+	"coffy/internal/coffy"
+	"coffy/internal/consume"
+	"coffy/internal/product"
+	"coffy/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrorInvalidProperty = errors.New("invalid property")
+	ErrorNotTracked      = errors.New("no bags have been purchased for the coffee")
+)
+
+type Service struct {
+	repo        storage.EventRepository
+	coffees     *product.Service
+	receipts    *consume.Service
+	gramsPerCup float64
+	rate        time.Duration
+	lowDays     float64
+}
+
+func NewService(repo *storage.EventRepository, coffees *product.Service, receipts *consume.Service, cfg *coffy.InventoryCfg) *Service {
+	return &Service{
+		repo:        *repo,
+		coffees:     coffees,
+		receipts:    receipts,
+		gramsPerCup: cfg.GramsPerCup,
+		rate:        time.Duration(cfg.RateDays) * 24 * time.Hour,
+		lowDays:     float64(cfg.LowStockDays),
+	}
+}
+
+// Purchase adds a bag of beans to the stock of a coffee. A zero purchase date means now.
+func (s *Service) Purchase(coffeeID string, weight float64, cost float64, purchased time.Time, roasted *time.Time) (*Bag, error) {
+	c, err := s.coffees.Find(coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if purchased.IsZero() {
+		purchased = now
+	}
+	b := Bag{ID: uuid.NewString(), CoffeeID: c.AggregateID, Weight: weight, Cost: cost, Purchased: purchased, Roasted: roasted}
+	if err := b.validate(now); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	data, err := json.Marshal(newBagPurchased(b))
+	if err != nil {
+		return nil, err
+	}
+	entry := storage.EventEntry{AggregateID: aggregateID(b.CoffeeID), EventType: "BagPurchased", Date: now, EventData: data}
+	if err := s.repo.SaveAll([]storage.EventEntry{entry}); err != nil {
+		return nil, fmt.Errorf("failed to save bag: %w", err)
+	}
+	return &b, nil
+}
+
+// Bags returns the bags purchased for a coffee, ordered by purchase date.
+func (s *Service) Bags(coffeeID string) ([]Bag, error) {
+	if _, err := s.coffees.Find(coffeeID); err != nil {
+		return nil, err
+	}
+	all, err := s.bags()
+	if err != nil {
+		return nil, err
+	}
+	bags := all[coffeeID]
+	if bags == nil {
+		bags = []Bag{}
+	}
+	return bags, nil
+}
+
+// Stock returns the stock of a coffee at the given time.
+func (s *Service) Stock(coffeeID string, now time.Time) (*Stock, error) {
+	c, err := s.coffees.Find(coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	all, err := s.bags()
+	if err != nil {
+		return nil, err
+	}
+	bags, ok := all[coffeeID]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrorNotTracked, c.Type)
+	}
+	used, err := s.usage(bags[0].Purchased)
+	if err != nil {
+		return nil, err
+	}
+	stock := stockOf(c.AggregateID, c.Type, bags, used, now, s.rate, s.lowDays)
+	return &stock, nil
+}
+
+// Stocks returns the stock of all coffees bags have been purchased for at the given time, the lowest
+// number of days remaining first. Coffees without recent consumptions are listed last.
+func (s *Service) Stocks(now time.Time) ([]Stock, error) {
+	all, err := s.bags()
+	if err != nil {
+		return nil, err
+	}
+	coffees, err := s.coffees.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	start := now
+	for _, bags := range all {
+		if bags[0].Purchased.Before(start) {
+			start = bags[0].Purchased
+		}
+	}
+	used, err := s.usage(start)
+	if err != nil {
+		return nil, err
+	}
+	stocks := make([]Stock, 0, len(all))
+	for _, c := range coffees {
+		if bags, ok := all[c.AggregateID]; ok {
+			stocks = append(stocks, stockOf(c.AggregateID, c.Type, bags, used, now, s.rate, s.lowDays))
+		}
+	}
+	slices.SortStableFunc(stocks, func(a Stock, b Stock) int {
+		switch {
+		case a.DaysRemaining == nil && b.DaysRemaining == nil:
+			return strings.Compare(a.Coffee, b.Coffee)
+		case a.DaysRemaining == nil:
+			return 1
+		case b.DaysRemaining == nil:
+			return -1
+		case *a.DaysRemaining != *b.DaysRemaining:
+			if *a.DaysRemaining < *b.DaysRemaining {
+				return -1
+			}
+			return 1
+		default:
+			return strings.Compare(a.Coffee, b.Coffee)
+		}
+	})
+	return stocks, nil
+}
+
+// LowStock returns the stocks that are gone or last fewer days than configured.
+func (s *Service) LowStock(now time.Time) ([]Stock, error) {
+	stocks, err := s.Stocks(now)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(stocks, func(stock Stock) bool { return !stock.Low }), nil
+}
+
+// CostPerGram returns the average costs of a gram of beans by coffee ID, weighted over the bags
+// purchased before the given time. Coffees without bags are omitted.
+func (s *Service) CostPerGram(until time.Time) (map[string]float64, error) {
+	all, err := s.bags()
+	if err != nil {
+		return nil, err
+	}
+	costs := make(map[string]float64, len(all))
+	for coffeeID, bags := range all {
+		weight, cost := 0.0, 0.0
+		for _, b := range bags {
+			if b.Purchased.Before(until) {
+				weight += b.Weight
+				cost += b.Cost
+			}
+		}
+		if weight > 0 {
+			costs[coffeeID] = cost / weight
+		}
+	}
+	return costs, nil
+}
+
+// GramsPerCup returns the beans used for a cup of plain coffee.
+func (s *Service) GramsPerCup() float64 {
+	return s.gramsPerCup
+}
+
+// Grams returns the beans used for the cups of a receipt. Beverages use the beans of their recipe,
+// plain coffee the configured grams per cup.
+func (s *Service) Grams(r consume.ReceiptIssued) float64 {
+	if r.Beans > 0 {
+		return r.Beans * float64(r.Quantity)
+	}
+	return s.gramsPerCup * float64(r.Quantity)
+}
+
+// bags returns the bags of all coffees by coffee ID, ordered by purchase date.
+func (s *Service) bags() (map[string][]Bag, error) {
+	entries, err := s.repo.FetchByEventType("BagPurchased")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bags: %w", err)
+	}
+	bags := make(map[string][]Bag)
+	for _, entry := range entries {
+		e := BagPurchased{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as BagPurchased: %w", err)
+		}
+		bags[e.CoffeeID] = append(bags[e.CoffeeID], e.bag())
+	}
+	for _, b := range bags {
+		slices.SortStableFunc(b, func(x Bag, y Bag) int { return x.Purchased.Compare(y.Purchased) })
+	}
+	return bags, nil
+}
+
+// usage returns the beans consumed since the given time.
+func (s *Service) usage(since time.Time) ([]usage, error) {
+	issued, err := s.receipts.Issued(since)
+	if err != nil {
+		return nil, err
+	}
+	used := make([]usage, 0, len(issued))
+	for _, r := range issued {
+		used = append(used, usage{coffeeID: r.CoffeeID, grams: s.Grams(r), time: r.OccurredOn})
+	}
+	return used, nil
+}
@@ -0,0 +1,161 @@
+package kiosk
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/beverage"
+	"coffy/internal/consume"
+	"coffy/internal/equipment"
+	"coffy/internal/product"
+	"coffy/internal/quota"
+	"coffy/internal/storage"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status is the outcome of a single Command.
+type Status string
+
+const (
+	StatusApplied   Status = "applied"   // the command has been applied
+	StatusDuplicate Status = "duplicate" // the command has been applied by an earlier sync
+	StatusConflict  Status = "conflict"  // the command cannot be applied, e.g. because the coffee is unknown
+)
+
+// A Command is a consumption that has been queued by an offline client.
+type Command struct {
+	ID         string    `json:"id"` // the client-generated, unique ID of the command
+	AccountID  string    `json:"account_id"`
+	CoffeeID   string    `json:"coffee_id"`
+	BeverageID string    `json:"beverage_id,omitempty"`
+	MachineID  string    `json:"machine_id,omitempty"`
+	Quantity   int       `json:"quantity"`
+	Time       time.Time `json:"timestamp"` // the time the command was issued on the client
+}
+
+// Result reports the outcome of a Command.
+type Result struct {
+	ID        string `json:"id"`
+	Status    Status `json:"status"`
+	ReceiptID string `json:"receipt_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Delta holds the state that has changed since a checkpoint.
+type Delta struct {
+	Accounts   []account.Account // the accounts that have been created or changed
+	Removed    []string          // the IDs of accounts that have been merged into other accounts
+	Coffees    []product.Coffee  // the coffees that have been created or changed
+	Checkpoint int               // the checkpoint to request the next delta from
+}
+
+type Service struct {
+	repo       storage.EventRepository
+	accounting *account.Accounting
+	coffees    *product.Service
+	consume    *consume.Service
+}
+
+func NewService(repo *storage.EventRepository, accounting *account.Accounting, coffees *product.Service, consume *consume.Service) *Service {
+	return &Service{repo: *repo, accounting: accounting, coffees: coffees, consume: consume}
+}
+
+// Apply applies the commands in the given order. Every command is applied once, commands that have
+// been applied by an earlier sync are reported as duplicates with their original receipt.
+//
+// Commands that cannot be applied are reported as conflicts and do not stop the other commands.
+func (s *Service) Apply(commands []Command) ([]Result, error) {
+	results := make([]Result, 0, len(commands))
+	for _, c := range commands {
+		order := consume.Order{AccountID: c.AccountID, CoffeeID: c.CoffeeID, BeverageID: c.BeverageID, MachineID: c.MachineID, Quantity: c.Quantity, Time: c.Time}
+		receipt, replayed, err := s.consume.ConsumeCommand(c.ID, order)
+		result := Result{ID: c.ID}
+		reason, conflicting := conflict(err)
+		switch {
+		case err == nil && replayed:
+			result.Status = StatusDuplicate
+			result.ReceiptID = receipt.ID
+		case err == nil:
+			result.Status = StatusApplied
+			result.ReceiptID = receipt.ID
+		case conflicting:
+			result.Status = StatusConflict
+			result.Error = reason.Error()
+		default:
+			return nil, fmt.Errorf("failed to apply command '%s': %w", c.ID, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// conflict returns the reason why a command cannot be applied, if the error is caused by the command.
+func conflict(err error) (error, bool) {
+	if err == nil {
+		return nil, false
+	}
+	for _, reason := range []error{
+		consume.ErrorAccountNotFound,
+		consume.ErrorProductNotFound,
+		consume.ErrorMachineNotFound,
+		consume.ErrorInvalidQuantity,
+		consume.ErrorInvalidIdempotencyKey,
+		consume.ErrorIdempotencyKeyReused,
+		equipment.ErrorNoCoffeeLoaded,
+		product.ErrorDiscontinued,
+		beverage.ErrorNotFound,
+		beverage.ErrorRemoved,
+	} {
+		if errors.Is(err, reason) {
+			return reason, true
+		}
+	}
+	// the quota error names the exceeded rule
+	if errors.Is(err, quota.ErrorQuotaExceeded) {
+		return err, true
+	}
+	return nil, false
+}
+
+// Changes returns the accounts and coffees that have changed since the checkpoint.
+// The checkpoint zero returns the complete state.
+func (s *Service) Changes(checkpoint int) (*Delta, error) {
+	entries, err := s.repo.FetchSince(checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load changes: %w", err)
+	}
+	delta := &Delta{Accounts: []account.Account{}, Removed: []string{}, Coffees: []product.Coffee{}, Checkpoint: checkpoint}
+	if len(entries) == 0 {
+		return delta, nil
+	}
+	delta.Checkpoint = entries[len(entries)-1].ID
+	changed := make(map[string]bool)
+	for _, e := range entries {
+		changed[e.AggregateID] = true
+	}
+
+	accounts, err := s.accounting.Search(account.Query{Status: account.StatusAll})
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		if !changed[a.ID()] {
+			continue
+		}
+		if _, merged := a.MergedInto(); merged {
+			delta.Removed = append(delta.Removed, a.ID())
+			continue
+		}
+		delta.Accounts = append(delta.Accounts, a)
+	}
+	coffees, err := s.coffees.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range coffees {
+		if changed[c.AggregateID] {
+			delta.Coffees = append(delta.Coffees, c)
+		}
+	}
+	return delta, nil
+}
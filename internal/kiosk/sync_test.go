@@ -0,0 +1,83 @@
+package kiosk
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/beverage"
+	"coffy/internal/cashbox"
+	"coffy/internal/coffy"
+	"coffy/internal/consume"
+	"coffy/internal/equipment"
+	"coffy/internal/pricing"
+	"coffy/internal/product"
+	"coffy/internal/quota"
+	"coffy/internal/storage/storagetest"
+	"coffy/internal/subscription"
+	"testing"
+	"time"
+)
+
+func TestSync(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	coffees := product.NewService(&repo)
+	consumeService := consume.NewService(&repo, accounting, coffees, beverage.NewService(&repo, coffees), equipment.NewService(&repo, coffees), pricing.NewEngine(), subscription.NewService(&repo, accounting, &coffy.BillingCfg{Day: 1, Hour: 6}), quota.NewLimiter(), cashbox.NewService(&repo))
+	s := NewService(&repo, accounting, coffees, consumeService)
+
+	a, _ := accounting.Create("Coffy", "")
+	other, _ := accounting.Create("Coffy Again", "")
+	p, _ := coffees.Create("Espresso", 0.50, nil, nil)
+
+	delta, err := s.Changes(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delta.Accounts) != 2 || len(delta.Coffees) != 1 {
+		t.Errorf("First sync should return the complete state, got %d accounts and %d coffees", len(delta.Accounts), len(delta.Coffees))
+	}
+	checkpoint := delta.Checkpoint
+
+	issued := time.Now().Add(-time.Hour)
+	commands := []Command{
+		{ID: "c1", AccountID: a.ID(), CoffeeID: p.AggregateID, Quantity: 1, Time: issued},
+		{ID: "c2", AccountID: a.ID(), CoffeeID: "discontinued", Quantity: 1, Time: issued},
+	}
+	results, err := s.Apply(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != StatusApplied || results[1].Status != StatusConflict {
+		t.Errorf("Unexpected results %+v", results)
+	}
+	results, _ = s.Apply(commands[:1])
+	if results[0].Status != StatusDuplicate || results[0].ReceiptID == "" {
+		t.Errorf("Repeated command should be a duplicate, got %+v", results[0])
+	}
+	if acc, _ := accounting.Find(a.ID()); acc.Balance() != -0.50 {
+		t.Errorf("Command should be charged once, got balance %.2f", acc.Balance())
+	}
+
+	if _, err := accounting.Merge(other.ID(), a.ID()); err != nil {
+		t.Fatal(err)
+	}
+	delta, _ = s.Changes(checkpoint)
+	if len(delta.Accounts) != 1 || delta.Accounts[0].ID() != a.ID() || len(delta.Coffees) != 0 {
+		t.Errorf("Delta should contain the changed account only, got %+v", delta)
+	}
+	if len(delta.Removed) != 1 || delta.Removed[0] != other.ID() {
+		t.Errorf("Delta should remove the merged account, got %v", delta.Removed)
+	}
+	if next, _ := s.Changes(delta.Checkpoint); len(next.Accounts) != 0 || next.Checkpoint != delta.Checkpoint {
+		t.Errorf("No changes expected after the latest checkpoint, got %+v", next)
+	}
+
+	if _, err := coffees.Discontinue(p.AggregateID); err != nil {
+		t.Fatal(err)
+	}
+	results, _ = s.Apply([]Command{{ID: "c3", AccountID: a.ID(), CoffeeID: p.AggregateID, Quantity: 1, Time: time.Now()}})
+	if results[0].Status != StatusConflict || results[0].Error != product.ErrorDiscontinued.Error() {
+		t.Errorf("Command for a discontinued coffee should conflict, got %+v", results[0])
+	}
+	if next, _ := s.Changes(delta.Checkpoint); len(next.Coffees) != 1 || !next.Coffees[0].Discontinued() {
+		t.Errorf("Delta should contain the discontinued coffee, got %+v", next.Coffees)
+	}
+}
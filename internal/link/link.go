@@ -0,0 +1,99 @@
+package link
+
+import (
+	"coffy/internal/coffy"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrorInvalidToken = errors.New("invalid link token")
+	ErrorTokenExpired = errors.New("link token has expired")
+)
+
+// Kind is the target of a link, it is the first segment of the link path.
+type Kind string
+
+const (
+	KindMachine Kind = "m" // consume the coffee loaded in a machine
+	KindAccount Kind = "a" // the personal page of an account
+)
+
+// macSize is the number of bytes of the signature kept in a token, truncated to keep QR codes small.
+const macSize = 16
+
+// Token is the verified content of a signed link.
+type Token struct {
+	Kind    Kind
+	ID      string    // the ID of the machine or account
+	Expires time.Time // the time the token is no longer accepted
+}
+
+// Links signs and verifies the tokens embedded in the QR codes of machines and accounts.
+type Links struct {
+	secret  []byte
+	baseURL string
+	ttl     time.Duration
+}
+
+// NewLinks creates links on the base URL, signed with the secret, that are valid for the given duration.
+func NewLinks(secret []byte, baseURL string, ttl time.Duration) *Links {
+	return &Links{secret: secret, baseURL: strings.TrimSuffix(baseURL, "/"), ttl: ttl}
+}
+
+// FromConfig creates links from the validated configuration.
+func FromConfig(cfg *coffy.LinksCfg) *Links {
+	return NewLinks([]byte(cfg.Secret), cfg.BaseURL, time.Duration(cfg.TTLDays)*24*time.Hour)
+}
+
+// Sign creates a token for the machine or account that expires after the configured duration.
+func (l *Links) Sign(kind Kind, id string, now time.Time) string {
+	payload := fmt.Sprintf("%s:%s:%d", kind, id, now.Add(l.ttl).Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(l.mac(payload))
+}
+
+// Verify checks the signature and expiry of a token of the given kind.
+func (l *Links) Verify(kind Kind, token string, now time.Time) (Token, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return Token{}, ErrorInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Token{}, ErrorInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, l.mac(string(payload))) {
+		return Token{}, ErrorInvalidToken
+	}
+	parts := strings.Split(string(payload), ":")
+	if len(parts) != 3 || Kind(parts[0]) != kind {
+		return Token{}, ErrorInvalidToken
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return Token{}, ErrorInvalidToken
+	}
+	t := Token{Kind: kind, ID: parts[1], Expires: time.Unix(expires, 0)}
+	if !now.Before(t.Expires) {
+		return t, ErrorTokenExpired
+	}
+	return t, nil
+}
+
+// URL returns the signed link of a machine or account on the configured base URL.
+func (l *Links) URL(kind Kind, id string, now time.Time) string {
+	return fmt.Sprintf("%s/%s/%s", l.baseURL, kind, l.Sign(kind, id, now))
+}
+
+func (l *Links) mac(payload string) []byte {
+	h := hmac.New(sha256.New, l.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)[:macSize]
+}
@@ -0,0 +1,60 @@
+package link
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	links := NewLinks([]byte("a-secret-of-at-least-16-characters"), "https://coffy.example.com", 24*time.Hour)
+	now := time.Now()
+	token := links.Sign(KindMachine, "machine-1", now)
+
+	verified, err := links.Verify(KindMachine, token, now.Add(time.Hour))
+	if err != nil || verified.ID != "machine-1" || verified.Kind != KindMachine {
+		t.Fatalf("Expected valid token of machine-1, got %+v (%v)", verified, err)
+	}
+	if _, err := links.Verify(KindMachine, token, now.Add(25*time.Hour)); !errors.Is(err, ErrorTokenExpired) {
+		t.Errorf("Expected ErrorTokenExpired, got %v", err)
+	}
+	if _, err := links.Verify(KindAccount, token, now); !errors.Is(err, ErrorInvalidToken) {
+		t.Errorf("Expected machine token to be rejected as account token, got %v", err)
+	}
+	other := NewLinks([]byte("another-secret-of-16-characters"), "https://coffy.example.com", 24*time.Hour)
+	if _, err := other.Verify(KindMachine, token, now); !errors.Is(err, ErrorInvalidToken) {
+		t.Errorf("Expected token signed with another secret to be rejected, got %v", err)
+	}
+	forged := links.Sign(KindMachine, "machine-2", now)
+	encoded, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+	if _, err := links.Verify(KindMachine, encoded+"."+signature, now); !errors.Is(err, ErrorInvalidToken) {
+		t.Errorf("Expected token with a foreign signature to be rejected, got %v", err)
+	}
+}
+
+func TestURL(t *testing.T) {
+	now := time.Now()
+	configured := NewLinks([]byte("a-secret-of-at-least-16-characters"), "https://coffy.example.com/", time.Hour)
+	url := configured.URL(KindAccount, "account-1", now)
+	if !strings.HasPrefix(url, "https://coffy.example.com/a/") {
+		t.Errorf("Expected link on the configured base URL, got '%s'", url)
+	}
+	token := strings.TrimPrefix(url, "https://coffy.example.com/a/")
+	if _, err := configured.Verify(KindAccount, token, now); err != nil {
+		t.Errorf("Expected token of the link to be valid, got %v", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	png, err := PNG("https://coffy.example.com/m/token", 256)
+	if err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Errorf("Expected PNG image, got %v", err)
+	}
+	svg, err := SVG("https://coffy.example.com/m/token")
+	if err != nil || !bytes.HasPrefix(svg, []byte("<svg")) || !bytes.Contains(svg, []byte("h1v1h-1z")) {
+		t.Errorf("Expected SVG image, got %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package link
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+)
+
+// PNG renders the content as QR code image with the given width and height in pixels.
+func PNG(content string, size int) ([]byte, error) {
+	png, err := qrcode.Encode(content, qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render QR code: %w", err)
+	}
+	return png, nil
+}
+
+// SVG renders the content as scalable QR code, one unit per module including the quiet zone.
+func SVG(content string) ([]byte, error) {
+	q, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render QR code: %w", err)
+	}
+	bitmap := q.Bitmap()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, len(bitmap), len(bitmap))
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#fff"/><path fill="#000" d="`)
+	for y, row := range bitmap {
+		for x, black := range row {
+			if black {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.Bytes(), nil
+}
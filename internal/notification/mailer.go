@@ -0,0 +1,52 @@
+package notification
+
+import (
+	"coffy/internal/coffy"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// A Mailer delivers a plain text message to a recipient.
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// SmtpMailer delivers messages via an SMTP server, e.g. the company's mail relay or a
+// local SMTP sink for testing.
+type SmtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSmtpMailer(cfg *coffy.SmtpCfg) *SmtpMailer {
+	m := &SmtpMailer{addr: fmt.Sprintf("%s:%d", cfg.Host, cfg.Port), from: cfg.From}
+	// Authentication is optional, since local SMTP sinks usually don't require it
+	if cfg.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return m
+}
+
+func (m *SmtpMailer) Send(to string, subject string, body string) error {
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{to}, message(m.from, to, subject, body)); err != nil {
+		return fmt.Errorf("failed to send mail to '%s': %w", to, err)
+	}
+	return nil
+}
+
+func message(from string, to string, subject string, body string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
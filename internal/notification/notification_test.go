@@ -0,0 +1,129 @@
+package notification
+
+import (
+	"bufio"
+	"coffy/internal/coffy"
+	"coffy/internal/inventory"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLowestCrossed(t *testing.T) {
+	thresholds := []float64{-20, -10, 0}
+
+	crossed := lowestCrossed(thresholds, 1.5, -11)
+	if crossed == nil {
+		t.Errorf("expected a crossed threshold")
+		return
+	}
+	if *crossed != -10 {
+		t.Errorf("expected threshold -10 to be crossed, got %.2f", *crossed)
+	}
+
+	if crossed = lowestCrossed(thresholds, -11, -12); crossed != nil {
+		t.Errorf("expected no crossed threshold, got %.2f", *crossed)
+	}
+}
+
+// smtpSink accepts a single mail transaction and stores the received message data.
+func smtpSink(t *testing.T, received chan<- string) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start smtp sink: %v", err)
+	}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 sink ready")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 sink")
+			case cmd == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return l
+}
+
+func TestSmtpMailer(t *testing.T) {
+	received := make(chan string, 1)
+	l := smtpSink(t, received)
+	defer l.Close()
+
+	addr := l.Addr().(*net.TCPAddr)
+	mailer := NewSmtpMailer(&coffy.SmtpCfg{Host: "127.0.0.1", Port: addr.Port, From: "coffy@example.com"})
+	if err := mailer.Send("drinker@example.com", "Coffy test", "Enjoy your coffee!"); err != nil {
+		t.Errorf("failed to send mail: %v", err)
+		return
+	}
+	var data string
+	select {
+	case data = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the mail")
+	}
+	if !strings.Contains(data, "Subject: Coffy test") {
+		t.Errorf("expected subject in message, got: %s", data)
+	}
+	if !strings.Contains(data, "Enjoy your coffee!") {
+		t.Errorf("expected body in message, got: %s", data)
+	}
+}
+
+// recordingMailer records the sent mails instead of sending them.
+type recordingMailer struct {
+	sent []string
+}
+
+func (m *recordingMailer) Send(to string, subject string, body string) error {
+	m.sent = append(m.sent, to+"|"+subject+"|"+body)
+	return nil
+}
+
+func TestAlertLowStock(t *testing.T) {
+	mailer := &recordingMailer{}
+	s := NewService(nil, mailer, &coffy.NotificationCfg{})
+	if err := s.AlertLowStock("barista@example.com", nil); err != nil || len(mailer.sent) != 0 {
+		t.Errorf("expected no mail without low stock, got %v (%v)", mailer.sent, err)
+	}
+	days := 2.5
+	low := []inventory.Stock{{Coffee: "Espresso", Remaining: 80, DaysRemaining: &days}}
+	if err := s.AlertLowStock("barista@example.com", low); err != nil {
+		t.Errorf("failed to send alert: %v", err)
+		return
+	}
+	if len(mailer.sent) != 1 || !strings.Contains(mailer.sent[0], "Espresso is low on stock") || !strings.Contains(mailer.sent[0], "about 2.5 days") {
+		t.Errorf("expected alert naming the coffee and the days left, got %v", mailer.sent)
+	}
+}
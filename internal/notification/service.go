@@ -0,0 +1,118 @@
+package notification
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/coffy"
+	"coffy/internal/inventory"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Service notifies account owners via email about their balance, and the barista about low stock.
+type Service struct {
+	accounting *account.Accounting
+	mailer     Mailer
+	thresholds []float64
+	debtAge    time.Duration
+}
+
+func NewService(accounting *account.Accounting, mailer Mailer, cfg *coffy.NotificationCfg) *Service {
+	s := &Service{accounting: accounting, mailer: mailer}
+	s.thresholds = append(s.thresholds, cfg.Thresholds...)
+	// the lowest crossed threshold is the most relevant one
+	sort.Float64s(s.thresholds)
+	if cfg.Dunning != nil {
+		s.debtAge = time.Duration(cfg.Dunning.DebtAgeDays) * 24 * time.Hour
+	}
+	return s
+}
+
+// BalanceChanged is an account.BalanceListener that sends an email, if the account's balance
+// dropped below one of the configured thresholds.
+//
+// Accounts without an email address or without opt-in are ignored. Sending happens in the background, so
+// the consumption is not delayed by a slow mail server.
+func (s *Service) BalanceChanged(a *account.Account, previous float64) {
+	if a.Email() == "" || !a.Contact().Notifications.LowBalance {
+		return
+	}
+	crossed := lowestCrossed(s.thresholds, previous, a.Balance())
+	if crossed == nil {
+		return
+	}
+	subject := "Coffy: your balance dropped below " + formatAmount(*crossed)
+	body := fmt.Sprintf("Hi %s,\n\nyour coffy balance is now %s.\nPlease consider topping up your account.\n\nEnjoy your coffee!",
+		a.Owner(), formatAmount(a.Balance()))
+	to := a.Email()
+	go func() {
+		if err := s.mailer.Send(to, subject, body); err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// RemindDebtors sends a reminder to every account owner that has been in debt for longer
+// than the configured debt age and opted in for reminders.
+func (s *Service) RemindDebtors(now time.Time) error {
+	accounts, err := s.accounting.ListAll()
+	if err != nil {
+		return fmt.Errorf("failed to load accounts: %w", err)
+	}
+	var errs []error
+	for _, a := range accounts {
+		since, inDebt := a.DebtSince()
+		if !inDebt || a.Email() == "" || !a.Contact().Notifications.DebtReminder || now.Sub(since) < s.debtAge {
+			continue
+		}
+		subject := "Coffy: friendly reminder about your debts"
+		body := fmt.Sprintf("Hi %s,\n\nyour coffy balance has been negative since %s and is currently %s.\nPlease settle your debts soon.\n\nEnjoy your coffee!",
+			a.Owner(), since.Format(time.DateOnly), formatAmount(a.Balance()))
+		if err := s.mailer.Send(a.Email(), subject, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AlertLowStock sends an email listing the coffees that run out of beans soon. Nothing is sent
+// if no stock is low.
+func (s *Service) AlertLowStock(to string, low []inventory.Stock) error {
+	if len(low) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(low))
+	for _, stock := range low {
+		line := fmt.Sprintf("- %s: %.0f g left", stock.Coffee, stock.Remaining)
+		if stock.DaysRemaining != nil {
+			line += fmt.Sprintf(", about %.1f days", *stock.DaysRemaining)
+		}
+		lines = append(lines, line)
+	}
+	subject := fmt.Sprintf("Coffy: %d coffees are low on stock", len(low))
+	if len(low) == 1 {
+		subject = fmt.Sprintf("Coffy: %s is low on stock", low[0].Coffee)
+	}
+	body := fmt.Sprintf("Hi,\n\nthe beans of these coffees run out soon:\n%s\n\nPlease order new bags.", strings.Join(lines, "\n"))
+	return s.mailer.Send(to, subject, body)
+}
+
+// lowestCrossed returns the lowest threshold that lies between the previous balance (inclusive)
+// and the current balance (exclusive), or nil if no threshold has been crossed.
+//
+// The thresholds are expected to be sorted in ascending order.
+func lowestCrossed(thresholds []float64, previous float64, current float64) *float64 {
+	for _, t := range thresholds {
+		if previous >= t && current < t {
+			return &t
+		}
+	}
+	return nil
+}
+
+func formatAmount(amount float64) string {
+	return fmt.Sprintf("%.2f €", amount)
+}
@@ -0,0 +1,118 @@
+package pricing
+
+import (
+	"coffy/internal/coffy"
+	"math"
+	"slices"
+	"time"
+)
+
+// Context describes the circumstances of a consumption that pricing rules are evaluated against.
+type Context struct {
+	Group    string    // the account group of the consumer
+	CoffeeID string    // the consumed coffee
+	Time     time.Time // the time point of consumption
+}
+
+// Quote is the result of a price evaluation.
+type Quote struct {
+	Charged float64 // the amount the consumer is charged
+	Subsidy float64 // the discounted amount that is paid by someone else
+	Rule    string  // the name of the applied rule, empty if the full price is charged
+}
+
+// Rule is a discount that applies to consumptions matching all of its conditions.
+type Rule struct {
+	Name       string
+	Group      string
+	CoffeeID   string
+	Weekdays   []time.Weekday
+	Window     *[2]time.Duration // daily time window as offsets from midnight, nil for the whole day. Wraps past midnight if the start is after the end
+	Percentage float64
+	Fixed      float64
+}
+
+func (r Rule) matches(ctx Context) bool {
+	if r.Group != "" && r.Group != ctx.Group {
+		return false
+	}
+	if r.CoffeeID != "" && r.CoffeeID != ctx.CoffeeID {
+		return false
+	}
+	if len(r.Weekdays) > 0 && !slices.Contains(r.Weekdays, ctx.Time.Weekday()) {
+		return false
+	}
+	if r.Window != nil {
+		midnight := time.Date(ctx.Time.Year(), ctx.Time.Month(), ctx.Time.Day(), 0, 0, 0, 0, ctx.Time.Location())
+		offset := ctx.Time.Sub(midnight)
+		from, to := r.Window[0], r.Window[1]
+		if from < to && (offset < from || offset >= to) {
+			return false
+		}
+		// an overnight window, e.g. 22:00 to 06:00
+		if from > to && offset < from && offset >= to {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Rule) discount(price float64) float64 {
+	d := r.Fixed
+	if r.Percentage > 0 {
+		d = price * r.Percentage / 100
+	}
+	// a discount can never exceed the price
+	return math.Min(round(d), price)
+}
+
+// Engine evaluates pricing rules at consumption time.
+//
+// Rules do not stack: if several rules match, the one with the highest discount is applied.
+type Engine struct {
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// FromConfig creates an Engine from the pricing configuration. A nil configuration results in
+// an Engine that always charges the full price.
+func FromConfig(cfg *coffy.PricingCfg) (*Engine, error) {
+	if cfg == nil {
+		return NewEngine(), nil
+	}
+	rules := make([]Rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rule := Rule{Name: r.Name, Group: r.Group, CoffeeID: r.CoffeeID, Weekdays: r.Days(), Percentage: r.Percentage, Fixed: r.Fixed}
+		if r.From != "" {
+			window, err := r.Window()
+			if err != nil {
+				return nil, err
+			}
+			rule.Window = &window
+		}
+		rules = append(rules, rule)
+	}
+	return NewEngine(rules...), nil
+}
+
+// Quote evaluates all rules against the consumption context and returns the price to charge.
+func (e *Engine) Quote(price float64, ctx Context) Quote {
+	q := Quote{Charged: price}
+	for _, r := range e.rules {
+		if !r.matches(ctx) {
+			continue
+		}
+		if d := r.discount(price); d > q.Subsidy {
+			q = Quote{Charged: round(price - d), Subsidy: d, Rule: r.Name}
+		}
+	}
+	return q
+}
+
+// round rounds an amount to full cents.
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,75 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+)
+
+// a Friday afternoon
+var friday = time.Date(2025, 1, 17, 15, 0, 0, 0, time.UTC)
+
+func TestQuoteFullPrice(t *testing.T) {
+	e := NewEngine(Rule{Name: "apprentice subsidy", Group: "apprentice", Percentage: 50})
+	q := e.Quote(0.50, Context{Group: "visitor", Time: friday})
+	if q.Charged != 0.50 || q.Subsidy != 0 {
+		t.Errorf("expected full price, got %+v", q)
+	}
+}
+
+func TestQuoteGroupDiscount(t *testing.T) {
+	e := NewEngine(Rule{Name: "apprentice subsidy", Group: "apprentice", Percentage: 50})
+	q := e.Quote(0.50, Context{Group: "apprentice", Time: friday})
+	if q.Charged != 0.25 || q.Subsidy != 0.25 {
+		t.Errorf("expected half price, got %+v", q)
+	}
+	if q.Rule != "apprentice subsidy" {
+		t.Errorf("expected rule 'apprentice subsidy', got '%s'", q.Rule)
+	}
+}
+
+func TestQuoteBestRuleApplies(t *testing.T) {
+	window := [2]time.Duration{14 * time.Hour, 16 * time.Hour}
+	e := NewEngine(
+		Rule{Name: "happy hour", Weekdays: []time.Weekday{time.Friday}, Window: &window, Fixed: 0.10},
+		Rule{Name: "espresso", CoffeeID: "123", Fixed: 0.20},
+	)
+	q := e.Quote(0.50, Context{CoffeeID: "123", Time: friday})
+	if q.Rule != "espresso" || q.Charged != 0.30 {
+		t.Errorf("expected espresso discount, got %+v", q)
+	}
+	q = e.Quote(0.50, Context{CoffeeID: "456", Time: friday})
+	if q.Rule != "happy hour" || q.Charged != 0.40 {
+		t.Errorf("expected happy hour discount, got %+v", q)
+	}
+	q = e.Quote(0.50, Context{CoffeeID: "456", Time: friday.Add(2 * time.Hour)})
+	if q.Rule != "" {
+		t.Errorf("expected no discount after happy hour, got %+v", q)
+	}
+}
+
+func TestQuoteOvernightWindow(t *testing.T) {
+	window := [2]time.Duration{22 * time.Hour, 6 * time.Hour}
+	e := NewEngine(Rule{Name: "night shift", Window: &window, Fixed: 0.10})
+	midnight := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		offset time.Duration
+		rule   string
+	}{
+		{23 * time.Hour, "night shift"},
+		{2 * time.Hour, "night shift"},
+		{6 * time.Hour, ""},
+		{14 * time.Hour, ""},
+	} {
+		if q := e.Quote(0.50, Context{Time: midnight.Add(tt.offset)}); q.Rule != tt.rule {
+			t.Errorf("expected rule '%s' at %v, got %+v", tt.rule, tt.offset, q)
+		}
+	}
+}
+
+func TestQuoteDiscountLimitedByPrice(t *testing.T) {
+	e := NewEngine(Rule{Name: "free", Fixed: 1.00})
+	q := e.Quote(0.50, Context{Time: friday})
+	if q.Charged != 0 || q.Subsidy != 0.50 {
+		t.Errorf("expected free coffee, got %+v", q)
+	}
+}
@@ -5,17 +5,38 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"slices"
 	"time"
 )
 
 // Coffee is the actual representation of the black gold.
 type Coffee struct {
-	AggregateID string        // The ID to identify the coffee in the system
-	Type        string        // What type of coffee it is, e.g. black coffee, espresso, ...
-	price       float64       // The current price of the coffee in €, e.g. 0.50 for 50 cents
-	cva         CuppingScore  // The coffee value assessment result, currently the CuppingScore
-	details     Details       // A more detailed description about the coffee
-	events      []event.Event // Uncommitted events of the aggregate
+	AggregateID  string        // The ID to identify the coffee in the system
+	Type         string        // What type of coffee it is, e.g. black coffee, espresso, ...
+	cva          CuppingScore  // The coffee value assessment result, currently the CuppingScore
+	details      Details       // A more detailed description about the coffee
+	discontinued []Interval    // The periods the coffee has been out of the catalog, ordered by time
+	prices       []PriceChange // The prices in €, e.g. 0.50 for 50 cents, ordered by the time they apply from
+	events       []event.Event // Uncommitted events of the aggregate
+}
+
+// Interval is a period of time. Until is zero for a period that has not ended yet.
+type Interval struct {
+	From  time.Time
+	Until time.Time
+}
+
+// Contains reports whether the time point lies within the interval, which includes its start.
+func (i Interval) Contains(t time.Time) bool {
+	return !t.Before(i.From) && (i.Until.IsZero() || t.Before(i.Until))
+}
+
+// PriceChange is an entry of the price history of a coffee.
+type PriceChange struct {
+	ID            string    `json:"id,omitempty"`   // Identifies a scheduled change, so it can be cancelled
+	Price         float64   `json:"price"`          // The price in €
+	Reason        string    `json:"reason"`         // Why the price has been changed
+	EffectiveFrom time.Time `json:"effective_from"` // The time the price applies from
 }
 
 // CoffeeValue provides the assessed Value of the current coffee.
@@ -25,9 +46,25 @@ func (c *Coffee) CoffeeValue() *CuppingScore {
 	return &c.cva
 }
 
-// Price returns the latest price of the coffee.
+// Price returns the price of the coffee that is valid now.
 func (c *Coffee) Price() float64 {
-	return c.price
+	return c.PriceAt(time.Now())
+}
+
+// PriceAt returns the price of the coffee that is valid at the given time. Before the coffee has been
+// created, the initial price is returned.
+func (c *Coffee) PriceAt(t time.Time) float64 {
+	if len(c.prices) == 0 {
+		return 0
+	}
+	price := c.prices[0].Price
+	for _, p := range c.prices {
+		if p.EffectiveFrom.After(t) {
+			break
+		}
+		price = p.Price
+	}
+	return price
 }
 
 // Events returns all uncommitted events of the current coffee aggregate
@@ -89,6 +126,90 @@ func (c *Coffee) ChangePrice(p float64, reason string) error {
 	return nil
 }
 
+// SchedulePrice announces a price that applies from a time in the future. Until then, the current price applies.
+func (c *Coffee) SchedulePrice(p float64, reason string, effective time.Time) (PriceChange, error) {
+	if p <= 0 {
+		return PriceChange{}, errors.New("invalid price")
+	}
+	e := NewPriceUpdated(c.AggregateID, p, reason)
+	if !effective.After(e.OccurredOn) {
+		return PriceChange{}, errors.New("scheduled price must apply in the future")
+	}
+	e.ChangeID = uuid.NewString()
+	e.EffectiveFrom = effective
+	if err := c.apply(*e); err != nil {
+		return PriceChange{}, errors.Join(
+			fmt.Errorf("could not schedule price for %s [id: %s]",
+				c.Type, c.AggregateID), err)
+	}
+	return PriceChange{ID: e.ChangeID, Price: p, Reason: reason, EffectiveFrom: effective}, nil
+}
+
+// CancelPriceChange withdraws a scheduled price that does not apply yet.
+func (c *Coffee) CancelPriceChange(changeID string, now time.Time) error {
+	for _, p := range c.Upcoming(now) {
+		if p.ID == changeID {
+			return c.apply(PriceChangeCancelled{ID: c.AggregateID, ChangeID: changeID, OccurredOn: now})
+		}
+	}
+	return fmt.Errorf("no upcoming price change '%s'", changeID)
+}
+
+// Prices returns the history of prices of the coffee that apply at or before the given time,
+// starting with the initial price.
+func (c *Coffee) Prices(at time.Time) []PriceChange {
+	history := make([]PriceChange, 0, len(c.prices))
+	for _, p := range c.prices {
+		if !p.EffectiveFrom.After(at) {
+			history = append(history, p)
+		}
+	}
+	return history
+}
+
+// Upcoming returns the scheduled prices that apply after the given time.
+func (c *Coffee) Upcoming(at time.Time) []PriceChange {
+	upcoming := make([]PriceChange, 0)
+	for _, p := range c.prices {
+		if p.EffectiveFrom.After(at) {
+			upcoming = append(upcoming, p)
+		}
+	}
+	return upcoming
+}
+
+// Discontinued reports whether the coffee has been taken out of the catalog.
+func (c *Coffee) Discontinued() bool {
+	return len(c.discontinued) > 0 && c.discontinued[len(c.discontinued)-1].Until.IsZero()
+}
+
+// AvailableAt reports whether the coffee could be consumed at the given time, i.e. it was not discontinued then.
+func (c *Coffee) AvailableAt(t time.Time) bool {
+	for _, i := range c.discontinued {
+		if i.Contains(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Discontinue takes the coffee out of the catalog. It cannot be consumed anymore, but stays
+// resolvable for the history of consumptions.
+func (c *Coffee) Discontinue() error {
+	if c.Discontinued() {
+		return errors.New("coffee has been discontinued already")
+	}
+	return c.apply(CoffeeDiscontinued{ID: c.AggregateID, OccurredOn: time.Now()})
+}
+
+// Reinstate puts a discontinued coffee back into the catalog.
+func (c *Coffee) Reinstate() error {
+	if !c.Discontinued() {
+		return errors.New("coffee has not been discontinued")
+	}
+	return c.apply(CoffeeReinstated{ID: c.AggregateID, OccurredOn: time.Now()})
+}
+
 // Clear empties the current event cache of the coffee and removes all previously appended events.
 func (c *Coffee) Clear() {
 	c.events = []event.Event{}
@@ -143,10 +264,22 @@ func (c *Coffee) apply(e event.Event) error {
 		if err := c.applyNewPrice(theEvent); err != nil {
 			return err
 		}
+	case PriceChangeCancelled:
+		if err := c.applyPriceChangeCancelled(theEvent); err != nil {
+			return err
+		}
 	case CvaProvided:
 		if err := c.applyCva(theEvent); err != nil {
 			return err
 		}
+	case CoffeeDiscontinued:
+		if err := c.applyDiscontinued(theEvent); err != nil {
+			return err
+		}
+	case CoffeeReinstated:
+		if err := c.applyReinstated(theEvent); err != nil {
+			return err
+		}
 	case DetailsUpdated:
 		if err := c.applyDetails(theEvent); err != nil {
 			return err
@@ -161,7 +294,26 @@ func (c *Coffee) applyNewPrice(e PriceUpdated) error {
 	if e.AggregateID() != c.AggregateID {
 		return fmt.Errorf("coffee ids do not match: expected %s, actual %s", c.AggregateID, e.AggregateID())
 	}
-	c.price = e.Price
+	// prices changed before they could be scheduled apply immediately
+	effective := e.EffectiveFrom
+	if effective.IsZero() {
+		effective = e.OccurredOn
+	}
+	change := PriceChange{ID: e.ChangeID, Price: e.Price, Reason: e.Reason, EffectiveFrom: effective}
+	i := len(c.prices)
+	for i > 0 && c.prices[i-1].EffectiveFrom.After(effective) {
+		i--
+	}
+	c.prices = slices.Insert(c.prices, i, change)
+	c.events = append(c.events, e)
+	return nil
+}
+
+func (c *Coffee) applyPriceChangeCancelled(e PriceChangeCancelled) error {
+	if e.AggregateID() != c.AggregateID {
+		return fmt.Errorf("coffee ids do not match: expected %s, actual %s", c.AggregateID, e.AggregateID())
+	}
+	c.prices = slices.DeleteFunc(c.prices, func(p PriceChange) bool { return p.ID == e.ChangeID })
 	c.events = append(c.events, e)
 	return nil
 }
@@ -169,10 +321,13 @@ func (c *Coffee) applyNewPrice(e PriceUpdated) error {
 func (c *Coffee) applyCreated(e CoffeeCreated) {
 	c.AggregateID = e.ID
 	c.Type = e.BeverageType
-	c.price = e.Price
+	c.prices = append(c.prices, PriceChange{Price: e.Price, Reason: initialPrice, EffectiveFrom: e.OccurredOn})
 	c.events = append(c.events, e)
 }
 
+// initialPrice is the reason of the first entry of the price history.
+const initialPrice = "initial price"
+
 func (c *Coffee) applyCva(e CvaProvided) error {
 	if e.AggregateID() != c.AggregateID {
 		return fmt.Errorf("coffee ids do not match: expected %s, actual %s", c.AggregateID, e.AggregateID())
@@ -186,6 +341,26 @@ func (c *Coffee) applyCva(e CvaProvided) error {
 	return nil
 }
 
+func (c *Coffee) applyDiscontinued(e CoffeeDiscontinued) error {
+	if e.AggregateID() != c.AggregateID {
+		return fmt.Errorf("coffee ids do not match: expected %s, actual %s", c.AggregateID, e.AggregateID())
+	}
+	c.discontinued = append(c.discontinued, Interval{From: e.OccurredOn})
+	c.events = append(c.events, e)
+	return nil
+}
+
+func (c *Coffee) applyReinstated(e CoffeeReinstated) error {
+	if e.AggregateID() != c.AggregateID {
+		return fmt.Errorf("coffee ids do not match: expected %s, actual %s", c.AggregateID, e.AggregateID())
+	}
+	if c.Discontinued() {
+		c.discontinued[len(c.discontinued)-1].Until = e.OccurredOn
+	}
+	c.events = append(c.events, e)
+	return nil
+}
+
 func (c *Coffee) applyDetails(e DetailsUpdated) error {
 	c.details = e.Details
 	c.events = append(c.events, e)
@@ -242,14 +417,16 @@ func (b CoffeeCreated) Occurred() time.Time {
 }
 
 type PriceUpdated struct {
-	ID         string
-	Price      float64
-	Reason     string
-	OccurredOn time.Time
+	ID            string
+	Price         float64
+	Reason        string
+	OccurredOn    time.Time
+	ChangeID      string    // Identifies a scheduled change, empty for immediate changes
+	EffectiveFrom time.Time // The time a scheduled price applies from, zero for immediate changes
 }
 
 func NewPriceUpdated(aggregateID string, price float64, reason string) *PriceUpdated {
-	return &PriceUpdated{aggregateID, price, reason, time.Now()}
+	return &PriceUpdated{ID: aggregateID, Price: price, Reason: reason, OccurredOn: time.Now()}
 }
 
 func (e PriceUpdated) AggregateID() string {
@@ -264,6 +441,61 @@ func (e PriceUpdated) Occurred() time.Time {
 	return e.OccurredOn
 }
 
+// PriceChangeCancelled withdraws a scheduled price before it applies.
+type PriceChangeCancelled struct {
+	ID         string
+	ChangeID   string
+	OccurredOn time.Time
+}
+
+func (e PriceChangeCancelled) AggregateID() string {
+	return e.ID
+}
+
+func (e PriceChangeCancelled) Type() string {
+	return "PriceChangeCancelled"
+}
+
+func (e PriceChangeCancelled) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+// CoffeeDiscontinued takes a coffee out of the catalog.
+type CoffeeDiscontinued struct {
+	ID         string
+	OccurredOn time.Time
+}
+
+func (e CoffeeDiscontinued) AggregateID() string {
+	return e.ID
+}
+
+func (e CoffeeDiscontinued) Type() string {
+	return "CoffeeDiscontinued"
+}
+
+func (e CoffeeDiscontinued) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+// CoffeeReinstated puts a discontinued coffee back into the catalog.
+type CoffeeReinstated struct {
+	ID         string
+	OccurredOn time.Time
+}
+
+func (e CoffeeReinstated) AggregateID() string {
+	return e.ID
+}
+
+func (e CoffeeReinstated) Type() string {
+	return "CoffeeReinstated"
+}
+
+func (e CoffeeReinstated) Occurred() time.Time {
+	return e.OccurredOn
+}
+
 func newCvaProvided(id string, value int) CvaProvided {
 	return CvaProvided{ID: id, Value: value, OccurredOn: time.Now()}
 }
@@ -7,8 +7,14 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"time"
 )
 
+var ErrorNotFound = errors.New("coffee not found")
+var ErrorPriceChangeNotFound = errors.New("upcoming price change not found")
+var ErrorDiscontinued = errors.New("coffee has been discontinued")
+
 type Service struct {
 	repo storage.EventRepository
 }
@@ -17,6 +23,7 @@ func NewService(repo *storage.EventRepository) *Service {
 	return &Service{*repo}
 }
 
+// ListAll returns all coffees, including the discontinued ones.
 func (s *Service) ListAll() ([]Coffee, error) {
 	query, err := s.repo.FetchByEventType("CoffeeCreated")
 	if err != nil {
@@ -43,7 +50,7 @@ func (s *Service) Find(coffeeId string) (*Coffee, error) {
 
 	// No entries mean the beverage was not found, thus we can return an error here already
 	if len(entries) == 0 {
-		return nil, fmt.Errorf(errorMsg, coffeeId)
+		return nil, fmt.Errorf("%w: '%s'", ErrorNotFound, coffeeId)
 	}
 
 	events := make([]event.Event, 0)
@@ -62,9 +69,85 @@ func (s *Service) Find(coffeeId string) (*Coffee, error) {
 			return nil, fmt.Errorf(errorMsg, coffeeId)
 		}
 	}
+	b.Clear()
 	return b, nil
 }
 
+// ChangePrice sets a new price for the coffee. The reason is kept in the price history.
+func (s *Service) ChangePrice(coffeeID string, price float64, reason string) (*Coffee, error) {
+	return s.modify(coffeeID, func(c *Coffee) error { return c.ChangePrice(price, reason) })
+}
+
+// SchedulePrice announces a price of the coffee that applies from a time in the future.
+func (s *Service) SchedulePrice(coffeeID string, price float64, reason string, effective time.Time) (*PriceChange, error) {
+	var change PriceChange
+	if _, err := s.modify(coffeeID, func(c *Coffee) error {
+		scheduled, err := c.SchedulePrice(price, reason, effective)
+		change = scheduled
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// CancelPriceChange withdraws a scheduled price of the coffee that does not apply yet.
+func (s *Service) CancelPriceChange(coffeeID string, changeID string) (*Coffee, error) {
+	c, err := s.Find(coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(c.Upcoming(time.Now()), func(p PriceChange) bool { return p.ID == changeID }) {
+		return nil, fmt.Errorf("%w: '%s'", ErrorPriceChangeNotFound, changeID)
+	}
+	return s.modify(coffeeID, func(c *Coffee) error { return c.CancelPriceChange(changeID, time.Now()) })
+}
+
+// Discontinue takes the coffee out of the catalog. Discontinued coffees cannot be consumed or loaded
+// into machines anymore, but can still be found for the history of consumptions.
+func (s *Service) Discontinue(coffeeID string) (*Coffee, error) {
+	return s.modify(coffeeID, func(c *Coffee) error { return c.Discontinue() })
+}
+
+// Reinstate puts a discontinued coffee back into the catalog.
+func (s *Service) Reinstate(coffeeID string) (*Coffee, error) {
+	return s.modify(coffeeID, func(c *Coffee) error { return c.Reinstate() })
+}
+
+// UpdateDetails replaces the detailed description of the coffee.
+func (s *Service) UpdateDetails(coffeeID string, details Details) (*Coffee, error) {
+	return s.modify(coffeeID, func(c *Coffee) error { return c.UpdateDetails(details) })
+}
+
+// Assess sets the cupping score of the coffee. Related event entries of other aggregates are saved in the same
+// transaction, e.g. the cupping session the score has been determined in.
+func (s *Service) Assess(coffeeID string, score int, related ...storage.EventEntry) (*Coffee, error) {
+	return s.modify(coffeeID, func(c *Coffee) error { return c.SetCuppingScore(score) }, related...)
+}
+
+func (s *Service) modify(coffeeID string, change func(*Coffee) error, related ...storage.EventEntry) (*Coffee, error) {
+	c, err := s.Find(coffeeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(c); err != nil {
+		return nil, fmt.Errorf("%w: %s", InvalidPropertyError, err.Error())
+	}
+	entries := related
+	for _, e := range c.Events() {
+		entry, err := toEventEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := s.repo.SaveAll(entries); err != nil {
+		return nil, fmt.Errorf("failed to save coffee '%s': %w", coffeeID, err)
+	}
+	c.Clear()
+	return c, nil
+}
+
 func convert(entry storage.EventEntry) (event.Event, error) {
 	switch entry.EventType {
 	case "CoffeeCreated":
@@ -79,6 +162,24 @@ func convert(entry storage.EventEntry) (event.Event, error) {
 			return nil, err
 		}
 		return evnt, nil
+	case "PriceChangeCancelled":
+		evnt, err := toPriceChangeCancelled(entry)
+		if err != nil {
+			return nil, err
+		}
+		return evnt, nil
+	case "CoffeeDiscontinued":
+		e := CoffeeDiscontinued{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("could not unmarshal event data as CoffeeDiscontinued: %w", err)
+		}
+		return e, nil
+	case "CoffeeReinstated":
+		e := CoffeeReinstated{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("could not unmarshal event data as CoffeeReinstated: %w", err)
+		}
+		return e, nil
 	case "CvaProvided":
 		evnt, err := toCvaProvided(entry)
 		if err != nil {
@@ -160,6 +261,18 @@ func toEventEntry(event event.Event) (storage.EventEntry, error) {
 			return storage.EventEntry{}, err
 		}
 		return storage.EventEntry{AggregateID: t.AggregateID(), Date: t.OccurredOn, EventType: "PriceUpdated", EventData: data}, nil
+	case PriceChangeCancelled:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: t.AggregateID(), Date: t.OccurredOn, EventType: "PriceChangeCancelled", EventData: data}, nil
+	case CoffeeDiscontinued, CoffeeReinstated:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: event.AggregateID(), Date: event.Occurred(), EventType: event.Type(), EventData: data}, nil
 	case CvaProvided:
 		data, err := json.Marshal(t)
 		if err != nil {
@@ -193,6 +306,14 @@ func toPriceUpdated(entry storage.EventEntry) (event.Event, error) {
 	return e, nil
 }
 
+func toPriceChangeCancelled(entry storage.EventEntry) (event.Event, error) {
+	e := PriceChangeCancelled{}
+	if err := json.Unmarshal(entry.EventData, &e); err != nil {
+		return nil, fmt.Errorf("could not unmarshal event data as PriceChangeCancelled: %w", err)
+	}
+	return e, nil
+}
+
 func toCvaProvided(entry storage.EventEntry) (event.Event, error) {
 	e := CvaProvided{}
 	if err := json.Unmarshal(entry.EventData, &e); err != nil {
@@ -0,0 +1,134 @@
+package product
+
+import (
+	"coffy/internal/storage/storagetest"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestServicePersistsChanges(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	s := NewService(&repo)
+	c, err := s.Create("Espresso", 0.50, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ChangePrice(c.AggregateID, 0.60, "new beans"); err != nil {
+		t.Fatalf("ChangePrice() error = %v", err)
+	}
+	if _, err := s.UpdateDetails(c.AggregateID, Details{Origin: "Ethiopia", RoastHouse: "Berlin"}); err != nil {
+		t.Fatalf("UpdateDetails() error = %v", err)
+	}
+
+	stored, err := s.Find(c.AggregateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Price() != 0.60 || stored.Details().Origin != "Ethiopia" {
+		t.Errorf("Expected the changes to be persisted, got price %.2f and details %+v", stored.Price(), stored.Details())
+	}
+	prices := stored.Prices(time.Now())
+	if len(prices) != 2 || prices[0].Price != 0.50 || prices[0].Reason != initialPrice || prices[1].Reason != "new beans" {
+		t.Errorf("Unexpected price history %+v", prices)
+	}
+	if prices[1].EffectiveFrom.Before(prices[0].EffectiveFrom) {
+		t.Errorf("Expected price history to be ordered by effective date, got %+v", prices)
+	}
+
+	if _, err := s.ChangePrice(c.AggregateID, -1, "mistake"); !errors.Is(err, InvalidPropertyError) {
+		t.Errorf("Expected InvalidPropertyError, got %v", err)
+	}
+	if _, err := s.ChangePrice("unknown", 0.60, "new beans"); !errors.Is(err, ErrorNotFound) {
+		t.Errorf("Expected ErrorNotFound, got %v", err)
+	}
+}
+
+func TestServiceSchedulesPrices(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	s := NewService(&repo)
+	c, err := s.Create("Espresso", 0.50, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextWeek := time.Now().Add(7 * 24 * time.Hour)
+	scheduled, err := s.SchedulePrice(c.AggregateID, 0.70, "roaster raises prices", nextWeek)
+	if err != nil {
+		t.Fatalf("SchedulePrice() error = %v", err)
+	}
+	if _, err := s.SchedulePrice(c.AggregateID, 0.70, "too late", time.Now().Add(-time.Hour)); !errors.Is(err, InvalidPropertyError) {
+		t.Errorf("Expected price in the past to be rejected, got %v", err)
+	}
+
+	stored, err := s.Find(c.AggregateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Price() != 0.50 || stored.PriceAt(nextWeek) != 0.70 {
+		t.Errorf("Expected 0.50 now and 0.70 next week, got %.2f and %.2f", stored.Price(), stored.PriceAt(nextWeek))
+	}
+	upcoming := stored.Upcoming(time.Now())
+	if len(upcoming) != 1 || upcoming[0].ID != scheduled.ID || len(stored.Prices(time.Now())) != 1 {
+		t.Errorf("Expected the scheduled price to be upcoming only, got %+v", upcoming)
+	}
+
+	// an immediate change applies until the scheduled price takes over
+	if _, err := s.ChangePrice(c.AggregateID, 0.60, "new beans"); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ = s.Find(c.AggregateID)
+	if stored.Price() != 0.60 || stored.PriceAt(nextWeek) != 0.70 {
+		t.Errorf("Expected 0.60 now and 0.70 next week, got %.2f and %.2f", stored.Price(), stored.PriceAt(nextWeek))
+	}
+
+	if _, err := s.CancelPriceChange(c.AggregateID, scheduled.ID); err != nil {
+		t.Fatalf("CancelPriceChange() error = %v", err)
+	}
+	stored, _ = s.Find(c.AggregateID)
+	if stored.PriceAt(nextWeek) != 0.60 || len(stored.Upcoming(time.Now())) != 0 {
+		t.Errorf("Expected the cancelled price not to apply, got %.2f next week", stored.PriceAt(nextWeek))
+	}
+	if _, err := s.CancelPriceChange(c.AggregateID, scheduled.ID); !errors.Is(err, ErrorPriceChangeNotFound) {
+		t.Errorf("Expected ErrorPriceChangeNotFound, got %v", err)
+	}
+}
+
+func TestServiceDiscontinuesCoffees(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	s := NewService(&repo)
+	c, err := s.Create("Espresso", 0.50, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	if _, err := s.Discontinue(c.AggregateID); err != nil {
+		t.Fatalf("Discontinue() error = %v", err)
+	}
+	if _, err := s.Discontinue(c.AggregateID); !errors.Is(err, InvalidPropertyError) {
+		t.Errorf("Expected discontinuing twice to fail, got %v", err)
+	}
+
+	stored, err := s.Find(c.AggregateID)
+	if err != nil {
+		t.Fatalf("Expected discontinued coffee to be found, got %v", err)
+	}
+	if !stored.Discontinued() || stored.AvailableAt(time.Now()) || !stored.AvailableAt(before) {
+		t.Errorf("Expected coffee to be available before it has been discontinued only")
+	}
+	during := time.Now()
+
+	if _, err := s.Reinstate(c.AggregateID); err != nil {
+		t.Fatalf("Reinstate() error = %v", err)
+	}
+	stored, _ = s.Find(c.AggregateID)
+	if stored.Discontinued() || !stored.AvailableAt(time.Now()) {
+		t.Errorf("Expected reinstated coffee to be available")
+	}
+	if stored.AvailableAt(during) || !stored.AvailableAt(before) {
+		t.Errorf("Expected reinstated coffee to be unavailable while it has been discontinued")
+	}
+	if _, err := s.Reinstate(c.AggregateID); !errors.Is(err, InvalidPropertyError) {
+		t.Errorf("Expected reinstating an available coffee to fail, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package quota
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/coffy"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var ErrorQuotaExceeded = errors.New("quota exceeded")
+
+// Period is the time span cups are counted in.
+type Period string
+
+const (
+	Day  Period = "day"
+	Week Period = "week" // a calendar week, starting on Monday
+)
+
+// bounds returns the half-open interval [from, to) of the period that contains t.
+func (p Period) bounds(t time.Time) (time.Time, time.Time) {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if p == Week {
+		// days since Monday
+		monday := midnight.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		return monday, monday.AddDate(0, 0, 7)
+	}
+	return midnight, midnight.AddDate(0, 0, 1)
+}
+
+// Rule caps the number of cups an account may consume per period.
+type Rule struct {
+	Name       string
+	Period     Period
+	Max        int
+	Accounts   []string // the accounts the rule applies to, empty for all accounts
+	Group      string   // the account group the rule applies to, empty for all groups
+	Subsidised bool     // counts subsidised cups only
+}
+
+func (r Rule) appliesTo(a *account.Account) bool {
+	if len(r.Accounts) > 0 && !slices.Contains(r.Accounts, a.ID()) {
+		return false
+	}
+	return r.Group == "" || r.Group == a.Group()
+}
+
+// counts reports whether a cup counts against the rule.
+func (r Rule) counts(subsidy float64) bool {
+	return !r.Subsidised || subsidy > 0
+}
+
+// Limiter evaluates quota rules before a consumption is charged. Every matching rule must be satisfied.
+type Limiter struct {
+	rules []Rule
+}
+
+func NewLimiter(rules ...Rule) *Limiter {
+	return &Limiter{rules: rules}
+}
+
+// FromConfig creates a Limiter from the quota configuration. A nil configuration results in
+// a Limiter without any caps.
+func FromConfig(cfg *coffy.QuotaCfg) *Limiter {
+	if cfg == nil {
+		return NewLimiter()
+	}
+	rules := make([]Rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rules = append(rules, Rule{Name: r.Name, Period: Period(r.Period), Max: r.Max, Accounts: r.Accounts, Group: r.Group, Subsidised: r.Subsidised})
+	}
+	return NewLimiter(rules...)
+}
+
+// Check evaluates the rules against the consumed cups of the account and the cups about to be charged
+// at the given time. It returns an error wrapping ErrorQuotaExceeded naming the first violated rule.
+func (l *Limiter) Check(a *account.Account, history []account.CoffyConsumed, charged []account.Consumption, t time.Time) error {
+	for _, r := range l.rules {
+		if !r.appliesTo(a) {
+			continue
+		}
+		from, to := r.Period.bounds(t)
+		used := 0
+		for _, c := range history {
+			if !c.OccurredOn.Before(from) && c.OccurredOn.Before(to) && r.counts(c.Subsidy) {
+				used++
+			}
+		}
+		requested := 0
+		for _, c := range charged {
+			if r.counts(c.Subsidy) {
+				requested++
+			}
+		}
+		if requested > 0 && used+requested > r.Max {
+			return fmt.Errorf("%w: '%s' allows %d cups per %s, %d already consumed", ErrorQuotaExceeded, r.Name, r.Max, r.Period, used)
+		}
+	}
+	return nil
+}
+
+// Active reports whether any rule is configured.
+func (l *Limiter) Active() bool {
+	return len(l.rules) > 0
+}
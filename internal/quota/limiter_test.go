@@ -0,0 +1,57 @@
+package quota
+
+import (
+	"coffy/internal/account"
+	"errors"
+	"testing"
+	"time"
+)
+
+func consumed(t time.Time, subsidy float64) account.CoffyConsumed {
+	return account.CoffyConsumed{OccurredOn: t, Costs: 0.50, Subsidy: subsidy}
+}
+
+func TestDailyQuota(t *testing.T) {
+	a, _ := account.NewAccount("Coffy", "")
+	// a Wednesday
+	now := time.Date(2024, 3, 6, 15, 0, 0, 0, time.UTC)
+	history := []account.CoffyConsumed{
+		consumed(now.AddDate(0, 0, -1), 0),
+		consumed(now.Add(-6*time.Hour), 0),
+		consumed(now.Add(-time.Hour), 0),
+	}
+	l := NewLimiter(Rule{Name: "health", Period: Day, Max: 3})
+	if err := l.Check(a, history, []account.Consumption{{Costs: 0.50}}, now); err != nil {
+		t.Errorf("Third cup of the day should be allowed, got %v", err)
+	}
+	history = append(history, consumed(now.Add(-time.Minute), 0))
+	if err := l.Check(a, history, []account.Consumption{{Costs: 0.50}}, now); !errors.Is(err, ErrorQuotaExceeded) {
+		t.Errorf("Expected ErrorQuotaExceeded for the fourth cup, got %v", err)
+	}
+}
+
+func TestWeeklySubsidisedQuota(t *testing.T) {
+	a, _ := account.NewAccount("Coffy", "")
+	_ = a.AssignGroup("apprentice")
+	now := time.Date(2024, 3, 6, 15, 0, 0, 0, time.UTC)
+	history := []account.CoffyConsumed{
+		consumed(time.Date(2024, 3, 3, 9, 0, 0, 0, time.UTC), 0.25), // the Sunday before
+		consumed(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC), 0.25), // Monday
+		consumed(time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC), 0),
+	}
+	l := NewLimiter(Rule{Name: "subsidised cups", Period: Week, Max: 2, Group: "apprentice", Subsidised: true})
+	if err := l.Check(a, history, []account.Consumption{{Costs: 0.25, Subsidy: 0.25}}, now); err != nil {
+		t.Errorf("Second subsidised cup of the week should be allowed, got %v", err)
+	}
+	charged := []account.Consumption{{Costs: 0.25, Subsidy: 0.25}, {Costs: 0.25, Subsidy: 0.25}}
+	if err := l.Check(a, history, charged, now); !errors.Is(err, ErrorQuotaExceeded) {
+		t.Errorf("Expected ErrorQuotaExceeded for the third subsidised cup, got %v", err)
+	}
+	if err := l.Check(a, history, []account.Consumption{{Costs: 0.50}, {Costs: 0.50}}, now); err != nil {
+		t.Errorf("Cups without subsidy should not count, got %v", err)
+	}
+	other, _ := account.NewAccount("Someone", "")
+	if err := l.Check(other, history, charged, now); err != nil {
+		t.Errorf("Rule should only apply to its group, got %v", err)
+	}
+}
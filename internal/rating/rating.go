@@ -0,0 +1,89 @@
+package rating
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxNotesLength is the maximum number of characters of tasting notes.
+const MaxNotesLength = 280
+
+// Rating is the opinion of an account about a coffee. Each account holds one rating per coffee,
+// rating the coffee again replaces it.
+type Rating struct {
+	CoffeeID  string    `json:"coffee_id"`
+	AccountID string    `json:"account_id"`
+	Stars     int       `json:"stars"`           // from 1 (worst) to 5 (best)
+	Notes     string    `json:"notes,omitempty"` // short tasting notes, e.g. 'chocolate, low acidity'
+	Date      time.Time `json:"date"`            // the time of the latest change
+}
+
+// Summary aggregates the ratings of a coffee.
+type Summary struct {
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
+
+func newRating(coffeeID string, accountID string, stars int, notes string) (Rating, error) {
+	if stars < 1 || stars > 5 {
+		return Rating{}, errors.New("stars must be between 1 and 5")
+	}
+	notes = strings.TrimSpace(notes)
+	if utf8.RuneCountInString(notes) > MaxNotesLength {
+		return Rating{}, fmt.Errorf("notes must not exceed %d characters", MaxNotesLength)
+	}
+	return Rating{CoffeeID: coffeeID, AccountID: accountID, Stars: stars, Notes: notes, Date: time.Now()}, nil
+}
+
+func summarize(ratings []Rating) Summary {
+	if len(ratings) == 0 {
+		return Summary{}
+	}
+	sum := 0
+	for _, r := range ratings {
+		sum += r.Stars
+	}
+	return Summary{Average: float64(sum) / float64(len(ratings)), Count: len(ratings)}
+}
+
+func aggregateID(coffeeID string, accountID string) string {
+	return fmt.Sprintf("rating:%s:%s", coffeeID, accountID)
+}
+
+// The CoffeeRated event records the latest rating of an account for a coffee.
+type CoffeeRated struct {
+	ID         string    `json:"id"`
+	OccurredOn time.Time `json:"occurredOn"`
+	EventType  string    `json:"eventType"`
+	CoffeeID   string    `json:"coffeeID"`
+	AccountID  string    `json:"accountID"`
+	Stars      int       `json:"stars"`
+	Notes      string    `json:"notes"`
+}
+
+func newCoffeeRated(r Rating) CoffeeRated {
+	return CoffeeRated{
+		ID:         aggregateID(r.CoffeeID, r.AccountID),
+		OccurredOn: r.Date,
+		EventType:  "CoffeeRated",
+		CoffeeID:   r.CoffeeID,
+		AccountID:  r.AccountID,
+		Stars:      r.Stars,
+		Notes:      r.Notes,
+	}
+}
+
+func (e CoffeeRated) AggregateID() string {
+	return e.ID
+}
+
+func (e CoffeeRated) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e CoffeeRated) Type() string {
+	return e.EventType
+}
@@ -0,0 +1,142 @@
+package rating
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/product"
+	"coffy/internal/storage"
+	"coffy/internal/storage/storagetest"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T) (*Service, *account.Accounting, *product.Service) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	coffees := product.NewService(&repo)
+	return NewService(&repo, accounting, coffees), accounting, coffees
+}
+
+func TestRateReplacesPreviousRating(t *testing.T) {
+	s, accounting, coffees := newTestService(t)
+	a, _ := accounting.Create("Coffy", "")
+	c, _ := coffees.Create("Espresso", 0.50, nil, nil)
+
+	r, replaced, err := s.Rate(c.AggregateID, a.ID(), 3, " nutty ")
+	if err != nil || replaced || r.Notes != "nutty" {
+		t.Fatalf("Expected a new rating with trimmed notes, got %+v, replaced %t (%v)", r, replaced, err)
+	}
+	if _, replaced, err = s.Rate(c.AggregateID, a.ID(), 5, "chocolate"); err != nil || !replaced {
+		t.Fatalf("Expected the rating to be replaced, got replaced %t (%v)", replaced, err)
+	}
+	ratings, err := s.Ratings(c.AggregateID)
+	if err != nil || len(ratings) != 1 || ratings[0].Stars != 5 || ratings[0].Notes != "chocolate" {
+		t.Errorf("Expected the latest rating only, got %+v (%v)", ratings, err)
+	}
+}
+
+func TestRateValidates(t *testing.T) {
+	s, accounting, coffees := newTestService(t)
+	a, _ := accounting.Create("Coffy", "")
+	c, _ := coffees.Create("Espresso", 0.50, nil, nil)
+
+	if _, _, err := s.Rate(c.AggregateID, a.ID(), 0, ""); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("Expected ErrorInvalidProperty for 0 stars, got %v", err)
+	}
+	if _, _, err := s.Rate(c.AggregateID, a.ID(), 6, ""); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("Expected ErrorInvalidProperty for 6 stars, got %v", err)
+	}
+	if _, _, err := s.Rate(c.AggregateID, a.ID(), 4, strings.Repeat("x", MaxNotesLength+1)); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("Expected ErrorInvalidProperty for long notes, got %v", err)
+	}
+	if _, _, err := s.Rate("unknown", a.ID(), 4, ""); !errors.Is(err, product.ErrorNotFound) {
+		t.Errorf("Expected product.ErrorNotFound, got %v", err)
+	}
+	if _, _, err := s.Rate(c.AggregateID, "unknown", 4, ""); !errors.Is(err, account.ErrorNotFound) {
+		t.Errorf("Expected account.ErrorNotFound, got %v", err)
+	}
+}
+
+func TestTopRated(t *testing.T) {
+	s, accounting, coffees := newTestService(t)
+	a, _ := accounting.Create("Alice", "")
+	b, _ := accounting.Create("Bob", "")
+	espresso, _ := coffees.Create("Espresso", 0.50, nil, nil)
+	lungo, _ := coffees.Create("Lungo", 0.50, nil, nil)
+	decaf, _ := coffees.Create("Decaf", 0.50, nil, nil)
+	for _, r := range []struct {
+		coffee  string
+		account string
+		stars   int
+	}{
+		{espresso.AggregateID, a.ID(), 4}, {espresso.AggregateID, b.ID(), 5},
+		{lungo.AggregateID, a.ID(), 5},
+		{decaf.AggregateID, a.ID(), 2}, {decaf.AggregateID, b.ID(), 3},
+	} {
+		if _, _, err := s.Rate(r.coffee, r.account, r.stars, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	top, summaries, err := s.TopRated(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 3 || top[0].AggregateID != lungo.AggregateID || top[2].AggregateID != decaf.AggregateID {
+		t.Errorf("Expected coffees ordered by average rating, got %+v", top)
+	}
+	if summary := summaries[espresso.AggregateID]; summary.Average != 4.5 || summary.Count != 2 {
+		t.Errorf("Expected an average of 4.5 of 2 ratings, got %+v", summary)
+	}
+	if top, _, _ := s.TopRated(1, 2); len(top) != 1 || top[0].AggregateID != espresso.AggregateID {
+		t.Errorf("Expected the best coffee with at least 2 ratings, got %+v", top)
+	}
+}
+
+func TestRatingsKeepLatest(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	coffees := product.NewService(&repo)
+	s := NewService(&repo, accounting, coffees)
+	a, _ := accounting.Create("Coffy", "")
+	c, _ := coffees.Create("Espresso", 0.50, nil, nil)
+
+	if _, _, err := s.Rate(c.AggregateID, a.ID(), 5, "chocolate"); err != nil {
+		t.Fatal(err)
+	}
+	// a rating saved after the current one, but given before it
+	older := Rating{CoffeeID: c.AggregateID, AccountID: a.ID(), Stars: 1, Date: time.Now().Add(-time.Hour)}
+	data, _ := json.Marshal(newCoffeeRated(older))
+	_ = repo.SaveAll([]storage.EventEntry{{AggregateID: aggregateID(c.AggregateID, a.ID()), EventType: "CoffeeRated", Date: older.Date, EventData: data}})
+
+	ratings, err := s.Ratings(c.AggregateID)
+	if err != nil || len(ratings) != 1 || ratings[0].Stars != 5 {
+		t.Errorf("Expected the latest rating to be kept, got %+v (%v)", ratings, err)
+	}
+}
+
+func TestRatingsOfMergedAccounts(t *testing.T) {
+	s, accounting, coffees := newTestService(t)
+	source, _ := accounting.Create("Coffy", "")
+	target, _ := accounting.Create("Coffy Again", "")
+	c, _ := coffees.Create("Espresso", 0.50, nil, nil)
+
+	if _, _, err := s.Rate(c.AggregateID, target.ID(), 2, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Rate(c.AggregateID, source.ID(), 4, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounting.Merge(source.ID(), target.ID()); err != nil {
+		t.Fatal(err)
+	}
+	ratings, err := s.Ratings(c.AggregateID)
+	if err != nil || len(ratings) != 1 || ratings[0].AccountID != target.ID() || ratings[0].Stars != 4 {
+		t.Errorf("Expected a single rating of the target account, got %+v (%v)", ratings, err)
+	}
+	if _, replaced, err := s.Rate(c.AggregateID, target.ID(), 5, ""); err != nil || !replaced {
+		t.Errorf("Expected the merged rating to be replaced, got replaced %t (%v)", replaced, err)
+	}
+}
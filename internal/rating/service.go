@@ -0,0 +1,167 @@
+package rating
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/product"
+	"coffy/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+var ErrorInvalidProperty = errors.New("invalid property")
+
+type Service struct {
+	repo       storage.EventRepository
+	accounting *account.Accounting
+	coffees    *product.Service
+}
+
+func NewService(repo *storage.EventRepository, accounting *account.Accounting, coffees *product.Service) *Service {
+	return &Service{repo: *repo, accounting: accounting, coffees: coffees}
+}
+
+// Rate records the rating of an account for a coffee, replacing a previous rating of the account or
+// of an account that has been merged into it. It reports whether a previous rating has been replaced.
+func (s *Service) Rate(coffeeID string, accountID string, stars int, notes string) (*Rating, bool, error) {
+	c, err := s.coffees.Find(coffeeID)
+	if err != nil {
+		return nil, false, err
+	}
+	a, err := s.accounting.Resolve(accountID)
+	if err != nil {
+		return nil, false, err
+	}
+	r, err := newRating(c.AggregateID, a.ID(), stars, notes)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", ErrorInvalidProperty, err.Error())
+	}
+	all, err := s.all()
+	if err != nil {
+		return nil, false, err
+	}
+	replaced := slices.ContainsFunc(all[r.CoffeeID], func(p Rating) bool { return p.AccountID == r.AccountID })
+	data, err := json.Marshal(newCoffeeRated(r))
+	if err != nil {
+		return nil, false, err
+	}
+	entry := storage.EventEntry{AggregateID: aggregateID(r.CoffeeID, r.AccountID), EventType: "CoffeeRated", Date: r.Date, EventData: data}
+	if err := s.repo.SaveAll([]storage.EventEntry{entry}); err != nil {
+		return nil, false, fmt.Errorf("failed to save rating: %w", err)
+	}
+	return &r, replaced, nil
+}
+
+// Ratings returns the current ratings of a coffee, the latest first.
+func (s *Service) Ratings(coffeeID string) ([]Rating, error) {
+	all, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	ratings := all[coffeeID]
+	sort.SliceStable(ratings, func(i, j int) bool { return ratings[i].Date.After(ratings[j].Date) })
+	return ratings, nil
+}
+
+// Summaries returns the aggregated ratings of all rated coffees by coffee ID.
+func (s *Service) Summaries() (map[string]Summary, error) {
+	all, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	summaries := make(map[string]Summary, len(all))
+	for coffeeID, ratings := range all {
+		summaries[coffeeID] = summarize(ratings)
+	}
+	return summaries, nil
+}
+
+// TopRated returns the coffees with the best average rating, limited to coffees with at least
+// the given number of ratings. Discontinued coffees are included, so the best beans can be bought again.
+func (s *Service) TopRated(limit int, minRatings int) ([]product.Coffee, map[string]Summary, error) {
+	summaries, err := s.Summaries()
+	if err != nil {
+		return nil, nil, err
+	}
+	coffees, err := s.coffees.ListAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	top := make([]product.Coffee, 0)
+	for _, c := range coffees {
+		if summary, ok := summaries[c.AggregateID]; ok && summary.Count >= minRatings {
+			top = append(top, c)
+		}
+	}
+	sort.SliceStable(top, func(i, j int) bool {
+		a, b := summaries[top[i].AggregateID], summaries[top[j].AggregateID]
+		if a.Average != b.Average {
+			return a.Average > b.Average
+		}
+		return a.Count > b.Count
+	})
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
+	return top, summaries, nil
+}
+
+// all returns the current rating of each account, grouped by coffee ID. Ratings of merged accounts count
+// for the account they have been merged into, which keeps the latest of both ratings.
+func (s *Service) all() (map[string][]Rating, error) {
+	entries, err := s.repo.FetchByEventType("CoffeeRated")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ratings: %w", err)
+	}
+	type latestRating struct {
+		rating  Rating
+		entryID int
+	}
+	resolved := make(map[string]string)
+	latest := make(map[string]latestRating)
+	order := make([]string, 0)
+	for _, entry := range entries {
+		e := CoffeeRated{}
+		if err := json.Unmarshal(entry.EventData, &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as CoffeeRated: %w", err)
+		}
+		accountID, ok := resolved[e.AccountID]
+		if !ok {
+			accountID, err = s.resolve(e.AccountID)
+			if err != nil {
+				return nil, err
+			}
+			resolved[e.AccountID] = accountID
+		}
+		id := aggregateID(e.CoffeeID, accountID)
+		current, found := latest[id]
+		if !found {
+			order = append(order, id)
+		} else if c := e.OccurredOn.Compare(current.rating.Date); c < 0 || (c == 0 && entry.ID < current.entryID) {
+			continue
+		}
+		r := Rating{CoffeeID: e.CoffeeID, AccountID: accountID, Stars: e.Stars, Notes: e.Notes, Date: e.OccurredOn}
+		latest[id] = latestRating{rating: r, entryID: entry.ID}
+	}
+	byCoffee := make(map[string][]Rating)
+	for _, id := range order {
+		r := latest[id].rating
+		byCoffee[r.CoffeeID] = append(byCoffee[r.CoffeeID], r)
+	}
+	return byCoffee, nil
+}
+
+// resolve returns the account that is in use for the account of a rating. Ratings of unknown accounts
+// are kept as they are.
+func (s *Service) resolve(accountID string) (string, error) {
+	a, err := s.accounting.Resolve(accountID)
+	if errors.Is(err, account.ErrorNotFound) {
+		return accountID, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve account of rating: %w", err)
+	}
+	return a.ID(), nil
+}
@@ -0,0 +1,177 @@
+package report
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/consume"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CostReport compares the actual costs of each coffee in a time range with its revenue and price.
+type CostReport struct {
+	From         time.Time     `json:"from"`
+	To           time.Time     `json:"to"`
+	TargetMargin float64       `json:"target_margin"` // in percent of the price
+	Cups         int           `json:"cups"`
+	Beans        float64       `json:"beans"`       // the costs of the beans consumed
+	Maintenance  float64       `json:"maintenance"` // the costs of maintenance, shared by all cups
+	Revenue      float64       `json:"revenue"`     // the charged and subsidised amounts and the subscription fees of covered cups
+	Result       float64       `json:"result"`      // the revenue minus the costs, negative for a deficit
+	Unallocated  float64       `json:"unallocated"` // subscription fees of billing periods without covered cups, not part of the revenue
+	Coffees      []CoffeeCosts `json:"coffees"`
+}
+
+// CoffeeCosts holds the costs of a single coffee and the price that achieves the target margin.
+type CoffeeCosts struct {
+	CoffeeID         string  `json:"coffee_id"`
+	Coffee           string  `json:"coffee"`
+	Cups             int     `json:"cups"`
+	Beans            float64 `json:"beans"`
+	Maintenance      float64 `json:"maintenance"`
+	Revenue          float64 `json:"revenue"`
+	Result           float64 `json:"result"`
+	CostPerCup       float64 `json:"cost_per_cup"`                // the costs of a cup of plain coffee
+	Price            float64 `json:"price"`                       // the current price of a cup
+	RecommendedPrice float64 `json:"recommended_price"`           // the price of a cup that achieves the target margin
+	MissingPurchases bool    `json:"missing_purchases,omitempty"` // no bags have been purchased, the costs of the beans are unknown
+}
+
+// TargetMargin returns the configured margin in percent of the price the recommended prices achieve.
+func (s *Service) TargetMargin() float64 {
+	return s.targetMargin
+}
+
+// Costs creates a CostReport for the half-open time range [from, to). The price recommendation
+// achieves the given margin in percent of the price.
+//
+// The subscription fee of a billing period is split evenly across the cups it has covered, each covered
+// cup in the time range adds its share to the revenue of its coffee.
+func (s *Service) Costs(from time.Time, to time.Time, margin float64) (*CostReport, error) {
+	if margin < 0 || margin >= 100 {
+		return nil, fmt.Errorf("%w: margin must be at least 0 and less than 100", ErrorInvalidProperty)
+	}
+	// receipts of the whole first billing period are needed to split its fees
+	issued, err := s.receipts.Issued(s.plans.PeriodStart(from))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cost report: %w", err)
+	}
+	fees, err := s.accounting.Fees(s.plans.Period(from), s.plans.Period(to.Add(-time.Nanosecond)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cost report: %w", err)
+	}
+	payments, err := s.accounting.Payments(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cost report: %w", err)
+	}
+	costPerGram, err := s.stock.CostPerGram(to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cost report: %w", err)
+	}
+	coffees, err := s.coffees.ListAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cost report: %w", err)
+	}
+
+	r := &CostReport{From: from, To: to, TargetMargin: margin}
+	for _, p := range payments {
+		if s.isMaintenance(p.Reason) {
+			r.Maintenance += p.Amount
+		}
+	}
+	feePerCup := s.feesPerCoveredCup(fees, issued)
+	for _, f := range fees {
+		if _, ok := feePerCup[feeKey(f.AccountID, f.Period)]; !ok {
+			r.Unallocated += f.Amount
+		}
+	}
+	perCoffee := make(map[string]*CoffeeCosts)
+	for _, c := range coffees {
+		perCoffee[c.AggregateID] = &CoffeeCosts{CoffeeID: c.AggregateID, Coffee: c.Type, Price: c.PriceAt(to)}
+	}
+	for _, receipt := range issued {
+		if receipt.OccurredOn.Before(from) || !receipt.OccurredOn.Before(to) {
+			continue
+		}
+		costs, ok := perCoffee[receipt.CoffeeID]
+		if !ok {
+			continue
+		}
+		costs.Cups += receipt.Quantity
+		costs.Beans += s.stock.Grams(receipt) * costPerGram[receipt.CoffeeID]
+		costs.Revenue += receipt.Amount + receipt.Subsidy
+		if receipt.Covered > 0 {
+			costs.Revenue += float64(receipt.Covered) * feePerCup[feeKey(receipt.AccountID, s.plans.Period(receipt.OccurredOn))]
+		}
+		r.Cups += receipt.Quantity
+	}
+
+	maintenancePerCup := 0.0
+	if r.Cups > 0 {
+		maintenancePerCup = r.Maintenance / float64(r.Cups)
+	}
+	r.Coffees = make([]CoffeeCosts, 0)
+	for _, costs := range perCoffee {
+		if costs.Cups == 0 {
+			continue
+		}
+		perGram, purchased := costPerGram[costs.CoffeeID]
+		costs.MissingPurchases = !purchased
+		costs.Maintenance = maintenancePerCup * float64(costs.Cups)
+		costs.Result = round(costs.Revenue - costs.Beans - costs.Maintenance)
+		costs.CostPerCup = round(s.stock.GramsPerCup()*perGram + maintenancePerCup)
+		costs.RecommendedPrice = recommendPrice(s.stock.GramsPerCup()*perGram+maintenancePerCup, margin)
+		r.Beans += costs.Beans
+		r.Revenue += costs.Revenue
+		costs.Beans, costs.Maintenance, costs.Revenue = round(costs.Beans), round(costs.Maintenance), round(costs.Revenue)
+		r.Coffees = append(r.Coffees, *costs)
+	}
+	r.Result = round(r.Revenue - r.Beans - r.Maintenance)
+	r.Beans, r.Maintenance, r.Revenue, r.Unallocated = round(r.Beans), round(r.Maintenance), round(r.Revenue), round(r.Unallocated)
+	sort.Slice(r.Coffees, func(i, j int) bool { return r.Coffees[i].Coffee < r.Coffees[j].Coffee })
+	return r, nil
+}
+
+// feesPerCoveredCup returns the share of each subscription fee per cup it has covered, by account and billing period.
+// Fees of billing periods without covered cups are left out.
+func (s *Service) feesPerCoveredCup(fees []account.SubscriptionFeeCharged, issued []consume.ReceiptIssued) map[string]float64 {
+	covered := make(map[string]int)
+	for _, receipt := range issued {
+		if receipt.Covered > 0 {
+			covered[feeKey(receipt.AccountID, s.plans.Period(receipt.OccurredOn))] += receipt.Covered
+		}
+	}
+	perCup := make(map[string]float64)
+	for _, f := range fees {
+		key := feeKey(f.AccountID, f.Period)
+		if cups := covered[key]; cups > 0 {
+			perCup[key] += f.Amount / float64(cups)
+		}
+	}
+	return perCup
+}
+
+func feeKey(accountID string, period string) string {
+	return accountID + ":" + period
+}
+
+// isMaintenance reports whether a payment has been made for maintenance, e.g. for descaling agent.
+func (s *Service) isMaintenance(reason string) bool {
+	reason = strings.ToLower(reason)
+	for _, keyword := range s.maintenanceKeywords {
+		if strings.Contains(reason, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// recommendPrice returns the price that achieves the margin in percent of the price for the given costs,
+// rounded up to full cents, so the margin is not missed.
+func recommendPrice(costs float64, margin float64) float64 {
+	price := costs / (1 - margin/100)
+	// round first, to avoid rounding up floating point artifacts to the next cent
+	return math.Ceil(math.Round(price*1e6)/1e4) / 100
+}
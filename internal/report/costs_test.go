@@ -0,0 +1,169 @@
+package report
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/beverage"
+	"coffy/internal/cashbox"
+	"coffy/internal/coffy"
+	"coffy/internal/consume"
+	"coffy/internal/equipment"
+	"coffy/internal/inventory"
+	"coffy/internal/pricing"
+	"coffy/internal/product"
+	"coffy/internal/quota"
+	"coffy/internal/storage/storagetest"
+	"coffy/internal/subscription"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecommendPrice(t *testing.T) {
+	for _, tt := range []struct {
+		costs    float64
+		margin   float64
+		expected float64
+	}{
+		{0.30, 0, 0.30},
+		{0.30, 25, 0.40},
+		{0.31, 10, 0.35},
+		{0.1, 50, 0.20},
+	} {
+		if price := recommendPrice(tt.costs, tt.margin); price != tt.expected {
+			t.Errorf("expected price %.2f for costs %.2f and margin %.0f%%, got %.2f", tt.expected, tt.costs, tt.margin, price)
+		}
+	}
+}
+
+func TestCosts(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	coffees := product.NewService(&repo)
+	plans := subscription.NewService(&repo, accounting, &coffy.BillingCfg{Day: 1, Hour: 6})
+	receipts := consume.NewService(&repo, accounting, coffees, beverage.NewService(&repo, coffees), equipment.NewService(&repo, coffees), pricing.NewEngine(), plans, quota.NewLimiter(), cashbox.NewService(&repo))
+	stock := inventory.NewService(&repo, coffees, receipts, &coffy.InventoryCfg{GramsPerCup: 10, RateDays: 14, LowStockDays: 7})
+	s := NewService(accounting, coffees, receipts, stock, plans, &coffy.CostsCfg{TargetMargin: 20, MaintenanceKeywords: []string{"descaling"}})
+
+	a, _ := accounting.Create("Coffy", "")
+	espresso, _ := coffees.Create("Espresso", 0.30, nil, nil)
+	lungo, _ := coffees.Create("Lungo", 0.40, nil, nil)
+	// 20 € per kg makes 0.20 € of beans per cup
+	if _, err := stock.Purchase(espresso.AggregateID, 1000, 20, time.Now().Add(-time.Hour), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := receipts.Consume(consume.Order{AccountID: a.ID(), CoffeeID: espresso.AggregateID, Quantity: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := receipts.Consume(consume.Order{AccountID: a.ID(), CoffeeID: lungo.AggregateID, Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounting.Pay(a.ID(), 2.00, "Descaling agent"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounting.Pay(a.ID(), 5.00, "top up"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.Costs(time.Now().Add(-time.Hour), time.Now().Add(time.Hour), s.TargetMargin())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Cups != 4 || r.Maintenance != 2.00 || r.Beans != 0.60 || r.Revenue != 1.30 || r.Result != -1.30 {
+		t.Errorf("unexpected totals %+v", r)
+	}
+	if len(r.Coffees) != 2 {
+		t.Fatalf("expected costs of 2 coffees, got %+v", r.Coffees)
+	}
+	e := r.Coffees[0]
+	if e.Coffee != "Espresso" || e.CostPerCup != 0.70 || e.RecommendedPrice != 0.88 || e.Maintenance != 1.50 || e.MissingPurchases {
+		t.Errorf("expected espresso at 0.70 per cup recommended for 0.88, got %+v", e)
+	}
+	if l := r.Coffees[1]; !l.MissingPurchases || l.CostPerCup != 0.50 {
+		t.Errorf("expected lungo without purchases at the maintenance costs per cup, got %+v", l)
+	}
+	if r.Unallocated != 0 {
+		t.Errorf("expected no unallocated fees, got %.2f", r.Unallocated)
+	}
+	if _, err := s.Costs(time.Now().Add(-time.Hour), time.Now(), 100); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("expected ErrorInvalidProperty for a margin of 100%%, got %v", err)
+	}
+}
+
+func TestCostsSubscriptionRevenue(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	coffees := product.NewService(&repo)
+	plans := subscription.NewService(&repo, accounting, &coffy.BillingCfg{Day: 1, Hour: 6})
+	receipts := consume.NewService(&repo, accounting, coffees, beverage.NewService(&repo, coffees), equipment.NewService(&repo, coffees), pricing.NewEngine(), plans, quota.NewLimiter(), cashbox.NewService(&repo))
+	stock := inventory.NewService(&repo, coffees, receipts, &coffy.InventoryCfg{GramsPerCup: 10, RateDays: 14, LowStockDays: 7})
+	s := NewService(accounting, coffees, receipts, stock, plans, &coffy.CostsCfg{})
+
+	subscriber, _ := accounting.Create("Coffy", "")
+	idle, _ := accounting.Create("Coffy Again", "")
+	espresso, _ := coffees.Create("Espresso", 0.30, nil, nil)
+	plan, _ := plans.Create("unlimited", 12, 0, 0)
+	for _, a := range []*account.Account{subscriber, idle} {
+		if _, err := plans.Subscribe(a.ID(), plan.AggregateID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	for _, a := range []*account.Account{subscriber, idle} {
+		if _, err := accounting.ChargeFee(a.ID(), plan.Fee(), plans.Period(now)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := receipts.Consume(consume.Order{AccountID: subscriber.ID(), CoffeeID: espresso.AggregateID, Quantity: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.Costs(now.Add(-time.Hour), now.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Coffees) != 1 || r.Coffees[0].Revenue != 12 {
+		t.Fatalf("expected the fee as revenue of the covered cups, got %+v", r.Coffees)
+	}
+	if r.Revenue != 12 || r.Unallocated != 12 {
+		t.Errorf("expected the fee of the idle subscriber to be unallocated, got %+v", r)
+	}
+}
+
+func TestCostsSubscriptionRevenueBillingDay(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	coffees := product.NewService(&repo)
+	plans := subscription.NewService(&repo, accounting, &coffy.BillingCfg{Day: 15, Hour: 6})
+	receipts := consume.NewService(&repo, accounting, coffees, beverage.NewService(&repo, coffees), equipment.NewService(&repo, coffees), pricing.NewEngine(), plans, quota.NewLimiter(), cashbox.NewService(&repo))
+	stock := inventory.NewService(&repo, coffees, receipts, &coffy.InventoryCfg{GramsPerCup: 10, RateDays: 14, LowStockDays: 7})
+	s := NewService(accounting, coffees, receipts, stock, plans, &coffy.CostsCfg{})
+
+	subscriber, _ := accounting.Create("Coffy", "")
+	espresso, _ := coffees.Create("Espresso", 0.30, nil, nil)
+	plan, _ := plans.Create("unlimited", 12, 0, 0)
+	if _, err := plans.Subscribe(subscriber.ID(), plan.AggregateID); err != nil {
+		t.Fatal(err)
+	}
+	// the previous billing period spans two calendar months, from the 15th to the 15th
+	end := plans.PeriodStart(time.Now())
+	start := end.AddDate(0, -1, 0)
+	if _, err := accounting.ChargeFee(subscriber.ID(), plan.Fee(), plans.Period(start)); err != nil {
+		t.Fatal(err)
+	}
+	for _, at := range []time.Time{start.Add(time.Hour), end.Add(-time.Hour)} {
+		if _, err := receipts.Consume(consume.Order{AccountID: subscriber.ID(), CoffeeID: espresso.AggregateID, Quantity: 1, Time: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := s.Costs(end.Add(-2*time.Hour), end, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Coffees) != 1 || r.Coffees[0].Revenue != 6 {
+		t.Fatalf("expected half of the fee as revenue of the last covered cup, got %+v", r.Coffees)
+	}
+	if r.Unallocated != 0 {
+		t.Errorf("expected the fee to be allocated, got %.2f", r.Unallocated)
+	}
+}
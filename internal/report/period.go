@@ -0,0 +1,41 @@
+package report
+
+import (
+	"fmt"
+	"time"
+)
+
+// Period is the granularity a report aggregates its values by.
+type Period string
+
+const (
+	Day   Period = "day"
+	Week  Period = "week"
+	Month Period = "month"
+)
+
+// ParsePeriod converts the textual representation of a Period. An empty value defaults to Month.
+func ParsePeriod(value string) (Period, error) {
+	switch p := Period(value); p {
+	case "":
+		return Month, nil
+	case Day, Week, Month:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown period '%s'", value)
+	}
+}
+
+// Key returns the label of the period the time point belongs to, e.g. '2025-01-17' for a Day,
+// '2025-W03' for an ISO Week or '2025-01' for a Month.
+func (p Period) Key(t time.Time) string {
+	switch p {
+	case Day:
+		return t.Format(time.DateOnly)
+	case Week:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	default:
+		return t.Format("2006-01")
+	}
+}
@@ -0,0 +1,136 @@
+package report
+
+import (
+	"coffy/internal/account"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AccountStats describes the consumption habits of an account in a time range.
+type AccountStats struct {
+	AccountID            string         `json:"account_id"`
+	From                 time.Time      `json:"from"`
+	To                   time.Time      `json:"to"`
+	Cups                 int            `json:"cups"`
+	Spent                float64        `json:"spent"`
+	FavouriteCoffee      string         `json:"favourite_coffee"`
+	AveragePerWorkingDay float64        `json:"average_per_working_day"`
+	PerCoffee            []UsageSummary `json:"per_coffee"`
+	PerDay               []UsageSummary `json:"per_day"`
+	PerWeek              []UsageSummary `json:"per_week"`
+	PerMonth             []UsageSummary `json:"per_month"`
+}
+
+// UsageSummary holds the number of cups and the money spent for a coffee type or a period.
+type UsageSummary struct {
+	Key   string  `json:"key"`
+	Cups  int     `json:"cups"`
+	Spent float64 `json:"spent"`
+}
+
+// Stats creates the AccountStats of an account for the half-open time range [from, to).
+func (s *Service) Stats(accountID string, from time.Time, to time.Time) (*AccountStats, error) {
+	consumptions, err := s.accounting.History(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account statistics: %w", err)
+	}
+	return Stats(accountID, consumptions, from, to), nil
+}
+
+// Stats aggregates the consumptions that occurred in the half-open time range [from, to).
+//
+// The average per working day considers all working days (Monday to Friday) from the first consumption
+// in the range until the end of the range, but not beyond today.
+func Stats(accountID string, consumptions []account.CoffyConsumed, from time.Time, to time.Time) *AccountStats {
+	stats := &AccountStats{AccountID: accountID, From: from, To: to}
+	perCoffee := newUsage()
+	perDay := newUsage()
+	perWeek := newUsage()
+	perMonth := newUsage()
+	var first time.Time
+	workdayCups := 0
+	for _, c := range consumptions {
+		t := c.Occurred()
+		if t.Before(from) || !t.Before(to) {
+			continue
+		}
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		stats.Cups++
+		stats.Spent = round(stats.Spent + c.Costs)
+		perCoffee.add(c.CoffyType, c.Costs)
+		perDay.add(Day.Key(t), c.Costs)
+		perWeek.add(Week.Key(t), c.Costs)
+		perMonth.add(Month.Key(t), c.Costs)
+		if isWorkingDay(t) {
+			workdayCups++
+		}
+	}
+	stats.PerCoffee = perCoffee.sorted()
+	stats.PerDay = perDay.sorted()
+	stats.PerWeek = perWeek.sorted()
+	stats.PerMonth = perMonth.sorted()
+
+	// the favourite is the coffee with the most cups, ties are broken alphabetically
+	favourite := UsageSummary{}
+	for _, u := range stats.PerCoffee {
+		if u.Cups > favourite.Cups {
+			favourite = u
+		}
+	}
+	stats.FavouriteCoffee = favourite.Key
+
+	end := to
+	if now := time.Now(); now.Before(end) {
+		end = now
+	}
+	if days := workingDays(first, end); days > 0 {
+		stats.AveragePerWorkingDay = round(float64(workdayCups) / float64(days))
+	}
+	return stats
+}
+
+type usage map[string]*UsageSummary
+
+func newUsage() usage {
+	return make(usage)
+}
+
+func (u usage) add(key string, spent float64) {
+	summary, ok := u[key]
+	if !ok {
+		summary = &UsageSummary{Key: key}
+		u[key] = summary
+	}
+	summary.Cups++
+	summary.Spent = round(summary.Spent + spent)
+}
+
+func (u usage) sorted() []UsageSummary {
+	list := make([]UsageSummary, 0, len(u))
+	for _, summary := range u {
+		list = append(list, *summary)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+func isWorkingDay(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// workingDays counts the working days from the day of the first time point until the day before the second.
+func workingDays(from time.Time, to time.Time) int {
+	if from.IsZero() {
+		return 0
+	}
+	days := 0
+	for d := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location()); d.Before(to); d = d.AddDate(0, 0, 1) {
+		if isWorkingDay(d) {
+			days++
+		}
+	}
+	return days
+}
@@ -0,0 +1,47 @@
+package report
+
+import (
+	"coffy/internal/account"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	// Monday until Sunday
+	from := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)
+	espresso := func(day int) account.CoffyConsumed {
+		c := consumed(time.Date(2025, 1, day, 9, 0, 0, 0, time.UTC), 0.50, 0)
+		return c
+	}
+	latte := consumed(time.Date(2025, 1, 14, 10, 0, 0, 0, time.UTC), 0.80, 0)
+	latte.CoffyType = "latte"
+	consumptions := []account.CoffyConsumed{
+		espresso(13), espresso(13), latte, espresso(15),
+		// weekend
+		espresso(18),
+		// outside the range
+		espresso(20),
+	}
+
+	stats := Stats("123", consumptions, from, to)
+	if stats.Cups != 5 {
+		t.Errorf("expected 5 cups, got %d", stats.Cups)
+	}
+	if stats.Spent != 2.80 {
+		t.Errorf("expected 2.80 spent, got %.2f", stats.Spent)
+	}
+	if stats.FavouriteCoffee != "espresso" {
+		t.Errorf("expected espresso as favourite, got %s", stats.FavouriteCoffee)
+	}
+	// 4 cups on 5 working days
+	if stats.AveragePerWorkingDay != 0.8 {
+		t.Errorf("expected 0.8 cups per working day, got %.2f", stats.AveragePerWorkingDay)
+	}
+	if len(stats.PerDay) != 4 {
+		t.Errorf("expected 4 days with consumptions, got %d", len(stats.PerDay))
+	}
+	if len(stats.PerWeek) != 1 || stats.PerWeek[0].Key != "2025-W03" {
+		t.Errorf("expected a single week 2025-W03, got %+v", stats.PerWeek)
+	}
+}
@@ -0,0 +1,111 @@
+package report
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/coffy"
+	"coffy/internal/consume"
+	"coffy/internal/inventory"
+	"coffy/internal/product"
+	"coffy/internal/subscription"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// SubsidyReport summarises the charged and subsidised amounts of all consumptions in a time range.
+type SubsidyReport struct {
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	Period  Period           `json:"period"`
+	Total   SubsidySummary   `json:"total"`
+	Periods []SubsidySummary `json:"periods"`
+}
+
+// SubsidySummary holds the aggregated values of a single period.
+type SubsidySummary struct {
+	Period  string  `json:"period"`
+	Cups    int     `json:"cups"`
+	Charged float64 `json:"charged"`
+	Subsidy float64 `json:"subsidy"`
+}
+
+func (s *SubsidySummary) add(c account.CoffyConsumed) {
+	s.Cups++
+	s.Charged = round(s.Charged + c.Costs)
+	s.Subsidy = round(s.Subsidy + c.Subsidy)
+}
+
+func (s *SubsidySummary) addSale(r consume.ReceiptIssued) {
+	s.Cups += r.Quantity
+	s.Charged = round(s.Charged + r.Amount)
+	s.Subsidy = round(s.Subsidy + r.Subsidy)
+}
+
+var ErrorInvalidProperty = errors.New("invalid property")
+
+type Service struct {
+	accounting          *account.Accounting
+	coffees             *product.Service
+	receipts            *consume.Service
+	stock               *inventory.Service
+	plans               *subscription.Service
+	targetMargin        float64
+	maintenanceKeywords []string
+}
+
+func NewService(accounting *account.Accounting, coffees *product.Service, receipts *consume.Service, stock *inventory.Service, plans *subscription.Service, cfg *coffy.CostsCfg) *Service {
+	return &Service{accounting: accounting, coffees: coffees, receipts: receipts, stock: stock, plans: plans, targetMargin: cfg.TargetMargin, maintenanceKeywords: cfg.MaintenanceKeywords}
+}
+
+// Subsidies creates a SubsidyReport for the half-open time range [from, to), including the cash sales to guests.
+func (s *Service) Subsidies(from time.Time, to time.Time, period Period) (*SubsidyReport, error) {
+	consumptions, err := s.accounting.Consumptions(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subsidy report: %w", err)
+	}
+	issued, err := s.receipts.Issued(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subsidy report: %w", err)
+	}
+	sales := make([]consume.ReceiptIssued, 0)
+	for _, receipt := range issued {
+		if receipt.Payment == consume.PaymentCash && receipt.OccurredOn.Before(to) {
+			sales = append(sales, receipt)
+		}
+	}
+	return Subsidies(consumptions, sales, from, to, period), nil
+}
+
+// Subsidies aggregates the consumptions and cash sales per period. Periods without any consumption are omitted.
+func Subsidies(consumptions []account.CoffyConsumed, sales []consume.ReceiptIssued, from time.Time, to time.Time, period Period) *SubsidyReport {
+	r := &SubsidyReport{From: from, To: to, Period: period, Total: SubsidySummary{Period: "total"}}
+	periods := make(map[string]*SubsidySummary)
+	summary := func(t time.Time) *SubsidySummary {
+		key := period.Key(t)
+		if _, ok := periods[key]; !ok {
+			periods[key] = &SubsidySummary{Period: key}
+		}
+		return periods[key]
+	}
+	for _, c := range consumptions {
+		summary(c.Occurred()).add(c)
+		r.Total.add(c)
+	}
+	for _, sale := range sales {
+		summary(sale.OccurredOn).addSale(sale)
+		r.Total.addSale(sale)
+	}
+	r.Periods = make([]SubsidySummary, 0, len(periods))
+	for _, summary := range periods {
+		r.Periods = append(r.Periods, *summary)
+	}
+	sort.Slice(r.Periods, func(i, j int) bool { return r.Periods[i].Period < r.Periods[j].Period })
+	return r
+}
+
+// round rounds an amount to full cents, to avoid floating point artifacts in sums.
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
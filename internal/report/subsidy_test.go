@@ -0,0 +1,52 @@
+package report
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/consume"
+	"testing"
+	"time"
+)
+
+func consumed(occurred time.Time, costs float64, subsidy float64) account.CoffyConsumed {
+	c := account.NewCoffyConsumed("123", "espresso", costs)
+	c.OccurredOn = occurred
+	c.Subsidy = subsidy
+	return *c
+}
+
+func TestSubsidies(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	consumptions := []account.CoffyConsumed{
+		consumed(time.Date(2025, 2, 3, 9, 0, 0, 0, time.UTC), 0.25, 0.25),
+		consumed(time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC), 0.50, 0),
+		consumed(time.Date(2025, 1, 11, 9, 0, 0, 0, time.UTC), 0.10, 0.40),
+	}
+	sales := []consume.ReceiptIssued{
+		{OccurredOn: time.Date(2025, 1, 12, 9, 0, 0, 0, time.UTC), Payment: consume.PaymentCash, Quantity: 2, Amount: 0.60, Subsidy: 0.40},
+	}
+	r := Subsidies(consumptions, sales, from, to, Month)
+	if len(r.Periods) != 2 {
+		t.Errorf("expected 2 periods, got %d", len(r.Periods))
+		return
+	}
+	if r.Periods[0].Period != "2025-01" || r.Periods[0].Subsidy != 0.80 || r.Periods[0].Cups != 4 {
+		t.Errorf("unexpected summary for January: %+v", r.Periods[0])
+	}
+	if r.Total.Subsidy != 1.05 || r.Total.Charged != 1.45 {
+		t.Errorf("unexpected total: %+v", r.Total)
+	}
+}
+
+func TestPeriodKey(t *testing.T) {
+	day := time.Date(2025, 1, 17, 9, 0, 0, 0, time.UTC)
+	if key := Week.Key(day); key != "2025-W03" {
+		t.Errorf("expected week 2025-W03, got %s", key)
+	}
+	if key := Day.Key(day); key != "2025-01-17" {
+		t.Errorf("expected day 2025-01-17, got %s", key)
+	}
+	if _, err := ParsePeriod("year"); err == nil {
+		t.Errorf("expected error for unknown period")
+	}
+}
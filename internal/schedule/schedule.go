@@ -0,0 +1,64 @@
+package schedule
+
+import (
+	"time"
+)
+
+// A Plan calculates the next time point a job is due, strictly after the provided time.
+type Plan func(after time.Time) time.Time
+
+// Daily returns a Plan that is due every day at the full hour.
+func Daily(hour int) Plan {
+	return func(after time.Time) time.Time {
+		next := time.Date(after.Year(), after.Month(), after.Day(), hour, 0, 0, 0, after.Location())
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	}
+}
+
+// Weekly returns a Plan that is due every week on the given weekday at the full hour.
+func Weekly(day time.Weekday, hour int) Plan {
+	return func(after time.Time) time.Time {
+		next := time.Date(after.Year(), after.Month(), after.Day(), hour, 0, 0, 0, after.Location())
+		next = next.AddDate(0, 0, (int(day)-int(next.Weekday())+7)%7)
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	}
+}
+
+// Monthly returns a Plan that is due every month on the given day of the month at the full hour.
+//
+// The day must be between 1 and 28, so it exists in every month.
+func Monthly(day int, hour int) Plan {
+	return func(after time.Time) time.Time {
+		next := time.Date(after.Year(), after.Month(), day, hour, 0, 0, 0, after.Location())
+		if !next.After(after) {
+			next = next.AddDate(0, 1, 0)
+		}
+		return next
+	}
+}
+
+// Start runs the job in the background every time the Plan is due.
+//
+// The returned function stops the scheduling, a job that is currently running will be completed.
+func Start(plan Plan, job func()) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(plan(time.Now())))
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-timer.C:
+				job()
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,57 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeekly(t *testing.T) {
+	plan := Weekly(time.Monday, 9)
+	// a Wednesday
+	after := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	expected := time.Date(2025, 1, 20, 9, 0, 0, 0, time.UTC)
+	if next := plan(after); !next.Equal(expected) {
+		t.Errorf("expected next run at %v, got %v", expected, next)
+	}
+}
+
+func TestWeeklySameDay(t *testing.T) {
+	plan := Weekly(time.Monday, 9)
+	before := time.Date(2025, 1, 20, 8, 0, 0, 0, time.UTC)
+	expected := time.Date(2025, 1, 20, 9, 0, 0, 0, time.UTC)
+	if next := plan(before); !next.Equal(expected) {
+		t.Errorf("expected next run at %v, got %v", expected, next)
+	}
+	// exactly at the due time, the next week is planned
+	expected = time.Date(2025, 1, 27, 9, 0, 0, 0, time.UTC)
+	if next := plan(time.Date(2025, 1, 20, 9, 0, 0, 0, time.UTC)); !next.Equal(expected) {
+		t.Errorf("expected next run at %v, got %v", expected, next)
+	}
+}
+
+func TestMonthly(t *testing.T) {
+	plan := Monthly(1, 6)
+	after := time.Date(2025, 12, 15, 12, 0, 0, 0, time.UTC)
+	expected := time.Date(2026, 1, 1, 6, 0, 0, 0, time.UTC)
+	if next := plan(after); !next.Equal(expected) {
+		t.Errorf("expected next run at %v, got %v", expected, next)
+	}
+	before := time.Date(2025, 12, 1, 5, 0, 0, 0, time.UTC)
+	expected = time.Date(2025, 12, 1, 6, 0, 0, 0, time.UTC)
+	if next := plan(before); !next.Equal(expected) {
+		t.Errorf("expected next run at %v, got %v", expected, next)
+	}
+}
+
+func TestDaily(t *testing.T) {
+	plan := Daily(8)
+	before := time.Date(2025, 1, 15, 7, 0, 0, 0, time.UTC)
+	expected := time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC)
+	if next := plan(before); !next.Equal(expected) {
+		t.Errorf("expected next run at %v, got %v", expected, next)
+	}
+	expected = time.Date(2025, 1, 16, 8, 0, 0, 0, time.UTC)
+	if next := plan(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC)); !next.Equal(expected) {
+		t.Errorf("expected next run at %v, got %v", expected, next)
+	}
+}
@@ -12,6 +12,8 @@ type EventRepository interface {
 	SaveAll([]EventEntry) error
 	LoadAll(aggregateID string) ([]EventEntry, error)
 	FetchByEventType(event string) ([]EventEntry, error)
+	// FetchSince returns all entries saved after the entry with the given ID, in the order of saving.
+	FetchSince(id int) ([]EventEntry, error)
 }
 
 type EventEntry struct {
@@ -32,7 +34,9 @@ func (r *eventRepositoryImpl) SaveAll(events []EventEntry) (e error) {
 			e = errors.New("saving events failed")
 		}
 	}()
-	r.db.Create(events)
+	if result := r.db.Create(events); result.Error != nil {
+		return fmt.Errorf("error saving events: %w", result.Error)
+	}
 	return nil
 }
 
@@ -54,6 +58,15 @@ func (r *eventRepositoryImpl) FetchByEventType(t string) ([]EventEntry, error) {
 	return events, nil
 }
 
+func (r *eventRepositoryImpl) FetchSince(id int) ([]EventEntry, error) {
+	events := make([]EventEntry, 0)
+	result := r.db.Where("id > ?", id).Order("id").Find(&events)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error fetching events: %w", result.Error)
+	}
+	return events, nil
+}
+
 func CreateEventRepository(storage string) (EventRepository, error) {
 	db, err := gorm.Open(sqlite.Open(storage), &gorm.Config{})
 	if err != nil {
@@ -0,0 +1,48 @@
+// Package storagetest provides an in-memory event repository for tests.
+package storagetest
+
+import (
+	"slices"
+	"sync"
+
+	"coffy/internal/storage"
+)
+
+// memoryRepository keeps events in memory, in the order of saving.
+type memoryRepository struct {
+	mu      sync.Mutex
+	entries []storage.EventEntry
+}
+
+// NewMemoryRepository creates an empty in-memory event repository.
+func NewMemoryRepository() storage.EventRepository {
+	return &memoryRepository{}
+}
+
+func (r *memoryRepository) SaveAll(entries []storage.EventEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range entries {
+		e.ID = len(r.entries) + 1
+		r.entries = append(r.entries, e)
+	}
+	return nil
+}
+
+func (r *memoryRepository) LoadAll(aggregateID string) ([]storage.EventEntry, error) {
+	return r.filter(func(e storage.EventEntry) bool { return e.AggregateID == aggregateID }), nil
+}
+
+func (r *memoryRepository) FetchByEventType(t string) ([]storage.EventEntry, error) {
+	return r.filter(func(e storage.EventEntry) bool { return e.EventType == t }), nil
+}
+
+func (r *memoryRepository) FetchSince(id int) ([]storage.EventEntry, error) {
+	return r.filter(func(e storage.EventEntry) bool { return e.ID > id }), nil
+}
+
+func (r *memoryRepository) filter(keep func(storage.EventEntry) bool) []storage.EventEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.DeleteFunc(slices.Clone(r.entries), func(e storage.EventEntry) bool { return !keep(e) })
+}
@@ -0,0 +1,112 @@
+package subscription
+
+import (
+	"coffy/internal/event"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// Plan is a flat-rate coffee subscription with a monthly fee.
+//
+// A plan covers either an unlimited number of cups per month or up to a cup cap. Cups beyond the cap are
+// charged with the overage price or, if no overage price is set, with the regular price of the coffee.
+type Plan struct {
+	AggregateID  string        // the plan's unique ID in coffy
+	Name         string        // a descriptive name, e.g. 'heavy drinker'
+	fee          float64       // the monthly fee in €
+	cupCap       int           // the number of cups covered per month, zero means unlimited
+	overagePrice float64       // the price per cup beyond the cap, zero means regular price
+	events       []event.Event // uncommitted events of the aggregate
+}
+
+func NewPlan(name string, fee float64, cupCap int, overagePrice float64) (*Plan, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, errors.New("plan name cannot be empty")
+	}
+	if fee <= 0 {
+		return nil, errors.New("monthly fee must be greater than zero")
+	}
+	if cupCap < 0 {
+		return nil, errors.New("cup cap cannot be negative")
+	}
+	if overagePrice < 0 {
+		return nil, errors.New("overage price cannot be negative")
+	}
+	p := &Plan{}
+	e := PlanCreated{ID: uuid.NewString(), Name: strings.TrimSpace(name), Fee: fee, CupCap: cupCap, OveragePrice: overagePrice, OccurredOn: time.Now()}
+	if err := p.apply(e); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Fee returns the monthly fee of the plan.
+func (p *Plan) Fee() float64 {
+	return p.fee
+}
+
+// CupCap returns the number of cups covered per month and false, if the plan covers an unlimited number of cups.
+func (p *Plan) CupCap() (int, bool) {
+	return p.cupCap, p.cupCap > 0
+}
+
+// OveragePrice returns the price per cup beyond the cap and false, if the regular price applies.
+func (p *Plan) OveragePrice() (float64, bool) {
+	return p.overagePrice, p.overagePrice > 0
+}
+
+// Covers reports whether the next cup is covered by the plan, given the number of cups already covered
+// in the current billing period.
+func (p *Plan) Covers(covered int) bool {
+	return p.cupCap == 0 || covered < p.cupCap
+}
+
+// Events returns all uncommitted events of the current plan aggregate
+func (p *Plan) Events() []event.Event {
+	return p.events
+}
+
+// Clear empties the current event cache of the plan and removes all previously appended events.
+func (p *Plan) Clear() {
+	p.events = []event.Event{}
+}
+
+func (p *Plan) apply(e event.Event) error {
+	switch theEvent := e.(type) {
+	case PlanCreated:
+		p.AggregateID = theEvent.ID
+		p.Name = theEvent.Name
+		p.fee = theEvent.Fee
+		p.cupCap = theEvent.CupCap
+		p.overagePrice = theEvent.OveragePrice
+		p.events = append(p.events, theEvent)
+		return nil
+	default:
+		return fmt.Errorf("unknown event type '%T'", theEvent)
+	}
+}
+
+// PlanCreated records the creation of a subscription plan.
+type PlanCreated struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Fee          float64   `json:"fee"`
+	CupCap       int       `json:"cupCap"`
+	OveragePrice float64   `json:"overagePrice"`
+	OccurredOn   time.Time `json:"occurredOn"`
+}
+
+func (e PlanCreated) AggregateID() string {
+	return e.ID
+}
+
+func (e PlanCreated) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e PlanCreated) Type() string {
+	return "PlanCreated"
+}
@@ -0,0 +1,49 @@
+package subscription
+
+import (
+	"testing"
+)
+
+func TestNewPlan(t *testing.T) {
+	p, err := NewPlan("heavy drinker", 20, 60, 0.30)
+	if err != nil {
+		t.Errorf("NewPlan() error = %v", err)
+		return
+	}
+	if len(p.Events()) != 1 {
+		t.Errorf("expected 1 event, got %d", len(p.Events()))
+		return
+	}
+	if _, ok := p.Events()[0].(PlanCreated); !ok {
+		t.Errorf("expected PlanCreated event, got %T", p.Events()[0])
+	}
+	if !p.Covers(59) {
+		t.Errorf("expected cup 60 to be covered")
+	}
+	if p.Covers(60) {
+		t.Errorf("expected cup 61 not to be covered")
+	}
+}
+
+func TestNewPlanUnlimited(t *testing.T) {
+	p, err := NewPlan("unlimited", 30, 0, 0)
+	if err != nil {
+		t.Errorf("NewPlan() error = %v", err)
+		return
+	}
+	if _, capped := p.CupCap(); capped {
+		t.Errorf("expected unlimited plan")
+	}
+	if !p.Covers(1000) {
+		t.Errorf("expected every cup to be covered")
+	}
+}
+
+func TestNewPlanInvalid(t *testing.T) {
+	if _, err := NewPlan("free", 0, 0, 0); err == nil {
+		t.Errorf("expected error for plan without fee")
+	}
+	if _, err := NewPlan("", 20, 0, 0); err == nil {
+		t.Errorf("expected error for plan without name")
+	}
+}
@@ -0,0 +1,179 @@
+package subscription
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/coffy"
+	"coffy/internal/event"
+	"coffy/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var ErrorNotFound = errors.New("plan not found")
+
+type Service struct {
+	repo       storage.EventRepository
+	accounting *account.Accounting
+	billing    *coffy.BillingCfg
+}
+
+func NewService(repo *storage.EventRepository, accounting *account.Accounting, billing *coffy.BillingCfg) *Service {
+	return &Service{repo: *repo, accounting: accounting, billing: billing}
+}
+
+func (s *Service) Create(name string, fee float64, cupCap int, overagePrice float64) (*Plan, error) {
+	p, err := NewPlan(name, fee, cupCap, overagePrice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plan: %w", err)
+	}
+	entries := make([]storage.EventEntry, 0)
+	for _, e := range p.Events() {
+		entry, err := toEventEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := s.repo.SaveAll(entries); err != nil {
+		return nil, fmt.Errorf("failed to save plan: %w", err)
+	}
+	p.Clear()
+	return p, nil
+}
+
+func (s *Service) ListAll() ([]Plan, error) {
+	query, err := s.repo.FetchByEventType("PlanCreated")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plans: %w", err)
+	}
+	plans := make([]Plan, 0)
+	for _, entry := range query {
+		p, err := s.Find(entry.AggregateID)
+		if err != nil {
+			return nil, errors.Join(errors.New("failed to load plans"), err)
+		}
+		plans = append(plans, *p)
+	}
+	return plans, nil
+}
+
+func (s *Service) Find(planID string) (*Plan, error) {
+	entries, err := s.repo.LoadAll(planID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plan '%s': %w", planID, err)
+	}
+	if len(entries) == 0 {
+		return nil, ErrorNotFound
+	}
+	p := &Plan{}
+	for _, entry := range entries {
+		e, err := convert(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load plan '%s': %w", planID, err)
+		}
+		if err := p.apply(e); err != nil {
+			log.Println(err)
+			return nil, fmt.Errorf("failed to load plan '%s'", planID)
+		}
+	}
+	p.Clear()
+	return p, nil
+}
+
+// Subscribe subscribes an account to an existing plan.
+func (s *Service) Subscribe(accountID string, planID string) (*account.Account, error) {
+	if _, err := s.Find(planID); err != nil {
+		return nil, err
+	}
+	return s.accounting.Subscribe(accountID, planID)
+}
+
+// Unsubscribe ends the subscription of an account.
+func (s *Service) Unsubscribe(accountID string) (*account.Account, error) {
+	return s.accounting.Unsubscribe(accountID)
+}
+
+// BillingInstant returns the latest billing instant (the given day of the month at the given hour) that is
+// not after the time point t.
+func BillingInstant(t time.Time, day int, hour int) time.Time {
+	due := time.Date(t.Year(), t.Month(), day, hour, 0, 0, 0, t.Location())
+	if due.After(t) {
+		due = due.AddDate(0, -1, 0)
+	}
+	return due
+}
+
+// PeriodStart returns the billing instant that starts the billing period of the time point t.
+func (s *Service) PeriodStart(t time.Time) time.Time {
+	return BillingInstant(t, s.billing.Day, s.billing.Hour)
+}
+
+// Period returns the name of the billing period of the time point t. Cups are covered by a plan only
+// in billing periods whose fee has been charged.
+func (s *Service) Period(t time.Time) string {
+	return account.BillingPeriod(s.PeriodStart(t))
+}
+
+// ChargeFees charges the monthly fee to all subscribed accounts for every billing period that started
+// after the subscription and up to the time point now and has not been billed yet.
+//
+// Subscribers who join within a period are first charged at the next billing instant, periods missed while
+// the server was not running are caught up. It is safe to call the function multiple times per period.
+func (s *Service) ChargeFees(now time.Time) error {
+	accounts, err := s.accounting.ListAll()
+	if err != nil {
+		return fmt.Errorf("failed to charge subscription fees: %w", err)
+	}
+	var errs []error
+	for _, a := range accounts {
+		sub, ok := a.Subscription()
+		if !ok || a.Billed(s.Period(now)) {
+			continue
+		}
+		p, err := s.Find(sub.PlanID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for due := s.PeriodStart(sub.Since).AddDate(0, 1, 0); !due.After(now); due = due.AddDate(0, 1, 0) {
+			period := account.BillingPeriod(due)
+			if a.Billed(period) {
+				continue
+			}
+			if _, err := s.accounting.ChargeFee(a.ID(), p.Fee(), period); err != nil {
+				errs = append(errs, fmt.Errorf("failed to charge fee to account '%s': %w", a.ID(), err))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func convert(entry storage.EventEntry) (event.Event, error) {
+	switch entry.EventType {
+	case "PlanCreated":
+		evnt := PlanCreated{}
+		if err := json.Unmarshal(entry.EventData, &evnt); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as PlanCreated: %w", err)
+		}
+		return evnt, nil
+	default:
+		return nil, fmt.Errorf("unknown event type: %s", entry.EventType)
+	}
+}
+
+func toEventEntry(e event.Event) (storage.EventEntry, error) {
+	switch t := e.(type) {
+	case PlanCreated:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: e.AggregateID(), EventType: e.Type(), Date: e.Occurred(), EventData: data}, nil
+	default:
+		return storage.EventEntry{}, fmt.Errorf("failed to convert event to entry: unknown event type '%T'", t)
+	}
+}
@@ -0,0 +1,77 @@
+package subscription
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/coffy"
+	"coffy/internal/storage/storagetest"
+	"testing"
+	"time"
+)
+
+func TestBillingInstant(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	if due := BillingInstant(now, 1, 6); !due.Equal(time.Date(2025, 3, 1, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected billing instant on March 1st, got %s", due)
+	}
+	if due := BillingInstant(now, 15, 6); !due.Equal(time.Date(2025, 2, 15, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected billing instant on February 15th, got %s", due)
+	}
+}
+
+func TestChargeFeesAfterSubscription(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	service := NewService(&repo, accounting, &coffy.BillingCfg{Day: 1, Hour: 0})
+	p, _ := service.Create("heavy drinker", 20, 0, 0)
+	acc, _ := accounting.Create("Coffy", "")
+	if _, err := service.Subscribe(acc.ID(), p.AggregateID); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	// the billing instant of the current period has passed before the subscription started
+	due := BillingInstant(time.Now(), 1, 0)
+	if err := service.ChargeFees(due); err != nil {
+		t.Fatalf("ChargeFees() error = %v", err)
+	}
+	acc, _ = accounting.Find(acc.ID())
+	if acc.Balance() != 0 {
+		t.Errorf("expected no fee before the next billing instant, got balance %.2f", acc.Balance())
+	}
+
+	next := due.AddDate(0, 1, 0)
+	for range 2 {
+		if err := service.ChargeFees(next); err != nil {
+			t.Fatalf("ChargeFees() error = %v", err)
+		}
+	}
+	acc, _ = accounting.Find(acc.ID())
+	if acc.Balance() != -20 {
+		t.Errorf("expected a single fee at the next billing instant, got balance %.2f", acc.Balance())
+	}
+}
+
+func TestChargeFeesCatchesUp(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	service := NewService(&repo, accounting, &coffy.BillingCfg{Day: 15, Hour: 6})
+	p, _ := service.Create("heavy drinker", 20, 0, 0)
+	acc, _ := accounting.Create("Coffy", "")
+	if _, err := service.Subscribe(acc.ID(), p.AggregateID); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	// three billing instants have passed while the server was not running
+	now := service.PeriodStart(time.Now()).AddDate(0, 3, 0)
+	for range 2 {
+		if err := service.ChargeFees(now); err != nil {
+			t.Fatalf("ChargeFees() error = %v", err)
+		}
+	}
+	acc, _ = accounting.Find(acc.ID())
+	if acc.Balance() != -60 {
+		t.Errorf("expected a fee for each missed billing period, got balance %.2f", acc.Balance())
+	}
+	if !acc.Billed(service.Period(now)) {
+		t.Errorf("expected the current period %s to be billed", service.Period(now))
+	}
+}
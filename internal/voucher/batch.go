@@ -0,0 +1,263 @@
+package voucher
+
+import (
+	"coffy/internal/event"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Characters used for voucher codes. Easily confused characters like 0/O and 1/I are omitted,
+// since the codes are typed in by hand from a printed card.
+const codeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// MaxBatchSize is the maximum number of vouchers issued in a single batch.
+const MaxBatchSize = 1000
+
+var ErrorUnknownCode = errors.New("unknown voucher code")
+var ErrorAlreadyRedeemed = errors.New("voucher already redeemed")
+var ErrorExpired = errors.New("voucher expired")
+var ErrorInvalidProperty = errors.New("invalid property")
+
+// Batch is a set of one-time voucher codes with the same value, e.g. prepaid coffee cards that are
+// sold at the reception.
+type Batch struct {
+	AggregateID string              // the batch's unique ID in coffy
+	value       float64             // the value of every voucher in €
+	expiresOn   time.Time           // vouchers cannot be redeemed after this time point, zero means never
+	vouchers    map[string]*Voucher // all vouchers of the batch by their code
+	codes       []string            // the codes in order of issue
+	events      []event.Event       // uncommitted events of the aggregate
+}
+
+// Voucher is a single one-time code of a Batch.
+type Voucher struct {
+	Code       string
+	RedeemedBy string    // the account ID the voucher has been redeemed for, empty if not redeemed
+	RedeemedOn time.Time // the time point of redemption
+}
+
+func (v Voucher) Redeemed() bool {
+	return v.RedeemedBy != ""
+}
+
+// NewBatch issues n new vouchers with the given value.
+//
+// The value must be greater than zero and between one and MaxBatchSize vouchers must be issued. A zero expiry
+// time point means that the vouchers never expire.
+func NewBatch(value float64, n int, expiresOn time.Time) (*Batch, error) {
+	if value <= 0 {
+		return nil, fmt.Errorf("%w: voucher value must be greater than zero", ErrorInvalidProperty)
+	}
+	if n < 1 || n > MaxBatchSize {
+		return nil, fmt.Errorf("%w: between 1 and %d vouchers must be issued", ErrorInvalidProperty, MaxBatchSize)
+	}
+	codes := make([]string, 0, n)
+	seen := make(map[string]bool)
+	for len(codes) < n {
+		code, err := newCode()
+		if err != nil {
+			return nil, err
+		}
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	b := &Batch{}
+	e := BatchIssued{ID: uuid.NewString(), Value: value, Codes: codes, ExpiresOn: expiresOn, OccurredOn: time.Now()}
+	if err := b.apply(e); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func newCode() (string, error) {
+	var b strings.Builder
+	for i := range 12 {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate voucher code: %w", err)
+		}
+		b.WriteByte(codeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// NormalizeCode converts a code as typed in by a user into its canonical form.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Redeem marks the voucher with the given code as redeemed for an account.
+//
+// A voucher can only be redeemed once and not after the batch has expired.
+func (b *Batch) Redeem(code string, accountID string, now time.Time) error {
+	v, ok := b.vouchers[NormalizeCode(code)]
+	if !ok {
+		return ErrorUnknownCode
+	}
+	if v.Redeemed() {
+		return ErrorAlreadyRedeemed
+	}
+	if b.expired(now) {
+		return ErrorExpired
+	}
+	e := VoucherRedeemed{BatchID: b.AggregateID, Code: v.Code, AccountID: accountID, OccurredOn: now}
+	if err := b.apply(e); err != nil {
+		return errors.Join(fmt.Errorf("could not redeem voucher of batch %s", b.AggregateID), err)
+	}
+	return nil
+}
+
+// Contains reports whether the code belongs to the current batch.
+func (b *Batch) Contains(code string) bool {
+	_, ok := b.vouchers[NormalizeCode(code)]
+	return ok
+}
+
+func (b *Batch) Value() float64 {
+	return b.value
+}
+
+// ExpiresOn returns the expiry time point of the batch and false, if the batch never expires.
+func (b *Batch) ExpiresOn() (time.Time, bool) {
+	return b.expiresOn, !b.expiresOn.IsZero()
+}
+
+// Vouchers returns all vouchers of the batch in order of issue.
+func (b *Batch) Vouchers() []Voucher {
+	vouchers := make([]Voucher, 0, len(b.codes))
+	for _, code := range b.codes {
+		vouchers = append(vouchers, *b.vouchers[code])
+	}
+	return vouchers
+}
+
+// Issued returns the number of vouchers issued with the batch.
+func (b *Batch) Issued() int {
+	return len(b.codes)
+}
+
+// Redeemed returns the number of vouchers that have been redeemed.
+func (b *Batch) Redeemed() int {
+	redeemed := 0
+	for _, v := range b.vouchers {
+		if v.Redeemed() {
+			redeemed++
+		}
+	}
+	return redeemed
+}
+
+// Expired returns the number of vouchers that have not been redeemed before the batch expired.
+func (b *Batch) Expired(now time.Time) int {
+	if !b.expired(now) {
+		return 0
+	}
+	return b.Issued() - b.Redeemed()
+}
+
+func (b *Batch) expired(now time.Time) bool {
+	return !b.expiresOn.IsZero() && now.After(b.expiresOn)
+}
+
+// Events returns all uncommitted events of the current batch aggregate
+func (b *Batch) Events() []event.Event {
+	return b.events
+}
+
+// Clear empties the current event cache of the batch and removes all previously appended events.
+func (b *Batch) Clear() {
+	b.events = []event.Event{}
+}
+
+func (b *Batch) apply(e event.Event) error {
+	switch theEvent := e.(type) {
+	case BatchIssued:
+		return b.applyIssued(theEvent)
+	case VoucherRedeemed:
+		return b.applyRedeemed(theEvent)
+	default:
+		return fmt.Errorf("unknown event type '%T'", theEvent)
+	}
+}
+
+func (b *Batch) applyIssued(e BatchIssued) error {
+	b.AggregateID = e.ID
+	b.value = e.Value
+	b.expiresOn = e.ExpiresOn
+	b.codes = e.Codes
+	b.vouchers = make(map[string]*Voucher)
+	for _, code := range e.Codes {
+		b.vouchers[code] = &Voucher{Code: code}
+	}
+	b.events = append(b.events, e)
+	return nil
+}
+
+func (b *Batch) applyRedeemed(e VoucherRedeemed) error {
+	if e.BatchID != b.AggregateID {
+		return fmt.Errorf("event does not belong to this aggregate")
+	}
+	v, ok := b.vouchers[e.Code]
+	if !ok {
+		return ErrorUnknownCode
+	}
+	if v.Redeemed() {
+		return ErrorAlreadyRedeemed
+	}
+	v.RedeemedBy = e.AccountID
+	v.RedeemedOn = e.OccurredOn
+	b.events = append(b.events, e)
+	return nil
+}
+
+// BatchIssued records the generation of a batch of voucher codes.
+type BatchIssued struct {
+	ID         string    `json:"id"`
+	Value      float64   `json:"value"`
+	Codes      []string  `json:"codes"`
+	ExpiresOn  time.Time `json:"expiresOn"`
+	OccurredOn time.Time `json:"occurredOn"`
+}
+
+func (e BatchIssued) AggregateID() string {
+	return e.ID
+}
+
+func (e BatchIssued) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e BatchIssued) Type() string {
+	return "BatchIssued"
+}
+
+// VoucherRedeemed records the one-time redemption of a voucher code for an account.
+type VoucherRedeemed struct {
+	BatchID    string    `json:"id"`
+	Code       string    `json:"code"`
+	AccountID  string    `json:"account_id"`
+	OccurredOn time.Time `json:"occurredOn"`
+}
+
+func (e VoucherRedeemed) AggregateID() string {
+	return e.BatchID
+}
+
+func (e VoucherRedeemed) Occurred() time.Time {
+	return e.OccurredOn
+}
+
+func (e VoucherRedeemed) Type() string {
+	return "VoucherRedeemed"
+}
@@ -0,0 +1,76 @@
+package voucher
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewBatch(t *testing.T) {
+	b, err := NewBatch(10.0, 5, time.Time{})
+	if err != nil {
+		t.Errorf("NewBatch() error = %v", err)
+		return
+	}
+	if b.Issued() != 5 {
+		t.Errorf("expected 5 vouchers, got %d", b.Issued())
+	}
+	if len(b.Events()) != 1 {
+		t.Errorf("expected 1 event, got %d", len(b.Events()))
+		return
+	}
+	if _, ok := b.Events()[0].(BatchIssued); !ok {
+		t.Errorf("expected BatchIssued event, got %T", b.Events()[0])
+	}
+	if _, expires := b.ExpiresOn(); expires {
+		t.Errorf("batch should not expire")
+	}
+}
+
+func TestNewBatchInvalid(t *testing.T) {
+	if _, err := NewBatch(0, 5, time.Time{}); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("expected error for zero value")
+	}
+	if _, err := NewBatch(5, 0, time.Time{}); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("expected error for empty batch")
+	}
+	if _, err := NewBatch(5, MaxBatchSize+1, time.Time{}); !errors.Is(err, ErrorInvalidProperty) {
+		t.Errorf("expected error for oversized batch")
+	}
+}
+
+func TestRedeem(t *testing.T) {
+	b, _ := NewBatch(10.0, 2, time.Time{})
+	b.Clear()
+	code := b.Vouchers()[0].Code
+
+	if err := b.Redeem(code, "123", time.Now()); err != nil {
+		t.Errorf("Redeem() error = %v", err)
+		return
+	}
+	if b.Redeemed() != 1 {
+		t.Errorf("expected 1 redeemed voucher, got %d", b.Redeemed())
+	}
+	if _, ok := b.Events()[0].(VoucherRedeemed); !ok {
+		t.Errorf("expected VoucherRedeemed event, got %T", b.Events()[0])
+	}
+	if err := b.Redeem(code, "456", time.Now()); !errors.Is(err, ErrorAlreadyRedeemed) {
+		t.Errorf("expected ErrorAlreadyRedeemed, got %v", err)
+	}
+	if err := b.Redeem("AAAA-BBBB-CCCC", "456", time.Now()); !errors.Is(err, ErrorUnknownCode) {
+		t.Errorf("expected ErrorUnknownCode, got %v", err)
+	}
+}
+
+func TestRedeemExpired(t *testing.T) {
+	expiresOn := time.Now().Add(-time.Hour)
+	b, _ := NewBatch(10.0, 2, expiresOn)
+	code := b.Vouchers()[1].Code
+
+	if err := b.Redeem(code, "123", time.Now()); !errors.Is(err, ErrorExpired) {
+		t.Errorf("expected ErrorExpired, got %v", err)
+	}
+	if b.Expired(time.Now()) != 2 {
+		t.Errorf("expected 2 expired vouchers, got %d", b.Expired(time.Now()))
+	}
+}
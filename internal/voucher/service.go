@@ -0,0 +1,170 @@
+package voucher
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/event"
+	"coffy/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrorNotFound = errors.New("voucher batch not found")
+
+type Service struct {
+	repo       storage.EventRepository
+	accounting *account.Accounting
+	// serialises redemptions, so a voucher cannot be redeemed twice by concurrent requests
+	mu sync.Mutex
+}
+
+func NewService(repo *storage.EventRepository, accounting *account.Accounting) *Service {
+	return &Service{repo: *repo, accounting: accounting}
+}
+
+// Issue generates a new batch of n vouchers with the given value.
+func (s *Service) Issue(value float64, n int, expiresOn time.Time) (*Batch, error) {
+	b, err := NewBatch(value, n, expiresOn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue vouchers: %w", err)
+	}
+	entries, err := convertAll(b.Events())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveAll(entries); err != nil {
+		return nil, fmt.Errorf("failed to save voucher batch: %w", err)
+	}
+	b.Clear()
+	return b, nil
+}
+
+func (s *Service) ListAll() ([]Batch, error) {
+	query, err := s.repo.FetchByEventType("BatchIssued")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load voucher batches: %w", err)
+	}
+	batches := make([]Batch, 0)
+	for _, entry := range query {
+		b, err := s.Find(entry.AggregateID)
+		if err != nil {
+			return nil, errors.Join(errors.New("failed to load voucher batches"), err)
+		}
+		batches = append(batches, *b)
+	}
+	return batches, nil
+}
+
+func (s *Service) Find(batchID string) (*Batch, error) {
+	entries, err := s.repo.LoadAll(batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load voucher batch '%s': %w", batchID, err)
+	}
+	if len(entries) == 0 {
+		return nil, ErrorNotFound
+	}
+	b := &Batch{}
+	for _, entry := range entries {
+		e, err := convert(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load voucher batch '%s': %w", batchID, err)
+		}
+		if err := b.apply(e); err != nil {
+			log.Println(err)
+			return nil, fmt.Errorf("failed to load voucher batch '%s'", batchID)
+		}
+	}
+	b.Clear()
+	return b, nil
+}
+
+// Redeem redeems a voucher code for an account and deposits the voucher's value as an
+// account.IncomingPayment.
+//
+// The redemption and the payment are saved together, so a voucher is either redeemed and paid or not at all.
+func (s *Service) Redeem(accountID string, code string) (*account.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the voucher is redeemed for the account in use if the given account has been merged
+	a, err := s.accounting.Resolve(accountID)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.findByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Redeem(code, a.ID(), time.Now()); err != nil {
+		return nil, err
+	}
+	entries, err := convertAll(b.Events())
+	if err != nil {
+		return nil, err
+	}
+	a, err = s.accounting.Pay(a.ID(), b.Value(), "voucher "+NormalizeCode(code), entries...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem voucher: %w", err)
+	}
+	return a, nil
+}
+
+func (s *Service) findByCode(code string) (*Batch, error) {
+	batches, err := s.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range batches {
+		if b.Contains(code) {
+			return &b, nil
+		}
+	}
+	return nil, ErrorUnknownCode
+}
+
+func convertAll(events []event.Event) ([]storage.EventEntry, error) {
+	entries := make([]storage.EventEntry, 0)
+	for _, e := range events {
+		entry, err := toEventEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func convert(entry storage.EventEntry) (event.Event, error) {
+	switch entry.EventType {
+	case "BatchIssued":
+		evnt := BatchIssued{}
+		if err := json.Unmarshal(entry.EventData, &evnt); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as BatchIssued: %w", err)
+		}
+		return evnt, nil
+	case "VoucherRedeemed":
+		evnt := VoucherRedeemed{}
+		if err := json.Unmarshal(entry.EventData, &evnt); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data as VoucherRedeemed: %w", err)
+		}
+		return evnt, nil
+	default:
+		return nil, fmt.Errorf("unknown event type: %s", entry.EventType)
+	}
+}
+
+func toEventEntry(e event.Event) (storage.EventEntry, error) {
+	switch t := e.(type) {
+	case BatchIssued, VoucherRedeemed:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return storage.EventEntry{}, err
+		}
+		return storage.EventEntry{AggregateID: e.AggregateID(), EventType: e.Type(), Date: e.Occurred(), EventData: data}, nil
+	default:
+		return storage.EventEntry{}, fmt.Errorf("failed to convert event to entry: unknown event type '%T'", t)
+	}
+}
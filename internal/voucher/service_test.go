@@ -0,0 +1,36 @@
+package voucher
+
+import (
+	"coffy/internal/account"
+	"coffy/internal/storage/storagetest"
+	"testing"
+	"time"
+)
+
+func TestRedeemMergedAccount(t *testing.T) {
+	repo := storagetest.NewMemoryRepository()
+	accounting := account.NewAccounting(&repo)
+	s := NewService(&repo, accounting)
+	duplicate, _ := accounting.Create("Coffy", "")
+	target, _ := accounting.Create("Coffy Again", "")
+	if _, err := accounting.Merge(duplicate.ID(), target.ID()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.Issue(10, 1, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := b.Vouchers()[0].Code
+
+	a, err := s.Redeem(duplicate.ID(), code)
+	if err != nil {
+		t.Fatalf("Redeem() error = %v", err)
+	}
+	if a.ID() != target.ID() || a.Balance() != 10 {
+		t.Errorf("expected the voucher to be paid into the target account, got %s with balance %.2f", a.ID(), a.Balance())
+	}
+	b, _ = s.findByCode(code)
+	if v := b.Vouchers()[0]; v.RedeemedBy != target.ID() {
+		t.Errorf("expected the voucher to be redeemed by the target account, got %q", v.RedeemedBy)
+	}
+}
@@ -4,18 +4,34 @@ import (
 	"coffy/docs"
 	"coffy/internal/account"
 	"coffy/internal/api"
+	"coffy/internal/badge"
+	"coffy/internal/beverage"
+	"coffy/internal/cashbox"
 	"coffy/internal/cmd"
 	"coffy/internal/coffy"
 	"coffy/internal/consume"
+	"coffy/internal/cupping"
 	"coffy/internal/equipment"
+	"coffy/internal/inventory"
+	"coffy/internal/kiosk"
+	"coffy/internal/link"
+	"coffy/internal/notification"
+	"coffy/internal/pricing"
 	"coffy/internal/product"
+	"coffy/internal/quota"
+	"coffy/internal/rating"
+	"coffy/internal/report"
+	"coffy/internal/schedule"
 	"coffy/internal/storage"
+	"coffy/internal/subscription"
+	"coffy/internal/voucher"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"log"
 	"os"
+	"time"
 )
 
 // Holds the current version value of the application.
@@ -35,6 +51,7 @@ func main() {
 		}
 		os.Exit(0)
 	}()
+	cmd.OnImportTally(importTally)
 	cmd.Execute(startCoffy)
 }
 
@@ -42,18 +59,31 @@ func startCoffy(config *coffy.Config) {
 	log.Println("Received app configuration")
 	docs.SwaggerInfo.BasePath = "/api/v1"
 
-	// init the event repo
-	repo, err := storage.CreateEventRepository(config.Database.Path)
+	services, err := createServices(config)
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Println("Database in use:", config.Database.Path)
+	accService := services.accounting
+	beverageService := services.coffees
+	subscriptionService := services.subscriptions
+	consumeService := services.consume
+	machineService := services.machines
+	voucherService := voucher.NewService(&services.repo, accService)
+	kioskService := kiosk.NewService(&services.repo, accService, beverageService, consumeService)
+	badgeService := badge.NewService(&services.repo, accService)
+	cuppingService := cupping.NewService(&services.repo, beverageService)
+	ratingService := rating.NewService(&services.repo, accService, beverageService)
+	inventoryService := inventory.NewService(&services.repo, beverageService, consumeService, config.Inventory)
+	reportService := report.NewService(accService, beverageService, consumeService, inventoryService, subscriptionService, config.Costs)
+	startBilling(config.Billing, subscriptionService)
 
-	// create app services first
-	accService := account.NewAccounting(&repo)
-	beverageService := product.NewService(&repo)
-	consumeService := consume.NewService(accService, beverageService)
-	machineService := equipment.NewService(&repo)
+	// notifications are optional
+	if config.Notification != nil {
+		notifier := startNotifications(config.Notification, accService)
+		if config.Inventory.AlertEmail != "" {
+			startStockAlerts(config.Inventory, inventoryService, notifier)
+		}
+	}
 
 	router := gin.Default()
 	v1 := router.Group("/api/v1")
@@ -63,20 +93,100 @@ func startCoffy(config *coffy.Config) {
 		v1.GET(pathAccounts, api.GetAccounts(accService))
 		v1.GET(pathAccounts+"/:id", api.GetAccountById(accService))
 		v1.POST(pathAccounts, api.CreateAccount(accService))
+		v1.PATCH(pathAccounts+"/:id/contact", api.PatchAccountContact(accService))
+		v1.PATCH(pathAccounts+"/:id/group", api.PatchAccountGroup(accService))
+		v1.POST(pathAccounts+"/:id/merge", api.MergeAccount(accService))
+		v1.GET(pathAccounts+"/:id/statement", api.GetAccountStatement(accService))
+		v1.GET(pathAccounts+"/:id/stats", api.GetAccountStats(reportService))
+		v1.GET(pathAccounts+"/:id/receipts", api.GetAccountReceipts(consumeService))
+		v1.POST(pathAccounts+"/:id/redeem", api.RedeemVoucher(voucherService))
+		v1.PUT(pathAccounts+"/:id/subscription", api.PutAccountSubscription(subscriptionService))
+		v1.DELETE(pathAccounts+"/:id/subscription", api.DeleteAccountSubscription(subscriptionService))
 
 		// beverages API
-		v1.GET("/coffees", api.GetCoffees(beverageService))
+		v1.GET("/coffees", api.GetCoffees(beverageService, ratingService))
+		v1.GET("/coffees/top-rated", api.GetTopRatedCoffees(ratingService))
 		v1.POST("/coffees", api.CreateCoffee(beverageService))
 		v1.PATCH("/coffees/:id/price", api.PatchCoffeePrice(beverageService))
 		v1.PATCH("/coffees/:id/info", api.PatchCoffeeDetails(beverageService))
+		v1.POST("/coffees/:id/discontinue", api.DiscontinueCoffee(beverageService))
+		v1.POST("/coffees/:id/reinstate", api.ReinstateCoffee(beverageService))
+		v1.GET("/coffees/:id/prices", api.GetCoffeePrices(beverageService))
+		v1.GET("/coffees/:id/cuppings", api.GetCuppingSessions(cuppingService, beverageService))
+		v1.POST("/coffees/:id/cuppings", api.OpenCuppingSession(cuppingService))
+		v1.GET("/coffees/:id/ratings", api.GetCoffeeRatings(ratingService, beverageService))
+		v1.POST("/coffees/:id/ratings", api.RateCoffee(ratingService))
+		v1.POST("/coffees/:id/prices", api.ScheduleCoffeePrice(beverageService))
+		v1.GET("/coffees/:id/prices/upcoming", api.GetUpcomingCoffeePrices(beverageService))
+		v1.DELETE("/coffees/:id/prices/:change_id", api.CancelCoffeePrice(beverageService))
+		v1.GET("/coffees/:id/bags", api.GetCoffeeBags(inventoryService))
+		v1.POST("/coffees/:id/bags", api.PurchaseBag(inventoryService))
+		v1.GET("/coffees/:id/stock", api.GetCoffeeStock(inventoryService))
+
+		// inventory API
+		v1.GET("/inventory", api.GetInventory(inventoryService))
+
+		// beverage catalog API
+		v1.GET("/beverages", api.GetBeverages(services.beverages))
+		v1.GET("/beverages/:id", api.GetBeverage(services.beverages))
+		v1.POST("/beverages", api.CreateBeverage(services.beverages))
+		v1.PUT("/beverages/:id", api.PutBeverage(services.beverages))
+		v1.DELETE("/beverages/:id", api.DeleteBeverage(services.beverages))
+
+		// cupping API
+		v1.GET("/cuppings/:id", api.GetCuppingSession(cuppingService))
+		v1.POST("/cuppings/:id/scores", api.SubmitCuppingScores(cuppingService))
+		v1.POST("/cuppings/:id/close", api.CloseCuppingSession(cuppingService))
 
 		// consume API
 		v1.POST("/consume", api.Consume(consumeService))
+		v1.POST("/consume/batch", api.ConsumeBatch(consumeService))
+		v1.POST("/consume/override", api.ConsumeOverride(consumeService))
+		v1.POST("/consume/badge", api.ConsumeByBadge(badgeService, consumeService))
+		v1.GET("/receipts/:id", api.GetReceipt(consumeService))
+
+		// badge API
+		v1.GET("/badges", api.GetBadges(badgeService))
+		v1.POST("/badges", api.RegisterBadge(badgeService))
+		v1.DELETE("/badges/:uid", api.RevokeBadge(badgeService))
+		v1.POST("/badges/:uid/lost", api.ReportBadgeLost(badgeService))
+
+		// cash box API
+		v1.GET("/cashbox", api.GetCashBox(services.cash))
+		v1.POST("/cashbox/count", api.CountCashBox(services.cash))
+
+		// kiosk API
+		v1.POST("/sync", api.Sync(kioskService))
 
 		// machine API
 		v1.GET("/machines", api.GetMachines(machineService))
 		v1.POST("/machines", api.CreateMachine(machineService))
-		v1.PATCH("/machines/:id", api.PatchMachines(machineService, beverageService))
+		v1.PATCH("/machines/:id", api.PatchMachines(machineService))
+		v1.POST("/machines/:id/consume", api.ConsumeFromMachine(consumeService))
+
+		// voucher API
+		v1.GET("/vouchers", api.GetVoucherBatches(voucherService))
+		v1.GET("/vouchers/:id", api.GetVoucherBatch(voucherService))
+		v1.POST("/vouchers", api.CreateVoucherBatch(voucherService))
+
+		// subscription API
+		v1.GET("/plans", api.GetPlans(subscriptionService))
+		v1.POST("/plans", api.CreatePlan(subscriptionService))
+
+		// reports API
+		v1.GET("/reports/subsidies", api.GetSubsidyReport(reportService))
+		v1.GET("/reports/costs", api.GetCostReport(reportService))
+	}
+
+	// QR codes and the pages opened by scanning them are optional
+	if config.Links != nil {
+		links := link.FromConfig(config.Links)
+		v1.GET("/accounts/:id/qrcode", api.GetAccountQRCode(links, accService))
+		v1.GET("/machines/:id/qrcode", api.GetMachineQRCode(links, machineService))
+		router.GET("/m/:token", api.MachinePage(links, machineService, beverageService, accService))
+		router.POST("/m/:token", api.ConfirmMachineConsume(links, consumeService, accService))
+		router.GET("/a/:token", api.AccountPage(links, accService, machineService, beverageService))
+		router.POST("/a/:token", api.ConfirmAccountConsume(links, consumeService, accService))
 	}
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -89,6 +199,113 @@ func startCoffy(config *coffy.Config) {
 	log.Println("Coffy Machine is running and listening on port", config.Server.Port)
 }
 
+// services holds the app services shared by the server and the import command.
+type services struct {
+	repo          storage.EventRepository
+	accounting    *account.Accounting
+	coffees       *product.Service
+	beverages     *beverage.Service
+	machines      *equipment.Service
+	subscriptions *subscription.Service
+	cash          *cashbox.Service
+	consume       *consume.Service
+}
+
+func createServices(config *coffy.Config) (*services, error) {
+	// init the event repo
+	repo, err := storage.CreateEventRepository(config.Database.Path)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Database in use:", config.Database.Path)
+
+	pricingEngine, err := pricing.FromConfig(config.Pricing)
+	if err != nil {
+		return nil, err
+	}
+	s := &services{repo: repo}
+	s.accounting = account.NewAccounting(&repo)
+	s.coffees = product.NewService(&repo)
+	s.machines = equipment.NewService(&repo, s.coffees)
+	s.subscriptions = subscription.NewService(&repo, s.accounting, config.Billing)
+	s.cash = cashbox.NewService(&repo)
+	s.beverages = beverage.NewService(&repo, s.coffees)
+	s.consume = consume.NewService(&repo, s.accounting, s.coffees, s.beverages, s.machines, pricingEngine, s.subscriptions, quota.FromConfig(config.Quotas), s.cash)
+	return s, nil
+}
+
+// importTally records the consumptions of a tally sheet file and prints the report of each failed row.
+func importTally(config *coffy.Config, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rows, err := consume.ParseTally(f)
+	if err != nil {
+		return err
+	}
+	services, err := createServices(config)
+	if err != nil {
+		return err
+	}
+	report, err := services.consume.ConsumeBatch(rows)
+	if report != nil {
+		for _, row := range report.Rows {
+			if row.Error != "" {
+				fmt.Printf("row %d: %s\n", row.Row, row.Error)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d rows from %s\n", len(report.Rows), file)
+	return nil
+}
+
+func startBilling(config *coffy.BillingCfg, subscriptionService *subscription.Service) {
+	chargeFees := func() {
+		if err := subscriptionService.ChargeFees(time.Now()); err != nil {
+			log.Println(err)
+		}
+	}
+	// catch up on fees that were due while the server was not running
+	chargeFees()
+	schedule.Start(schedule.Monthly(config.Day, config.Hour), chargeFees)
+	log.Printf("Subscription fees are charged monthly on day %d at %d:00", config.Day, config.Hour)
+}
+
+func startNotifications(config *coffy.NotificationCfg, accService *account.Accounting) *notification.Service {
+	notifier := notification.NewService(accService, notification.NewSmtpMailer(config.Smtp), config)
+	accService.OnBalanceChange(notifier.BalanceChanged)
+	log.Println("Notifications enabled via SMTP server:", config.Smtp.Host)
+
+	if config.Dunning == nil {
+		return notifier
+	}
+	schedule.Start(schedule.Weekly(config.Dunning.Day(), config.Dunning.Hour), func() {
+		if err := notifier.RemindDebtors(time.Now()); err != nil {
+			log.Println(err)
+		}
+	})
+	log.Printf("Debt reminders scheduled every %s at %d:00", config.Dunning.Day(), config.Dunning.Hour)
+	return notifier
+}
+
+func startStockAlerts(config *coffy.InventoryCfg, inventoryService *inventory.Service, notifier *notification.Service) {
+	schedule.Start(schedule.Daily(config.AlertHour), func() {
+		low, err := inventoryService.LowStock(time.Now())
+		if err == nil {
+			err = notifier.AlertLowStock(config.AlertEmail, low)
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	log.Printf("Low-stock alerts are sent daily at %d:00 to %s", config.AlertHour, config.AlertEmail)
+}
+
 func logStartup() {
 	log.Printf("Starting Coffy server (version: %s) ...", version)
 }